@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/Ozoniuss/hangcounts/config"
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/infrastructure"
+	"github.com/Ozoniuss/hangcounts/migrations"
+)
+
+const (
+	EXPORT_USAGE  = "usage: hangcounts export [-format json|zip] [-o file] <username>"
+	ERASE_USAGE   = "usage: hangcounts erase <username>"
+	MIGRATE_USAGE = "usage: hangcounts migrate up | down <n> | version | force <version>"
+)
+
+// runAdminCommand runs the command named by the first argument, and reports
+// whether such a command exists.
+func runAdminCommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case "export":
+		return true, runExport(args[1:])
+	case "erase":
+		return true, runErase(args[1:])
+	case "migrate":
+		return true, runMigrate(args[1:])
+	}
+	return false, nil
+}
+
+// openAdminStore connects to the database of the app. The logs go to stderr,
+// to keep stdout for the output of the commands.
+func openAdminStore(ctx context.Context) (*infrastructure.PostgresStore, *slog.Logger, error) {
+	// the flags of the commands are not settings of the app
+	config, err := config.NewAppConfig(nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read config: %w", err)
+	}
+	logger := config.Logging.NewLogger(os.Stderr)
+
+	pgStore, err := infrastructure.NewPostgresStore(ctx, config.Database, logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create a postgres store: %w", err)
+	}
+	return pgStore, logger, nil
+}
+
+// runExport writes everything stored about an individual to stdout, or to the
+// file given with -o.
+func runExport(args []string) (err error) {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", string(export.FORMAT_JSON), "format of the export, json or zip")
+	output := flags.String("o", "", "file to write the export to, stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("could not parse arguments: %w", err)
+	}
+	if flags.NArg() != 1 {
+		return errors.New(EXPORT_USAGE)
+	}
+	username := model.IndividualId(flags.Arg(0))
+	exportFormat, err := export.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pgStore, logger, err := openAdminStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer pgStore.Close()
+
+	data, err := export.NewService(pgStore).Export(ctx, username)
+	if err != nil {
+		return fmt.Errorf("could not export %s: %w", username, err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %w", err)
+		}
+		defer func() {
+			if closeErr := f.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("could not close output file: %w", closeErr)
+			}
+		}()
+		w = f
+	}
+
+	if err := data.Write(w, exportFormat); err != nil {
+		return fmt.Errorf("could not write export: %w", err)
+	}
+	logger.Info("exported individual", slog.String("username", string(username)), slog.String("format", string(exportFormat)))
+	return nil
+}
+
+// runErase irreversibly wipes the personal data of an individual, whether or
+// not they deleted their account.
+func runErase(args []string) error {
+	if len(args) != 1 {
+		return errors.New(ERASE_USAGE)
+	}
+	username := model.IndividualId(args[0])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pgStore, logger, err := openAdminStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer pgStore.Close()
+
+	if err := pgStore.EraseIndividual(ctx, username); err != nil {
+		return fmt.Errorf("could not erase %s: %w", username, err)
+	}
+	logger.Info("erased individual", slog.String("username", string(username)))
+	return nil
+}
+
+// runMigrate applies or reverts the embedded migrations, with the same
+// commands as golang-migrate.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(MIGRATE_USAGE)
+	}
+	command := args[0]
+	var arg int64
+	switch command {
+	case "up", "version":
+		if len(args) != 1 {
+			return errors.New(MIGRATE_USAGE)
+		}
+	case "down", "force":
+		if len(args) != 2 {
+			return errors.New(MIGRATE_USAGE)
+		}
+		parsed, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s argument: %w", command, err)
+		}
+		if command == "down" && parsed < 1 {
+			return errors.New("down needs at least one migration to revert")
+		}
+		arg = parsed
+	default:
+		return errors.New(MIGRATE_USAGE)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pgStore, _, err := openAdminStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer pgStore.Close()
+
+	migrator, err := infrastructure.NewMigrator(pgStore, migrations.FS)
+	if err != nil {
+		return fmt.Errorf("could not load migrations: %w", err)
+	}
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx, int(arg))
+	case "force":
+		return migrator.Force(ctx, arg)
+	}
+
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	// printed like golang-migrate does
+	if dirty {
+		fmt.Printf("%d (dirty)\n", version)
+	} else {
+		fmt.Println(version)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// Package batch runs the background jobs which process records in batches,
+// such as the session reaper and the retention purger.
+package batch
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RunEvery calls job every interval, until ctx is cancelled.
+func RunEvery(ctx context.Context, name string, interval time.Duration, logger *slog.Logger, job func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.InfoContext(ctx, "started "+name, slog.Duration("interval", interval))
+	for {
+		select {
+		case <-ctx.Done():
+			logger.InfoContext(ctx, "stopped "+name)
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
+
+// Drain calls process with batches of size batchSize until there is nothing
+// left to process, and returns the number of processed records. It stops at
+// the first error, the records processed until then are still counted.
+func Drain(ctx context.Context, batchSize int, process func(ctx context.Context, limit int) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		processed, err := process(ctx, batchSize)
+		total += processed
+		if err != nil {
+			return total, err
+		}
+		// a partial batch means there is nothing left to process
+		if processed < int64(batchSize) || ctx.Err() != nil {
+			return total, nil
+		}
+	}
+}
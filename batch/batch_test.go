@@ -0,0 +1,60 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRecords processes the records it holds, limit at a time.
+type fakeRecords struct {
+	left    int64
+	batches []int64
+	err     error
+}
+
+func (f *fakeRecords) process(_ context.Context, limit int) (int64, error) {
+	processed := min(f.left, int64(limit))
+	f.left -= processed
+	f.batches = append(f.batches, processed)
+	return processed, f.err
+}
+
+func Test_Drain_StopsAfterPartialBatch(t *testing.T) {
+	records := &fakeRecords{left: 5}
+
+	total, err := Drain(t.Context(), 2, records.process)
+	assert.NoError(t, err, "expected no error when draining")
+	assert.Equal(t, int64(5), total, "expected every record to be processed")
+	assert.Equal(t, []int64{2, 2, 1}, records.batches, "expected full batches until the last one")
+}
+
+func Test_Drain_FullLastBatch_ChecksForMore(t *testing.T) {
+	records := &fakeRecords{left: 4}
+
+	total, err := Drain(t.Context(), 2, records.process)
+	assert.NoError(t, err, "expected no error when draining")
+	assert.Equal(t, int64(4), total)
+	assert.Equal(t, []int64{2, 2, 0}, records.batches, "expected an empty batch to end the drain")
+}
+
+func Test_Drain_Error_ReturnsProcessedRecords(t *testing.T) {
+	records := &fakeRecords{left: 5, err: errors.New("database down")}
+
+	total, err := Drain(t.Context(), 2, records.process)
+	assert.Error(t, err, "expected the error to be returned")
+	assert.Equal(t, int64(2), total, "expected the records processed before the error to be counted")
+	assert.Len(t, records.batches, 1, "expected no batch after the error")
+}
+
+func Test_Drain_CancelledContext_StopsAfterCurrentBatch(t *testing.T) {
+	records := &fakeRecords{left: 5}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	total, err := Drain(ctx, 2, records.process)
+	assert.NoError(t, err, "expected no error when cancelled")
+	assert.Equal(t, int64(2), total)
+}
@@ -1,73 +1,375 @@
 package config
 
 import (
-	"errors"
-	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	DEFAULT_SECRET_RELOAD_INTERVAL = time.Minute
+	DEFAULT_APPLICATION_NAME       = "hangcounts"
+)
+
+// the sslmode values of libpq
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// PostgresConfig describes the connection to the database. The zero values
+// of the optional settings keep the defaults of pgx.
 type PostgresConfig struct {
-	User       string
-	Password   string
-	DbName     string
-	Host       string
-	Port       int
+	// URL is the base of the connection, the other settings override its parts
+	URL      Secret
+	User     string
+	Password Secret
+	// the file the password was read from, empty if it was set directly
+	PasswordFile string
+	// how often the password file is reread, to pick up rotated secrets
+	SecretReloadInterval time.Duration
+	DbName               string
+	Host                 string
+	Port                 int
+
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	ApplicationName  string
+	ConnectTimeout   time.Duration
+	StatementTimeout time.Duration
+
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+
 	ShowConfig bool
+	// apply the pending migrations before serving, instead of relying on
+	// golang-migrate being run separately
+	MigrateOnStartup bool
+}
+
+func newPostgresConfig(l *loader) PostgresConfig {
+	connURL, _ := l.secret("postgres.url")
+	password, passwordFile := l.secret("postgres.password")
+	c := PostgresConfig{
+		URL:               connURL,
+		User:              l.string("postgres.user"),
+		Password:          password,
+		PasswordFile:      passwordFile,
+		DbName:            l.string("postgres.db"),
+		Host:              l.string("postgres.host"),
+		Port:              int(l.uint("postgres.port", 16)),
+		SSLMode:           l.string("postgres.sslmode"),
+		SSLRootCert:       l.string("postgres.sslrootcert"),
+		SSLCert:           l.string("postgres.sslcert"),
+		SSLKey:            l.string("postgres.sslkey"),
+		ApplicationName:   l.string("postgres.application_name"),
+		ConnectTimeout:    l.duration("postgres.connect_timeout"),
+		StatementTimeout:  l.duration("postgres.statement_timeout"),
+		MaxConns:          int32(l.uint("postgres.max_conns", 31)),
+		MinConns:          int32(l.uint("postgres.min_conns", 31)),
+		MaxConnLifetime:   l.duration("postgres.max_conn_lifetime"),
+		MaxConnIdleTime:   l.duration("postgres.max_conn_idle_time"),
+		HealthCheckPeriod: l.duration("postgres.health_check_period"),
+		ShowConfig:        l.bool("postgres.show_config"),
+		MigrateOnStartup:  l.bool("postgres.migrate_on_startup"),
+	}
+	if passwordFile != "" {
+		c.SecretReloadInterval = l.duration("postgres.secret_reload_interval")
+	}
+
+	if c.URL.IsEmpty() {
+		l.require("without postgres.url", "postgres.user", "postgres.db", "postgres.host", "postgres.port")
+		if c.Password.IsEmpty() && passwordFile == "" {
+			r := l.resolve(lookupSetting("postgres.password"))
+			l.problem(r.key, unsetSource(r.setting), "is required without postgres.url, directly or with postgres.password_file")
+		}
+	} else if u, err := url.Parse(c.URL.Reveal()); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		// the url is not quoted, it may contain a password
+		r := l.resolve(lookupSetting("postgres.url"))
+		l.problem(r.key, r.source, "must be a postgres:// or postgresql:// url")
+	}
+
+	if c.SSLMode != "" && !slices.Contains(sslModes, c.SSLMode) {
+		r := l.resolve(lookupSetting("postgres.sslmode"))
+		l.problem(r.key, r.source, "invalid sslmode %s: must be one of %s", strconv.Quote(c.SSLMode), strings.Join(sslModes, ", "))
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		r := l.resolve(lookupSetting("postgres.sslkey"))
+		l.problem(r.key, r.source, "postgres.sslcert and postgres.sslkey must be set together")
+	}
+	if c.MaxConns != 0 && c.MinConns > c.MaxConns {
+		r := l.resolve(lookupSetting("postgres.min_conns"))
+		l.problem(r.key, r.source, "cannot exceed postgres.max_conns")
+	}
+	return c
+}
+
+const DEFAULT_HTTP_ADDRESS = ":8080"
+const DEFAULT_HTTP_ADMIN_ADDRESS = ":8081"
+
+type ServerConfig struct {
+	Address string
+	// AdminAddress serves the probes, the status and the metrics of the app,
+	// it must only be reachable from inside the cluster
+	AdminAddress string
+	// how long the server keeps serving after the readiness probe started
+	// failing on shutdown, at least the period of the probe
+	ShutdownDelay time.Duration
+}
+
+func newServerConfig(l *loader) ServerConfig {
+	c := ServerConfig{
+		Address:       l.string("server.address"),
+		AdminAddress:  l.string("server.admin_address"),
+		ShutdownDelay: l.duration("server.shutdown_delay"),
+	}
+	if c.AdminAddress == c.Address {
+		r := l.resolve(lookupSetting("server.admin_address"))
+		l.problem(r.key, r.source, "must differ from server.address")
+	}
+	return c
+}
+
+const (
+	DEFAULT_SESSION_IDLE_EXPIRATION     = 30 * time.Minute
+	DEFAULT_SESSION_ABSOLUTE_EXPIRATION = 24 * time.Hour
+	DEFAULT_SESSION_REAP_INTERVAL       = 10 * time.Minute
+)
+
+type SessionConfig struct {
+	IdleExpiration     time.Duration
+	AbsoluteExpiration time.Duration
+	// how often expired sessions are deleted from the database
+	ReapInterval time.Duration
+}
+
+func newSessionConfig(l *loader) SessionConfig {
+	c := SessionConfig{
+		IdleExpiration:     l.duration("session.idle_expiration"),
+		AbsoluteExpiration: l.duration("session.absolute_expiration"),
+		ReapInterval:       l.duration("session.reap_interval"),
+	}
+	if c.IdleExpiration > c.AbsoluteExpiration {
+		r := l.resolve(lookupSetting("session.idle_expiration"))
+		l.problem(r.key, r.source, "cannot exceed the absolute expiration")
+	}
+	return c
+}
+
+const (
+	PASSWORD_ALGORITHM_ARGON2ID = "argon2id"
+	PASSWORD_ALGORITHM_BCRYPT   = "bcrypt"
+
+	// the parameters recommended by RFC 9106 for memory constrained
+	// environments
+	DEFAULT_ARGON2_TIME       = 3
+	DEFAULT_ARGON2_MEMORY_KIB = 64 * 1024
+	DEFAULT_ARGON2_THREADS    = 4
+	DEFAULT_BCRYPT_COST       = 12
+)
+
+type PasswordConfig struct {
+	Algorithm       string
+	Argon2Time      uint32
+	Argon2MemoryKiB uint32
+	Argon2Threads   uint8
+	BcryptCost      int
+}
+
+func newPasswordConfig(l *loader) PasswordConfig {
+	algorithm := l.get("password.algorithm")
+	if algorithm.value != PASSWORD_ALGORITHM_ARGON2ID && algorithm.value != PASSWORD_ALGORITHM_BCRYPT {
+		l.problem(algorithm.key, algorithm.source, "invalid password algorithm %s: must be %q or %q", strconv.Quote(algorithm.value), PASSWORD_ALGORITHM_ARGON2ID, PASSWORD_ALGORITHM_BCRYPT)
+	}
+
+	bcryptCost := l.uint("password.bcrypt_cost", 8)
+	// bcrypt.MinCost and bcrypt.MaxCost, not imported to keep config free of
+	// crypto dependencies
+	if bcryptCost != 0 && (bcryptCost < 4 || bcryptCost > 31) {
+		r := l.resolve(lookupSetting("password.bcrypt_cost"))
+		l.problem(r.key, r.source, "must be between 4 and 31")
+	}
+
+	return PasswordConfig{
+		Algorithm:       algorithm.value,
+		Argon2Time:      uint32(l.uint("password.argon2_time", 32)),
+		Argon2MemoryKiB: uint32(l.uint("password.argon2_memory_kib", 32)),
+		Argon2Threads:   uint8(l.uint("password.argon2_threads", 8)),
+		BcryptCost:      int(bcryptCost),
+	}
+}
+
+const (
+	DEFAULT_SMTP_PORT      = 587
+	DEFAULT_MAGIC_LINK_TTL = 15 * time.Minute
+)
+
+// MagicLinkConfig is optional, magic links are disabled if no SMTP host is
+// configured.
+type MagicLinkConfig struct {
+	SmtpHost     string
+	SmtpPort     int
+	SmtpUsername string
+	SmtpPassword Secret
+	From         string
+	// the frontend page exchanging the token for a session
+	URL string
+	TTL time.Duration
+}
+
+func (c MagicLinkConfig) Enabled() bool {
+	return c.SmtpHost != ""
+}
+
+func newMagicLinkConfig(l *loader) MagicLinkConfig {
+	host := l.string("smtp.host")
+	if host == "" {
+		return MagicLinkConfig{}
+	}
+
+	smtpPassword, _ := l.secret("smtp.password")
+	c := MagicLinkConfig{
+		SmtpHost:     host,
+		SmtpPort:     int(l.uint("smtp.port", 16)),
+		SmtpUsername: l.string("smtp.username"),
+		SmtpPassword: smtpPassword,
+		From:         l.string("smtp.from"),
+		URL:          l.string("magic_link.url"),
+		TTL:          l.duration("magic_link.ttl"),
+	}
+	for _, key := range []string{"smtp.from", "magic_link.url"} {
+		if r := l.resolve(lookupSetting(key)); r.value == "" {
+			l.problem(key, unsetSource(r.setting), "is required when smtp.host is set")
+		}
+	}
+	return c
+}
+
+const (
+	DEFAULT_DELETED_HANGOUT_RETENTION    = 30 * 24 * time.Hour
+	DEFAULT_DELETED_INDIVIDUAL_RETENTION = 30 * 24 * time.Hour
+	DEFAULT_PURGE_INTERVAL               = time.Hour
+)
+
+type RetentionConfig struct {
+	// how long soft-deleted hangouts can be restored before they are purged
+	DeletedHangouts time.Duration
+	// the grace period during which individuals can restore their account,
+	// after which it is anonymised
+	DeletedIndividuals time.Duration
+	// how often the expired records are purged from the database
+	PurgeInterval time.Duration
+}
+
+func newRetentionConfig(l *loader) RetentionConfig {
+	return RetentionConfig{
+		DeletedHangouts:    l.duration("retention.deleted_hangouts"),
+		DeletedIndividuals: l.duration("retention.deleted_individuals"),
+		PurgeInterval:      l.duration("retention.purge_interval"),
+	}
 }
 
-func newPostgresConfig() (PostgresConfig, error) {
-	user := os.Getenv("HANGCOUNTS_POSTGRES_USER")
-	pw := os.Getenv("HANGCOUNTS_POSTGRES_PASSWORD")
-	db := os.Getenv("HANGCOUNTS_POSTGRES_DB")
-	host := os.Getenv("HANGCOUNTS_POSTGRES_HOST")
-	portstr := os.Getenv("HANGCOUNTS_POSTGRES_PORT")
-	showConfigStr := os.Getenv("HANGCOUNTS_POSTGRES_SHOW_CONFIG")
+const (
+	LOG_FORMAT_JSON = "json"
+	LOG_FORMAT_TEXT = "text"
+)
 
-	// let the condition below catch this failure
-	port, _ := strconv.Atoi(portstr)
+type LoggingConfig struct {
+	Level  slog.Level
+	Format string
+}
 
-	if user == "" || pw == "" || db == "" || host == "" || port == 0 {
-		return PostgresConfig{}, errors.New("empty postgres config")
+// the level defaults to debug in dev and info in prod
+func newLoggingConfig(l *loader, env string) LoggingConfig {
+	c := LoggingConfig{Level: slog.LevelInfo}
+	if env == "dev" {
+		c.Level = slog.LevelDebug
+	}
+	if level := l.get("logging.level"); level.value != "" {
+		if err := c.Level.UnmarshalText([]byte(level.value)); err != nil {
+			l.problem(level.key, level.source, "invalid level %s", strconv.Quote(level.value))
+		}
 	}
 
-	showConfig := false
-	if showConfigStr == "true" {
-		showConfig = true
+	format := l.get("logging.format")
+	if format.value != LOG_FORMAT_JSON && format.value != LOG_FORMAT_TEXT {
+		l.problem(format.key, format.source, "invalid format %s: must be %q or %q", strconv.Quote(format.value), LOG_FORMAT_JSON, LOG_FORMAT_TEXT)
 	}
+	c.Format = format.value
+	return c
+}
 
-	return PostgresConfig{
-		User:       user,
-		Password:   pw,
-		DbName:     db,
-		Host:       host,
-		Port:       port,
-		ShowConfig: showConfig,
-	}, nil
+// NewLogger returns a logger writing to w with the configured level and
+// format.
+func (c LoggingConfig) NewLogger(w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: c.Level}
+	if c.Format == LOG_FORMAT_TEXT {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
 }
 
 type AppConfig struct {
-	Env      string
-	Database PostgresConfig
+	Env       string
+	Database  PostgresConfig
+	Server    ServerConfig
+	Session   SessionConfig
+	Password  PasswordConfig
+	MagicLink MagicLinkConfig
+	Retention RetentionConfig
+	Logging   LoggingConfig
+
+	// PrintConfig is set by -print-config, the caller should print the
+	// settings and exit instead of starting
+	PrintConfig bool
+	resolved    []resolvedSetting
+	problems    []Problem
 }
 
-func NewAppConfig() (AppConfig, error) {
-	var cfgErr error
-	pgconfig, err := newPostgresConfig()
+// NewAppConfig reads the configuration from the defaults, the config file,
+// the environment and the command-line arguments, in increasing order of
+// precedence. Invalid settings are reported together in a *ValidationError.
+func NewAppConfig(args []string) (AppConfig, error) {
+	l, err := newLoader(args, os.Getenv)
 	if err != nil {
-		cfgErr = errors.Join(cfgErr, err)
+		return AppConfig{}, err
 	}
 
-	env := os.Getenv("HANGCOUNTS_ENV")
-	if env != "dev" && env != "prod" {
-		cfgErr = errors.Join(cfgErr, fmt.Errorf("invalid env value %s: must be \"dev\" or \"prod\"", strconv.Quote(env)))
+	env := l.get("env")
+	if env.value != "" && env.value != "dev" && env.value != "prod" {
+		l.problem(env.key, env.source, "invalid env value %s: must be \"dev\" or \"prod\"", strconv.Quote(env.value))
 	}
 
-	if cfgErr != nil {
-		return AppConfig{}, cfgErr
+	c := AppConfig{
+		Env:         env.value,
+		Database:    newPostgresConfig(l),
+		Server:      newServerConfig(l),
+		Session:     newSessionConfig(l),
+		Password:    newPasswordConfig(l),
+		MagicLink:   newMagicLinkConfig(l),
+		Retention:   newRetentionConfig(l),
+		Logging:     newLoggingConfig(l, env.value),
+		PrintConfig: l.printConfig,
+		resolved:    l.report(),
+		problems:    l.problems,
+	}
+	// the configuration is printed even when invalid, to find out why
+	if err := l.err(); err != nil && !c.PrintConfig {
+		return AppConfig{}, err
 	}
-	return AppConfig{
-		Database: pgconfig,
-		Env:      env,
-	}, nil
+	return c, nil
+}
+
+// WriteSettings prints every setting with the source of its value, the
+// secrets redacted, followed by the problems of the configuration.
+func (c AppConfig) WriteSettings(w io.Writer) error {
+	return writeSettings(w, c.resolved, c.problems)
 }
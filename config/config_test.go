@@ -5,8 +5,19 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// readConfig reads a section from the environment of the test, returning the
+// problems found.
+func readConfig[T any](t *testing.T, newSection func(*loader) T) (T, error) {
+	t.Helper()
+	l, err := newLoader(nil, os.Getenv)
+	require.NoError(t, err, "expected the loader to be created")
+	c := newSection(l)
+	return c, l.err()
+}
+
 func Test_NewPostgresConfig_ValidConfig_ReturnsNoError(t *testing.T) {
 	t.Setenv("HANGCOUNTS_POSTGRES_USER", "val")
 	t.Setenv("HANGCOUNTS_POSTGRES_PASSWORD", "val")
@@ -14,17 +25,20 @@ func Test_NewPostgresConfig_ValidConfig_ReturnsNoError(t *testing.T) {
 	t.Setenv("HANGCOUNTS_POSTGRES_HOST", "val")
 	t.Setenv("HANGCOUNTS_POSTGRES_PORT", "5432")
 	t.Setenv("HANGCOUNTS_POSTGRES_SHOW_CONFIG", "true")
+	t.Setenv("HANGCOUNTS_POSTGRES_MIGRATE_ON_STARTUP", "true")
 
-	c, err := newPostgresConfig()
+	c, err := readConfig(t, newPostgresConfig)
 	assert.NoError(t, err, "config should be valid")
 
 	assert.Equal(t, c, PostgresConfig{
-		User:       "val",
-		Password:   "val",
-		DbName:     "val",
-		Host:       "val",
-		Port:       5432,
-		ShowConfig: true,
+		User:             "val",
+		Password:         NewSecret("val"),
+		DbName:           "val",
+		Host:             "val",
+		Port:             5432,
+		ApplicationName:  DEFAULT_APPLICATION_NAME,
+		ShowConfig:       true,
+		MigrateOnStartup: true,
 	})
 }
 
@@ -52,10 +66,206 @@ func Test_NewPostgresConfig_MissingValue_ReturnsError(t *testing.T) {
 			original := os.Getenv(tt.envToUnset)
 			t.Setenv(tt.envToUnset, "")
 
-			_, err := newPostgresConfig()
+			_, err := readConfig(t, newPostgresConfig)
 			assert.Error(t, err, "config should not be valid")
 
 			t.Setenv(tt.envToUnset, original)
 		})
 	}
 }
+
+func Test_NewPostgresConfig_UrlSet_OtherValuesAreOptional(t *testing.T) {
+	for _, key := range []string{"USER", "PASSWORD", "DB", "HOST", "PORT"} {
+		t.Setenv("HANGCOUNTS_POSTGRES_"+key, "")
+	}
+	t.Setenv("HANGCOUNTS_POSTGRES_URL", "postgres://user:pw@db.example.com:5432/hangcounts?sslmode=verify-full")
+
+	c, err := readConfig(t, newPostgresConfig)
+	assert.NoError(t, err, "config should be valid")
+	assert.Equal(t, "postgres://user:pw@db.example.com:5432/hangcounts?sslmode=verify-full", c.URL.Reveal(), "expected the url from env")
+}
+
+func Test_NewPostgresConfig_InvalidConnectionSettings_ReturnsError(t *testing.T) {
+	t.Setenv("HANGCOUNTS_POSTGRES_USER", "val")
+	t.Setenv("HANGCOUNTS_POSTGRES_PASSWORD", "val")
+	t.Setenv("HANGCOUNTS_POSTGRES_DB", "val")
+	t.Setenv("HANGCOUNTS_POSTGRES_HOST", "val")
+	t.Setenv("HANGCOUNTS_POSTGRES_PORT", "5432")
+
+	tc := []struct {
+		name  string
+		env   string
+		value string
+	}{
+		{name: "not a postgres url", env: "HANGCOUNTS_POSTGRES_URL", value: "mysql://db.example.com"},
+		{name: "unknown sslmode", env: "HANGCOUNTS_POSTGRES_SSLMODE", value: "always"},
+		{name: "certificate without key", env: "HANGCOUNTS_POSTGRES_SSLCERT", value: "client.crt"},
+		{name: "negative statement timeout", env: "HANGCOUNTS_POSTGRES_STATEMENT_TIMEOUT", value: "-1s"},
+		{name: "more min than max conns", env: "HANGCOUNTS_POSTGRES_MIN_CONNS", value: "20"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HANGCOUNTS_POSTGRES_MAX_CONNS", "10")
+			t.Setenv(tt.env, tt.value)
+
+			_, err := readConfig(t, newPostgresConfig)
+			assert.Error(t, err, "config should not be valid")
+		})
+	}
+}
+
+func Test_NewServerConfig_AddressNotSet_ReturnsDefaultAddress(t *testing.T) {
+	t.Setenv("HANGCOUNTS_HTTP_ADDRESS", "")
+
+	c, _ := readConfig(t, newServerConfig)
+	assert.Equal(t, DEFAULT_HTTP_ADDRESS, c.Address, "expected default address when env is not set")
+}
+
+func Test_NewServerConfig_AddressSet_ReturnsAddress(t *testing.T) {
+	t.Setenv("HANGCOUNTS_HTTP_ADDRESS", "127.0.0.1:9000")
+
+	c, _ := readConfig(t, newServerConfig)
+	assert.Equal(t, "127.0.0.1:9000", c.Address, "expected address from env")
+}
+
+func Test_NewServerConfig_AdminAddressNotSet_ReturnsDefaultAdminAddress(t *testing.T) {
+	t.Setenv("HANGCOUNTS_HTTP_ADMIN_ADDRESS", "")
+
+	c, err := readConfig(t, newServerConfig)
+	require.NoError(t, err, "expected the default addresses to be valid")
+	assert.Equal(t, DEFAULT_HTTP_ADMIN_ADDRESS, c.AdminAddress, "expected default admin address when env is not set")
+}
+
+func Test_NewServerConfig_AdminAddressIsTheAddress_ReturnsError(t *testing.T) {
+	t.Setenv("HANGCOUNTS_HTTP_ADDRESS", ":9000")
+	t.Setenv("HANGCOUNTS_HTTP_ADMIN_ADDRESS", ":9000")
+
+	_, err := readConfig(t, newServerConfig)
+	assert.ErrorContains(t, err, "server.admin_address", "expected the probes to not share the public listener")
+}
+
+func Test_NewSessionConfig_NothingSet_ReturnsDefaults(t *testing.T) {
+	t.Setenv("HANGCOUNTS_SESSION_IDLE_EXPIRATION", "")
+	t.Setenv("HANGCOUNTS_SESSION_ABSOLUTE_EXPIRATION", "")
+	t.Setenv("HANGCOUNTS_SESSION_REAP_INTERVAL", "")
+
+	c, err := readConfig(t, newSessionConfig)
+	assert.NoError(t, err, "config should be valid")
+	assert.Equal(t, SessionConfig{
+		IdleExpiration:     DEFAULT_SESSION_IDLE_EXPIRATION,
+		AbsoluteExpiration: DEFAULT_SESSION_ABSOLUTE_EXPIRATION,
+		ReapInterval:       DEFAULT_SESSION_REAP_INTERVAL,
+	}, c)
+}
+
+func Test_NewSessionConfig_InvalidValues_ReturnsError(t *testing.T) {
+	tc := []struct {
+		name     string
+		idle     string
+		absolute string
+	}{
+		{name: "idle not a duration", idle: "ten", absolute: "1h"},
+		{name: "absolute not a duration", idle: "10m", absolute: "one"},
+		{name: "negative idle", idle: "-10m", absolute: "1h"},
+		{name: "idle exceeds absolute", idle: "2h", absolute: "1h"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HANGCOUNTS_SESSION_IDLE_EXPIRATION", tt.idle)
+			t.Setenv("HANGCOUNTS_SESSION_ABSOLUTE_EXPIRATION", tt.absolute)
+
+			_, err := readConfig(t, newSessionConfig)
+			assert.Error(t, err, "config should not be valid")
+		})
+	}
+}
+
+func Test_NewRetentionConfig_NothingSet_ReturnsDefaults(t *testing.T) {
+	t.Setenv("HANGCOUNTS_DELETED_HANGOUT_RETENTION", "")
+	t.Setenv("HANGCOUNTS_DELETED_INDIVIDUAL_RETENTION", "")
+	t.Setenv("HANGCOUNTS_PURGE_INTERVAL", "")
+
+	c, err := readConfig(t, newRetentionConfig)
+	assert.NoError(t, err, "config should be valid")
+	assert.Equal(t, RetentionConfig{
+		DeletedHangouts:    DEFAULT_DELETED_HANGOUT_RETENTION,
+		DeletedIndividuals: DEFAULT_DELETED_INDIVIDUAL_RETENTION,
+		PurgeInterval:      DEFAULT_PURGE_INTERVAL,
+	}, c)
+}
+
+func Test_NewRetentionConfig_InvalidRetention_ReturnsError(t *testing.T) {
+	t.Setenv("HANGCOUNTS_DELETED_HANGOUT_RETENTION", "-1h")
+	t.Setenv("HANGCOUNTS_DELETED_INDIVIDUAL_RETENTION", "")
+	t.Setenv("HANGCOUNTS_PURGE_INTERVAL", "")
+
+	_, err := readConfig(t, newRetentionConfig)
+	assert.Error(t, err, "config should not be valid")
+}
+
+func Test_NewPasswordConfig_NothingSet_ReturnsArgon2idDefaults(t *testing.T) {
+	t.Setenv("HANGCOUNTS_PASSWORD_ALGORITHM", "")
+	t.Setenv("HANGCOUNTS_PASSWORD_ARGON2_TIME", "")
+	t.Setenv("HANGCOUNTS_PASSWORD_ARGON2_MEMORY_KIB", "")
+	t.Setenv("HANGCOUNTS_PASSWORD_ARGON2_THREADS", "")
+	t.Setenv("HANGCOUNTS_PASSWORD_BCRYPT_COST", "")
+
+	c, err := readConfig(t, newPasswordConfig)
+	assert.NoError(t, err, "config should be valid")
+	assert.Equal(t, PasswordConfig{
+		Algorithm:       PASSWORD_ALGORITHM_ARGON2ID,
+		Argon2Time:      DEFAULT_ARGON2_TIME,
+		Argon2MemoryKiB: DEFAULT_ARGON2_MEMORY_KIB,
+		Argon2Threads:   DEFAULT_ARGON2_THREADS,
+		BcryptCost:      DEFAULT_BCRYPT_COST,
+	}, c)
+}
+
+func Test_NewPasswordConfig_InvalidValues_ReturnsError(t *testing.T) {
+	tc := []struct {
+		name  string
+		env   string
+		value string
+	}{
+		{name: "unknown algorithm", env: "HANGCOUNTS_PASSWORD_ALGORITHM", value: "md5"},
+		{name: "zero argon2 time", env: "HANGCOUNTS_PASSWORD_ARGON2_TIME", value: "0"},
+		{name: "argon2 threads overflow", env: "HANGCOUNTS_PASSWORD_ARGON2_THREADS", value: "256"},
+		{name: "bcrypt cost too low", env: "HANGCOUNTS_PASSWORD_BCRYPT_COST", value: "3"},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+
+			_, err := readConfig(t, newPasswordConfig)
+			assert.Error(t, err, "config should not be valid")
+		})
+	}
+}
+
+func Test_NewMagicLinkConfig_SmtpHostNotSet_ReturnsDisabledConfig(t *testing.T) {
+	t.Setenv("HANGCOUNTS_SMTP_HOST", "")
+
+	c, err := readConfig(t, newMagicLinkConfig)
+	assert.NoError(t, err, "config should be valid")
+	assert.False(t, c.Enabled(), "expected magic links to be disabled")
+}
+
+func Test_NewMagicLinkConfig_SmtpHostSet_RequiresSenderAndUrl(t *testing.T) {
+	t.Setenv("HANGCOUNTS_SMTP_HOST", "smtp.example.com")
+	t.Setenv("HANGCOUNTS_SMTP_FROM", "")
+	t.Setenv("HANGCOUNTS_MAGIC_LINK_URL", "")
+
+	_, err := readConfig(t, newMagicLinkConfig)
+	assert.Error(t, err, "config should not be valid")
+
+	t.Setenv("HANGCOUNTS_SMTP_FROM", "noreply@example.com")
+	t.Setenv("HANGCOUNTS_MAGIC_LINK_URL", "https://example.com/login/magic")
+	c, err := readConfig(t, newMagicLinkConfig)
+	assert.NoError(t, err, "config should be valid")
+	assert.True(t, c.Enabled(), "expected magic links to be enabled")
+	assert.Equal(t, DEFAULT_SMTP_PORT, c.SmtpPort)
+	assert.Equal(t, DEFAULT_MAGIC_LINK_TTL, c.TTL)
+}
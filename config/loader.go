@@ -0,0 +1,425 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The settings are read from several layers, each one overriding the
+// previous: the defaults, the config file, the environment and the
+// command-line flags.
+//
+// A setting has the same key in the file, where the dots are nested maps,
+// and as a flag, for example postgres.host and -postgres.host.
+type setting struct {
+	key string
+	env string
+	// an empty default makes the setting optional, unless it is required
+	def      string
+	required bool
+	// secrets are redacted when the configuration is printed, and can also be
+	// read from the file given by the setting with the _file suffix
+	secret bool
+	// boolean flags can be set without a value
+	boolean bool
+	usage   string
+}
+
+var settings = []setting{
+	{key: "env", env: "HANGCOUNTS_ENV", required: true, usage: `"dev" or "prod"`},
+
+	{key: "postgres.url", env: "HANGCOUNTS_POSTGRES_URL", secret: true, usage: "postgres:// connection url, the other postgres settings override its parts"},
+	{key: "postgres.url_file", env: "HANGCOUNTS_POSTGRES_URL_FILE", usage: "file containing the connection url, only read at startup"},
+	{key: "postgres.user", env: "HANGCOUNTS_POSTGRES_USER", usage: "database user, required without postgres.url"},
+	{key: "postgres.password", env: "HANGCOUNTS_POSTGRES_PASSWORD", secret: true, usage: "database password, required without postgres.url"},
+	{key: "postgres.password_file", env: "HANGCOUNTS_POSTGRES_PASSWORD_FILE", usage: "file containing the database password, reread when it changes"},
+	{key: "postgres.secret_reload_interval", env: "HANGCOUNTS_POSTGRES_SECRET_RELOAD_INTERVAL", def: DEFAULT_SECRET_RELOAD_INTERVAL.String(), usage: "how often the password file is checked for rotations"},
+	{key: "postgres.db", env: "HANGCOUNTS_POSTGRES_DB", usage: "database name, required without postgres.url"},
+	{key: "postgres.host", env: "HANGCOUNTS_POSTGRES_HOST", usage: "database host, required without postgres.url"},
+	{key: "postgres.port", env: "HANGCOUNTS_POSTGRES_PORT", usage: "database port, required without postgres.url"},
+	{key: "postgres.sslmode", env: "HANGCOUNTS_POSTGRES_SSLMODE", usage: `"disable", "allow", "prefer", "require", "verify-ca" or "verify-full", prefer by default`},
+	{key: "postgres.sslrootcert", env: "HANGCOUNTS_POSTGRES_SSLROOTCERT", usage: "CA certificates verifying the server"},
+	{key: "postgres.sslcert", env: "HANGCOUNTS_POSTGRES_SSLCERT", usage: "client certificate"},
+	{key: "postgres.sslkey", env: "HANGCOUNTS_POSTGRES_SSLKEY", usage: "key of the client certificate"},
+	{key: "postgres.application_name", env: "HANGCOUNTS_POSTGRES_APPLICATION_NAME", def: DEFAULT_APPLICATION_NAME, usage: "name of the app in pg_stat_activity"},
+	{key: "postgres.connect_timeout", env: "HANGCOUNTS_POSTGRES_CONNECT_TIMEOUT", usage: "timeout of a new connection, none by default"},
+	{key: "postgres.statement_timeout", env: "HANGCOUNTS_POSTGRES_STATEMENT_TIMEOUT", usage: "timeout of a statement, the server setting by default"},
+	{key: "postgres.max_conns", env: "HANGCOUNTS_POSTGRES_MAX_CONNS", usage: "size of the pool, the larger of 4 and the number of cpus by default"},
+	{key: "postgres.min_conns", env: "HANGCOUNTS_POSTGRES_MIN_CONNS", usage: "connections kept open when idle, none by default"},
+	{key: "postgres.max_conn_lifetime", env: "HANGCOUNTS_POSTGRES_MAX_CONN_LIFETIME", usage: "age after which a connection is closed, 1h by default"},
+	{key: "postgres.max_conn_idle_time", env: "HANGCOUNTS_POSTGRES_MAX_CONN_IDLE_TIME", usage: "idle time after which a connection is closed, 30m by default"},
+	{key: "postgres.health_check_period", env: "HANGCOUNTS_POSTGRES_HEALTH_CHECK_PERIOD", usage: "how often idle connections are checked, 1m by default"},
+	{key: "postgres.show_config", env: "HANGCOUNTS_POSTGRES_SHOW_CONFIG", def: "false", boolean: true, usage: "log the database configuration in dev"},
+	{key: "postgres.migrate_on_startup", env: "HANGCOUNTS_POSTGRES_MIGRATE_ON_STARTUP", def: "false", boolean: true, usage: "apply the pending migrations before serving"},
+
+	{key: "server.address", env: "HANGCOUNTS_HTTP_ADDRESS", def: DEFAULT_HTTP_ADDRESS, usage: "address the http server listens on"},
+	{key: "server.admin_address", env: "HANGCOUNTS_HTTP_ADMIN_ADDRESS", def: DEFAULT_HTTP_ADMIN_ADDRESS, usage: "address of the probes, the status page and the metrics, not to be exposed publicly"},
+	{key: "server.shutdown_delay", env: "HANGCOUNTS_HTTP_SHUTDOWN_DELAY", usage: "how long requests are still served once readiness fails on shutdown, none by default"},
+
+	{key: "session.idle_expiration", env: "HANGCOUNTS_SESSION_IDLE_EXPIRATION", def: DEFAULT_SESSION_IDLE_EXPIRATION.String(), usage: "how long an unused session stays valid"},
+	{key: "session.absolute_expiration", env: "HANGCOUNTS_SESSION_ABSOLUTE_EXPIRATION", def: DEFAULT_SESSION_ABSOLUTE_EXPIRATION.String(), usage: "how long a session stays valid at most"},
+	{key: "session.reap_interval", env: "HANGCOUNTS_SESSION_REAP_INTERVAL", def: DEFAULT_SESSION_REAP_INTERVAL.String(), usage: "how often expired sessions are deleted"},
+
+	{key: "password.algorithm", env: "HANGCOUNTS_PASSWORD_ALGORITHM", def: PASSWORD_ALGORITHM_ARGON2ID, usage: `"argon2id" or "bcrypt"`},
+	{key: "password.argon2_time", env: "HANGCOUNTS_PASSWORD_ARGON2_TIME", def: strconv.Itoa(DEFAULT_ARGON2_TIME), usage: "argon2id iterations"},
+	{key: "password.argon2_memory_kib", env: "HANGCOUNTS_PASSWORD_ARGON2_MEMORY_KIB", def: strconv.Itoa(DEFAULT_ARGON2_MEMORY_KIB), usage: "argon2id memory in KiB"},
+	{key: "password.argon2_threads", env: "HANGCOUNTS_PASSWORD_ARGON2_THREADS", def: strconv.Itoa(DEFAULT_ARGON2_THREADS), usage: "argon2id parallelism"},
+	{key: "password.bcrypt_cost", env: "HANGCOUNTS_PASSWORD_BCRYPT_COST", def: strconv.Itoa(DEFAULT_BCRYPT_COST), usage: "bcrypt cost"},
+
+	{key: "smtp.host", env: "HANGCOUNTS_SMTP_HOST", usage: "smtp host, magic links are disabled without it"},
+	{key: "smtp.port", env: "HANGCOUNTS_SMTP_PORT", def: strconv.Itoa(DEFAULT_SMTP_PORT), usage: "smtp port"},
+	{key: "smtp.username", env: "HANGCOUNTS_SMTP_USERNAME", usage: "smtp user"},
+	{key: "smtp.password", env: "HANGCOUNTS_SMTP_PASSWORD", secret: true, usage: "smtp password"},
+	{key: "smtp.password_file", env: "HANGCOUNTS_SMTP_PASSWORD_FILE", usage: "file containing the smtp password"},
+	{key: "smtp.from", env: "HANGCOUNTS_SMTP_FROM", usage: "sender of the magic links"},
+	{key: "magic_link.url", env: "HANGCOUNTS_MAGIC_LINK_URL", usage: "frontend page exchanging magic links"},
+	{key: "magic_link.ttl", env: "HANGCOUNTS_MAGIC_LINK_TTL", def: DEFAULT_MAGIC_LINK_TTL.String(), usage: "how long a magic link stays valid"},
+
+	{key: "retention.deleted_hangouts", env: "HANGCOUNTS_DELETED_HANGOUT_RETENTION", def: DEFAULT_DELETED_HANGOUT_RETENTION.String(), usage: "how long deleted hangouts can be restored"},
+	{key: "retention.deleted_individuals", env: "HANGCOUNTS_DELETED_INDIVIDUAL_RETENTION", def: DEFAULT_DELETED_INDIVIDUAL_RETENTION.String(), usage: "how long deleted accounts can be restored"},
+	{key: "retention.purge_interval", env: "HANGCOUNTS_PURGE_INTERVAL", def: DEFAULT_PURGE_INTERVAL.String(), usage: "how often expired records are purged"},
+
+	{key: "logging.level", env: "HANGCOUNTS_LOG_LEVEL", usage: `"debug", "info", "warn" or "error", debug in dev and info in prod by default`},
+	{key: "logging.format", env: "HANGCOUNTS_LOG_FORMAT", def: LOG_FORMAT_JSON, usage: `"json" or "text"`},
+}
+
+func lookupSetting(key string) setting {
+	for _, s := range settings {
+		if s.key == key {
+			return s
+		}
+	}
+	panic("unknown setting " + key)
+}
+
+const (
+	SOURCE_DEFAULT = "default"
+
+	CONFIG_FILE_ENV = "HANGCOUNTS_CONFIG_FILE"
+
+	REDACTED = "REDACTED"
+)
+
+// Problem is an invalid setting, and where its value came from.
+type Problem struct {
+	Setting string
+	Source  string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s (%s): %s", p.Setting, p.Source, p.Message)
+}
+
+// ValidationError reports every problem of the configuration instead of
+// stopping at the first one.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration, %d problem(s):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  ")
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// resolvedSetting is the value a setting ended up with.
+type resolvedSetting struct {
+	setting
+	value  string
+	source string
+}
+
+type loader struct {
+	fileName string
+	file     map[string]string
+	flags    map[string]string
+	getenv   func(string) string
+
+	printConfig bool
+	problems    []Problem
+}
+
+// newLoader parses the command-line arguments and reads the config file,
+// given either with -config or by HANGCOUNTS_CONFIG_FILE. The errors it
+// returns are not about the values of the settings, which are only checked
+// when they are read.
+func newLoader(args []string, getenv func(string) string) (*loader, error) {
+	l := &loader{
+		file:   make(map[string]string),
+		flags:  make(map[string]string),
+		getenv: getenv,
+	}
+
+	flags := flag.NewFlagSet("hangcounts", flag.ContinueOnError)
+	fileName := flags.String("config", getenv(CONFIG_FILE_ENV), "yaml config file, also read from "+CONFIG_FILE_ENV)
+	flags.BoolVar(&l.printConfig, "print-config", false, "print the configuration with its sources and exit")
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		set := func(value string) error {
+			l.flags[s.key] = value
+			return nil
+		}
+		if s.boolean {
+			flags.BoolFunc(s.key, usage, set)
+		} else {
+			flags.Func(s.key, usage, set)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("could not parse flags: %w", err)
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	if *fileName != "" {
+		if err := l.readFile(*fileName); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// readFile only reads yaml files, any other format would be parsed as yaml
+// and fail in confusing ways.
+func (l *loader) readFile(name string) error {
+	l.fileName = name
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+	default:
+		l.problem("config", l.fileSource(), "must be a yaml file, with the .yaml or .yml extension")
+		return nil
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("could not parse config file %s: %w", name, err)
+	}
+
+	l.flatten("", doc)
+	// sorted to report the problems in the same order every time
+	for _, key := range slices.Sorted(maps.Keys(l.file)) {
+		if !l.known(key) {
+			l.problem(key, l.fileSource(), "unknown setting")
+		}
+	}
+	return nil
+}
+
+// flatten joins the nested keys of the file with dots.
+func (l *loader) flatten(prefix string, doc map[string]any) {
+	for k, v := range doc {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch value := v.(type) {
+		case map[string]any:
+			l.flatten(key, value)
+		case []any:
+			l.problem(key, l.fileSource(), "lists are not supported")
+		case nil:
+			// an empty value in yaml, same as not setting it
+		default:
+			l.file[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+func (l *loader) known(key string) bool {
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *loader) fileSource() string {
+	return "file " + l.fileName
+}
+
+// resolve returns the value of the setting from the layer with the highest
+// precedence.
+func (l *loader) resolve(s setting) resolvedSetting {
+	if v, ok := l.flags[s.key]; ok {
+		return resolvedSetting{setting: s, value: v, source: "flag -" + s.key}
+	}
+	if v := l.getenv(s.env); v != "" {
+		return resolvedSetting{setting: s, value: v, source: "env " + s.env}
+	}
+	if v, ok := l.file[s.key]; ok {
+		return resolvedSetting{setting: s, value: v, source: l.fileSource()}
+	}
+	return resolvedSetting{setting: s, value: s.def, source: SOURCE_DEFAULT}
+}
+
+// require reports the settings without a value.
+func (l *loader) require(reason string, keys ...string) {
+	for _, key := range keys {
+		if r := l.resolve(lookupSetting(key)); r.value == "" {
+			l.problem(key, unsetSource(r.setting), "is required %s", reason)
+		}
+	}
+}
+
+// unsetSource tells where a missing setting can be set.
+func unsetSource(s setting) string {
+	return fmt.Sprintf("unset, use env %s or flag -%s", s.env, s.key)
+}
+
+func (l *loader) problem(key, source, format string, args ...any) {
+	l.problems = append(l.problems, Problem{
+		Setting: key,
+		Source:  source,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// get returns the raw value of the setting, reporting it if it is required
+// but empty.
+func (l *loader) get(key string) resolvedSetting {
+	r := l.resolve(lookupSetting(key))
+	if r.required && r.value == "" {
+		l.problem(key, unsetSource(r.setting), "is required")
+	}
+	return r
+}
+
+// SECRET_FILE_SUFFIX names the setting holding the file of a secret.
+const SECRET_FILE_SUFFIX = "_file"
+
+// secret reads the setting either directly or from its file, which is returned
+// to be reread when the secret is rotated. Setting both is a problem, since
+// it is unclear which one should win.
+func (l *loader) secret(key string) (Secret, string) {
+	r := l.resolve(lookupSetting(key))
+	file := l.resolve(lookupSetting(key + SECRET_FILE_SUFFIX))
+
+	switch {
+	case r.value != "" && file.value != "":
+		l.problem(key, r.source, "cannot be set together with %s (%s)", file.key, file.source)
+		return Secret{}, ""
+	case file.value != "":
+		secret, err := ReadSecretFile(file.value)
+		if err != nil {
+			l.problem(file.key, file.source, "%v", err)
+			return Secret{}, ""
+		}
+		return secret, file.value
+	case r.required && r.value == "":
+		l.problem(key, unsetSource(r.setting), "is required, directly or with %s", file.key)
+	}
+	return NewSecret(r.value), ""
+}
+
+func (l *loader) string(key string) string {
+	return l.get(key).value
+}
+
+// duration must be positive.
+func (l *loader) duration(key string) time.Duration {
+	r := l.get(key)
+	if r.value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(r.value)
+	if err != nil {
+		l.problem(key, r.source, "invalid duration %s", strconv.Quote(r.value))
+		return 0
+	}
+	if d <= 0 {
+		l.problem(key, r.source, "must be positive")
+		return 0
+	}
+	return d
+}
+
+// uint must be positive.
+func (l *loader) uint(key string, bitSize int) uint64 {
+	r := l.get(key)
+	if r.value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(r.value, 10, bitSize)
+	if err != nil {
+		l.problem(key, r.source, "must be a number of at most %d bits", bitSize)
+		return 0
+	}
+	if n == 0 {
+		l.problem(key, r.source, "must be positive")
+		return 0
+	}
+	return n
+}
+
+func (l *loader) bool(key string) bool {
+	r := l.get(key)
+	if r.value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(r.value)
+	if err != nil {
+		l.problem(key, r.source, "invalid boolean %s", strconv.Quote(r.value))
+		return false
+	}
+	return b
+}
+
+// err returns the problems found while reading the settings.
+func (l *loader) err() error {
+	if len(l.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: l.problems}
+}
+
+// report resolves every setting, to show where each value comes from.
+func (l *loader) report() []resolvedSetting {
+	resolved := make([]resolvedSetting, 0, len(settings))
+	for _, s := range settings {
+		resolved = append(resolved, l.resolve(s))
+	}
+	return resolved
+}
+
+// writeSettings prints one setting per line, with the secrets redacted.
+func writeSettings(w io.Writer, resolved []resolvedSetting, problems []Problem) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range resolved {
+		value := r.value
+		if r.secret && value != "" {
+			value = REDACTED
+		}
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\n", r.key, value, r.source)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("could not print config: %w", err)
+	}
+	if len(problems) > 0 {
+		if _, err := fmt.Fprintf(w, "\n%s\n", (&ValidationError{Problems: problems}).Error()); err != nil {
+			return fmt.Errorf("could not print config: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "hangcounts.yaml")
+	require.NoError(t, os.WriteFile(name, []byte(content), 0o600), "expected the config file to be written")
+	return name
+}
+
+func envFrom(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func Test_Loader_Resolve_FlagsOverrideEnvOverridesFile(t *testing.T) {
+	name := writeConfigFile(t, `
+postgres:
+  host: file-host
+  user: file-user
+  db: file-db
+`)
+	env := envFrom(map[string]string{
+		"HANGCOUNTS_POSTGRES_HOST": "env-host",
+		"HANGCOUNTS_POSTGRES_USER": "env-user",
+	})
+
+	l, err := newLoader([]string{"-config", name, "-postgres.host", "flag-host"}, env)
+	require.NoError(t, err, "expected the loader to be created")
+
+	assert.Equal(t, "flag-host", l.string("postgres.host"), "expected the flag to win")
+	assert.Equal(t, "env-user", l.string("postgres.user"), "expected the env to override the file")
+	assert.Equal(t, "file-db", l.string("postgres.db"), "expected the value from the file")
+	assert.Equal(t, DEFAULT_HTTP_ADDRESS, l.string("server.address"), "expected the default")
+}
+
+func Test_Loader_ConfigFileFromEnv_IsRead(t *testing.T) {
+	name := writeConfigFile(t, "server:\n  address: 127.0.0.1:9000\n")
+
+	l, err := newLoader(nil, envFrom(map[string]string{CONFIG_FILE_ENV: name}))
+	require.NoError(t, err, "expected the loader to be created")
+	assert.Equal(t, "127.0.0.1:9000", l.string("server.address"), "expected the value from the file")
+}
+
+func Test_Loader_ConfigFileNotYaml_ReportsTheFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "hangcounts.toml")
+	require.NoError(t, os.WriteFile(name, []byte("[server]\naddress = \"127.0.0.1:9000\"\n"), 0o600), "expected the config file to be written")
+
+	l, err := newLoader([]string{"-config", name}, envFrom(nil))
+	require.NoError(t, err, "expected the loader to be created")
+
+	assert.Equal(t, DEFAULT_HTTP_ADDRESS, l.string("server.address"), "expected the file to not be read")
+	var validationErr *ValidationError
+	require.True(t, errors.As(l.err(), &validationErr), "expected a validation error")
+	assert.Equal(t, []Problem{
+		{Setting: "config", Source: "file " + name, Message: "must be a yaml file, with the .yaml or .yml extension"},
+	}, validationErr.Problems)
+}
+
+func Test_Loader_BooleanFlagWithoutValue_IsTrue(t *testing.T) {
+	l, err := newLoader([]string{"-postgres.migrate_on_startup"}, envFrom(nil))
+	require.NoError(t, err, "expected the loader to be created")
+	assert.True(t, l.bool("postgres.migrate_on_startup"), "expected the flag to enable the setting")
+}
+
+func Test_Loader_InvalidValues_ReportsEveryProblemWithItsSource(t *testing.T) {
+	name := writeConfigFile(t, `
+session:
+  idle_expiration: ten
+unknown: 1
+`)
+	env := envFrom(map[string]string{"HANGCOUNTS_SESSION_REAP_INTERVAL": "-1m"})
+
+	l, err := newLoader([]string{"-config", name, "-password.bcrypt_cost", "40"}, env)
+	require.NoError(t, err, "expected the loader to be created")
+	newSessionConfig(l)
+	newPasswordConfig(l)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(l.err(), &validationErr), "expected a validation error")
+	assert.ElementsMatch(t, []Problem{
+		{Setting: "unknown", Source: "file " + name, Message: "unknown setting"},
+		{Setting: "session.idle_expiration", Source: "file " + name, Message: `invalid duration "ten"`},
+		{Setting: "session.reap_interval", Source: "env HANGCOUNTS_SESSION_REAP_INTERVAL", Message: "must be positive"},
+		{Setting: "password.bcrypt_cost", Source: "flag -password.bcrypt_cost", Message: "must be between 4 and 31"},
+	}, validationErr.Problems)
+}
+
+func Test_NewAppConfig_MissingRequiredSettings_ListsEachOfThem(t *testing.T) {
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+	t.Setenv(CONFIG_FILE_ENV, "")
+
+	_, err := NewAppConfig(nil)
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr), "expected a validation error")
+
+	var missing []string
+	for _, p := range validationErr.Problems {
+		missing = append(missing, p.Setting)
+	}
+	assert.ElementsMatch(t, []string{"env", "postgres.user", "postgres.password", "postgres.db", "postgres.host", "postgres.port"}, missing, "expected every required setting to be reported")
+}
+
+func Test_NewAppConfig_PrintConfig_RedactsSecrets(t *testing.T) {
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+	t.Setenv(CONFIG_FILE_ENV, "")
+	t.Setenv("HANGCOUNTS_POSTGRES_PASSWORD", "hunter2")
+
+	c, err := NewAppConfig([]string{"-print-config"})
+	require.NoError(t, err, "expected an invalid config to be printed")
+	require.True(t, c.PrintConfig, "expected print config to be set")
+
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteSettings(&buf), "expected the settings to be printed")
+	assert.NotContains(t, buf.String(), "hunter2", "expected the password to be redacted")
+	assert.Contains(t, buf.String(), REDACTED, "expected the password to be redacted")
+	assert.Contains(t, buf.String(), "env HANGCOUNTS_POSTGRES_PASSWORD", "expected the source of the password")
+	assert.Contains(t, buf.String(), "postgres.user (unset, use env HANGCOUNTS_POSTGRES_USER or flag -postgres.user): is required", "expected the problems to be printed")
+}
+
+func Test_NewLoggingConfig_LevelNotSet_DependsOnEnv(t *testing.T) {
+	l, err := newLoader(nil, envFrom(nil))
+	require.NoError(t, err, "expected the loader to be created")
+
+	assert.Equal(t, slog.LevelDebug, newLoggingConfig(l, "dev").Level, "expected debug logs in dev")
+	assert.Equal(t, slog.LevelInfo, newLoggingConfig(l, "prod").Level, "expected info logs in prod")
+	assert.NoError(t, l.err(), "config should be valid")
+}
+
+func Test_NewLoggingConfig_InvalidValues_ReturnsError(t *testing.T) {
+	l, err := newLoader([]string{"-logging.level", "loud", "-logging.format", "xml"}, envFrom(nil))
+	require.NoError(t, err, "expected the loader to be created")
+
+	newLoggingConfig(l, "prod")
+	var validationErr *ValidationError
+	require.True(t, errors.As(l.err(), &validationErr), "expected a validation error")
+	assert.Len(t, validationErr.Problems, 2, "expected both settings to be reported")
+}
+
+func Test_Loader_Secret_ReadsPasswordFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(name, []byte("hunter2\n"), 0o600), "expected the secret file to be written")
+
+	l, err := newLoader(nil, envFrom(map[string]string{"HANGCOUNTS_POSTGRES_PASSWORD_FILE": name}))
+	require.NoError(t, err, "expected the loader to be created")
+
+	password, file := l.secret("postgres.password")
+	assert.NoError(t, l.err(), "config should be valid")
+	assert.Equal(t, "hunter2", password.Reveal(), "expected the password from the file")
+	assert.Equal(t, name, file, "expected the file to be kept for reloads")
+}
+
+func Test_Loader_Secret_SetDirectlyAndFromFile_ReturnsError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(name, []byte("hunter2"), 0o600), "expected the secret file to be written")
+
+	l, err := newLoader([]string{"-postgres.password_file", name}, envFrom(map[string]string{"HANGCOUNTS_POSTGRES_PASSWORD": "other"}))
+	require.NoError(t, err, "expected the loader to be created")
+
+	l.secret("postgres.password")
+	var validationErr *ValidationError
+	require.True(t, errors.As(l.err(), &validationErr), "expected a validation error")
+	assert.Equal(t, "postgres.password", validationErr.Problems[0].Setting, "expected the conflict to be reported")
+}
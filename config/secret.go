@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var ErrEmptySecret = errors.New("secret file is empty")
+
+// Secret is a value that must never end up in the logs or the output. It is
+// redacted everywhere it is formatted, and only Reveal returns the value.
+type Secret struct {
+	value string
+}
+
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+// Reveal returns the value, only call it where the secret is used.
+func (s Secret) Reveal() string {
+	return s.value
+}
+
+func (s Secret) IsEmpty() bool {
+	return s.value == ""
+}
+
+func (s Secret) String() string {
+	return REDACTED
+}
+
+func (s Secret) GoString() string {
+	return REDACTED
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(REDACTED)
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(REDACTED), nil
+}
+
+// ReadSecretFile reads a secret mounted as a file, such as Docker and
+// Kubernetes secrets. The trailing newline left by editors is not part of the
+// secret.
+func ReadSecretFile(name string) (Secret, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return Secret{}, fmt.Errorf("could not read secret file: %w", err)
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return Secret{}, fmt.Errorf("%w: %s", ErrEmptySecret, name)
+	}
+	return NewSecret(value), nil
+}
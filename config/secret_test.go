@@ -0,0 +1,52 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Secret_Formatting_AlwaysRedacts(t *testing.T) {
+	c := PostgresConfig{User: "user", Password: NewSecret("hunter2")}
+
+	var logs bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logs, nil)).Info("config", slog.Any("config", c), slog.Any("password", c.Password))
+	encoded, err := json.Marshal(c)
+	require.NoError(t, err, "expected the config to be encoded")
+
+	for name, out := range map[string]string{
+		"%s":   fmt.Sprintf("%s", c.Password),
+		"%v":   fmt.Sprintf("%v", c),
+		"%+v":  fmt.Sprintf("%+v", c),
+		"%#v":  fmt.Sprintf("%#v", c),
+		"slog": logs.String(),
+		"json": string(encoded),
+	} {
+		assert.NotContains(t, out, "hunter2", "expected the secret to be redacted with %s", name)
+	}
+	assert.Equal(t, "hunter2", c.Password.Reveal(), "expected reveal to return the value")
+}
+
+func Test_ReadSecretFile_TrailingNewline_IsTrimmed(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(name, []byte("hunter2\n"), 0o600), "expected the secret file to be written")
+
+	s, err := ReadSecretFile(name)
+	require.NoError(t, err, "expected the secret file to be read")
+	assert.Equal(t, "hunter2", s.Reveal(), "expected the newline to be trimmed")
+}
+
+func Test_ReadSecretFile_EmptyFile_ReturnsError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(name, []byte("\n"), 0o600), "expected the secret file to be written")
+
+	_, err := ReadSecretFile(name)
+	assert.ErrorIs(t, err, ErrEmptySecret)
+}
@@ -0,0 +1,278 @@
+package aggregate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/google/uuid"
+)
+
+// Explicit Hangout validation errors
+type HangoutValidationError error
+
+var ErrNegativeMinutes = errors.New("duration cannot be negative")
+var ErrEmptyLocation = errors.New("location cannot be empty")
+var ErrMissingDate = errors.New("date is required")
+var ErrEmptyCreator = errors.New("hangout creator cannot be empty")
+var ErrEmptyParticipant = errors.New("participant username cannot be empty")
+var ErrTooManyParticipants = fmt.Errorf("a hangout can have at most %d participants", MAX_HANGOUT_PARTICIPANTS)
+
+var ErrNotHangoutCreator = errors.New("only the creator of the hangout can do this")
+var ErrNotHangoutParticipant = errors.New("only the participants of the hangout can do this")
+var ErrCreatorNotRemovable = errors.New("the creator cannot leave or be removed from the hangout")
+
+// counting the creator
+const MAX_HANGOUT_PARTICIPANTS = 50
+
+// HangoutStorage contains the storage operations the hangout aggregate relies
+// on.
+type HangoutStorage interface {
+	StoreHangoutOfIndividuals(context.Context, model.Hangout) error
+	GetHangout(context.Context, model.HangoutId) (model.Hangout, error)
+	UpdateHangoutDetails(context.Context, model.HangoutId, model.HangoutDetails) error
+	UpdateHangoutParticipants(ctx context.Context, hangoutId model.HangoutId, expected, participants []model.IndividualId) error
+	GetHangoutCreator(context.Context, model.HangoutId) (model.IndividualId, error)
+	IsHangoutParticipant(context.Context, model.HangoutId, model.IndividualId) (bool, error)
+	MarkHangoutAsDeleted(context.Context, model.HangoutId) error
+	RestoreHangout(context.Context, model.HangoutId) error
+	RemoveHangoutParticipant(context.Context, model.HangoutId, model.IndividualId) error
+}
+
+var _ HangoutStorage = (storage.AppStorage)(nil)
+
+// HangoutAgg enforces the rules of the hangouts. Hangouts whose creator erased
+// their account are managed by all of their participants, each of them having
+// the rights of the creator.
+type HangoutAgg struct {
+	model.Hangout
+
+	storage HangoutStorage
+}
+
+func NewHangoutAgg(store HangoutStorage) *HangoutAgg {
+	return &HangoutAgg{
+		storage: store,
+	}
+}
+
+func validateDetails(details model.HangoutDetails) error {
+	var errs error
+
+	if strings.TrimSpace(details.Location) == "" {
+		errs = errors.Join(errs, ErrEmptyLocation)
+	}
+	if details.Duration < 0 {
+		errs = errors.Join(errs, ErrNegativeMinutes)
+	}
+	if details.Date.IsZero() {
+		errs = errors.Join(errs, ErrMissingDate)
+	}
+
+	return errs
+}
+
+// participantsWithCreator deduplicates the participants and puts the creator
+// first. Hangouts whose creator erased their account have no creator to add.
+func participantsWithCreator(createdBy model.IndividualId, participants []model.IndividualId) ([]model.IndividualId, error) {
+	var errs error
+
+	seen := make(map[model.IndividualId]bool, len(participants)+1)
+	unique := make([]model.IndividualId, 0, len(participants)+1)
+	if createdBy != "" {
+		unique = append(unique, createdBy)
+		seen[createdBy] = true
+	}
+
+	emptyParticipant := false
+	for _, p := range participants {
+		if p == "" {
+			emptyParticipant = true
+			continue
+		}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		unique = append(unique, p)
+	}
+	if emptyParticipant {
+		errs = errors.Join(errs, ErrEmptyParticipant)
+	}
+	if len(unique) > MAX_HANGOUT_PARTICIPANTS {
+		errs = errors.Join(errs, ErrTooManyParticipants)
+	}
+
+	return unique, errs
+}
+
+// NewHangout validates a new hangout and returns all the validation errors at
+// once. Participants are deduplicated and the creator is added to them if
+// missing.
+func NewHangout(details model.HangoutDetails, createdBy model.IndividualId, participants []model.IndividualId) (model.Hangout, error) {
+	errs := validateDetails(details)
+	if createdBy == "" {
+		errs = errors.Join(errs, ErrEmptyCreator)
+	}
+	individuals, err := participantsWithCreator(createdBy, participants)
+	if err != nil {
+		errs = errors.Join(errs, err)
+	}
+	if errs != nil {
+		return model.Hangout{}, HangoutValidationError(errs)
+	}
+
+	return model.Hangout{
+		PublicId:       model.HangoutId(uuid.New()),
+		HangoutDetails: details,
+		CreatedBy:      createdBy,
+		Individuals:    individuals,
+	}, nil
+}
+
+func (agg *HangoutAgg) CreateHangout(ctx context.Context, details model.HangoutDetails, createdBy model.IndividualId, participants []model.IndividualId) error {
+	hangout, err := NewHangout(details, createdBy, participants)
+	// eager return to avoid database call
+	if err != nil {
+		return err
+	}
+
+	agg.Hangout = hangout
+	return agg.storage.StoreHangoutOfIndividuals(ctx, agg.Hangout)
+}
+
+// UpdateDetails validates the details and replaces the ones of the hangout,
+// returning all the validation errors at once. Only the creator can update
+// them.
+func (agg *HangoutAgg) UpdateDetails(ctx context.Context, hangoutId model.HangoutId, by model.IndividualId, details model.HangoutDetails) error {
+	if errs := validateDetails(details); errs != nil {
+		return HangoutValidationError(errs)
+	}
+	if err := agg.checkCreator(ctx, hangoutId, by); err != nil {
+		return err
+	}
+	return agg.storage.UpdateHangoutDetails(ctx, hangoutId, details)
+}
+
+// UpdateParticipants replaces the participants of the hangout. They are
+// deduplicated, and the creator is kept as the first of them. Only the
+// creator and the participants can change them, and every participant left
+// out is removed as by RemoveParticipant, so only the creator can leave out
+// someone else, or any participant once the creator erased their account.
+func (agg *HangoutAgg) UpdateParticipants(ctx context.Context, hangoutId model.HangoutId, by model.IndividualId, participants []model.IndividualId) error {
+	hangout, err := agg.storage.GetHangout(ctx, hangoutId)
+	if err != nil {
+		return err
+	}
+	if by != hangout.CreatedBy && !slices.Contains(hangout.Individuals, by) {
+		if hangout.CreatedBy == "" {
+			return ErrNotHangoutParticipant
+		}
+		return ErrNotHangoutCreator
+	}
+	individuals, errs := participantsWithCreator(hangout.CreatedBy, participants)
+	if errs != nil {
+		return HangoutValidationError(errs)
+	}
+	// any participant can leave out the others of an orphaned hangout
+	if hangout.CreatedBy != "" {
+		for _, current := range hangout.Individuals {
+			if slices.Contains(individuals, current) {
+				continue
+			}
+			if err := checkParticipantRemoval(hangout.CreatedBy, by, current); err != nil {
+				return err
+			}
+		}
+	}
+	// the removals were checked against these participants, the storage
+	// refuses the update if they changed since
+	return agg.storage.UpdateHangoutParticipants(ctx, hangoutId, hangout.Individuals, individuals)
+}
+
+// checkHangoutCreator makes sure only the creator of a hangout changes its
+// details, deletes or restores it.
+func checkHangoutCreator(creator, by model.IndividualId) error {
+	if creator != by {
+		return ErrNotHangoutCreator
+	}
+	return nil
+}
+
+func (agg *HangoutAgg) checkCreator(ctx context.Context, hangoutId model.HangoutId, by model.IndividualId) error {
+	creator, err := agg.storage.GetHangoutCreator(ctx, hangoutId)
+	if errors.Is(err, storage.ErrHangoutCreatorNotFound) {
+		return agg.checkParticipant(ctx, hangoutId, by)
+	}
+	if err != nil {
+		return err
+	}
+	return checkHangoutCreator(creator, by)
+}
+
+func (agg *HangoutAgg) checkParticipant(ctx context.Context, hangoutId model.HangoutId, by model.IndividualId) error {
+	participant, err := agg.storage.IsHangoutParticipant(ctx, hangoutId, by)
+	if err != nil {
+		return err
+	}
+	if !participant {
+		return ErrNotHangoutParticipant
+	}
+	return nil
+}
+
+// DeleteHangout soft-deletes the hangout, only its creator can delete it.
+func (agg *HangoutAgg) DeleteHangout(ctx context.Context, hangoutId model.HangoutId, by model.IndividualId) error {
+	if err := agg.checkCreator(ctx, hangoutId, by); err != nil {
+		return err
+	}
+	return agg.storage.MarkHangoutAsDeleted(ctx, hangoutId)
+}
+
+// RestoreHangout undoes DeleteHangout, as long as the hangout was not purged
+// yet. Only its creator can restore it.
+func (agg *HangoutAgg) RestoreHangout(ctx context.Context, hangoutId model.HangoutId, by model.IndividualId) error {
+	if err := agg.checkCreator(ctx, hangoutId, by); err != nil {
+		return err
+	}
+	return agg.storage.RestoreHangout(ctx, hangoutId)
+}
+
+// checkParticipantRemoval makes sure participant leaves the hangout on their
+// own, or is removed by the creator. The creator always stays.
+func checkParticipantRemoval(creator, by, participant model.IndividualId) error {
+	if participant == creator {
+		return ErrCreatorNotRemovable
+	}
+	if by != participant && by != creator {
+		return ErrNotHangoutCreator
+	}
+	return nil
+}
+
+// RemoveParticipant takes participant out of the hangout, either because they
+// leave it or because the creator removes them. Once the creator erased their
+// account, any participant can remove the others. The hangout is kept in the
+// history of the other participants.
+func (agg *HangoutAgg) RemoveParticipant(ctx context.Context, hangoutId model.HangoutId, by, participant model.IndividualId) error {
+	creator, err := agg.storage.GetHangoutCreator(ctx, hangoutId)
+	switch {
+	case errors.Is(err, storage.ErrHangoutCreatorNotFound):
+		if by != participant {
+			if err := agg.checkParticipant(ctx, hangoutId, by); err != nil {
+				return err
+			}
+		}
+	case err != nil:
+		return err
+	default:
+		if err := checkParticipantRemoval(creator, by, participant); err != nil {
+			return err
+		}
+	}
+	return agg.storage.RemoveHangoutParticipant(ctx, hangoutId, participant)
+}
@@ -0,0 +1,358 @@
+package aggregate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingStorage only implements the hangout operations used by the tests,
+// the other methods panic if called.
+type recordingStorage struct {
+	HangoutStorage
+	hangouts     []model.Hangout
+	creator      model.IndividualId
+	current      []model.IndividualId
+	deleted      []model.HangoutId
+	restored     []model.HangoutId
+	left         []model.IndividualId
+	details      []model.HangoutDetails
+	participants [][]model.IndividualId
+}
+
+func (r *recordingStorage) GetHangout(_ context.Context, hangoutId model.HangoutId) (model.Hangout, error) {
+	return model.Hangout{PublicId: hangoutId, CreatedBy: r.creator, Individuals: r.current}, nil
+}
+
+func (r *recordingStorage) UpdateHangoutDetails(_ context.Context, _ model.HangoutId, details model.HangoutDetails) error {
+	r.details = append(r.details, details)
+	return nil
+}
+
+func (r *recordingStorage) UpdateHangoutParticipants(_ context.Context, _ model.HangoutId, expected, participants []model.IndividualId) error {
+	if !slices.Equal(expected, r.current) {
+		return storage.ErrHangoutParticipantsChanged
+	}
+	r.participants = append(r.participants, participants)
+	return nil
+}
+
+// GetHangoutCreator treats an empty creator as erased.
+func (r *recordingStorage) GetHangoutCreator(_ context.Context, _ model.HangoutId) (model.IndividualId, error) {
+	if r.creator == "" {
+		return "", storage.ErrHangoutCreatorNotFound
+	}
+	return r.creator, nil
+}
+
+func (r *recordingStorage) IsHangoutParticipant(_ context.Context, _ model.HangoutId, individual model.IndividualId) (bool, error) {
+	return slices.Contains(r.current, individual), nil
+}
+
+func (r *recordingStorage) MarkHangoutAsDeleted(_ context.Context, hangoutId model.HangoutId) error {
+	r.deleted = append(r.deleted, hangoutId)
+	return nil
+}
+
+func (r *recordingStorage) RemoveHangoutParticipant(_ context.Context, _ model.HangoutId, participant model.IndividualId) error {
+	r.left = append(r.left, participant)
+	return nil
+}
+
+func (r *recordingStorage) RestoreHangout(_ context.Context, hangoutId model.HangoutId) error {
+	r.restored = append(r.restored, hangoutId)
+	return nil
+}
+
+func (r *recordingStorage) StoreHangoutOfIndividuals(_ context.Context, hangout model.Hangout) error {
+	r.hangouts = append(r.hangouts, hangout)
+	return nil
+}
+
+func validDetails() model.HangoutDetails {
+	return model.HangoutDetails{
+		Location: "location",
+		Duration: 10,
+		Date:     time.Date(2025, 3, 16, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func Test_NewHangout_ValidHangout_IncludesCreatorOnce(t *testing.T) {
+	tc := []struct {
+		name         string
+		participants []model.IndividualId
+		want         []model.IndividualId
+	}{
+		{name: "no participants", participants: nil, want: []model.IndividualId{"creator"}},
+		{name: "creator missing", participants: []model.IndividualId{"other"}, want: []model.IndividualId{"creator", "other"}},
+		{name: "creator last", participants: []model.IndividualId{"other", "creator"}, want: []model.IndividualId{"creator", "other"}},
+		{name: "duplicates", participants: []model.IndividualId{"other", "creator", "other"}, want: []model.IndividualId{"creator", "other"}},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hangout, err := NewHangout(validDetails(), "creator", tt.participants)
+			require.NoError(t, err, "valid hangout should not return an error")
+			assert.Equal(t, tt.want, hangout.Individuals, "expected deduplicated participants starting with the creator")
+			assert.Equal(t, model.IndividualId("creator"), hangout.CreatedBy)
+			assert.NotEqual(t, model.HangoutId{}, hangout.PublicId, "expected a public id to be generated")
+		})
+	}
+}
+
+func Test_NewHangout_InvalidFields_ReturnsAllErrors(t *testing.T) {
+	details := model.HangoutDetails{Location: "  ", Duration: -1}
+
+	_, err := NewHangout(details, "", []model.IndividualId{""})
+	assert.ErrorIs(t, err, ErrEmptyLocation)
+	assert.ErrorIs(t, err, ErrNegativeMinutes)
+	assert.ErrorIs(t, err, ErrMissingDate)
+	assert.ErrorIs(t, err, ErrEmptyCreator)
+	assert.ErrorIs(t, err, ErrEmptyParticipant)
+}
+
+func Test_NewHangout_TooManyParticipants_ReturnsError(t *testing.T) {
+	participants := make([]model.IndividualId, 0, MAX_HANGOUT_PARTICIPANTS)
+	for i := range MAX_HANGOUT_PARTICIPANTS {
+		participants = append(participants, model.IndividualId(fmt.Sprintf("participant%d", i)))
+	}
+
+	_, err := NewHangout(validDetails(), "creator", participants)
+	assert.ErrorIs(t, err, ErrTooManyParticipants, "expected the creator to count towards the limit")
+
+	_, err = NewHangout(validDetails(), participants[0], participants)
+	assert.NoError(t, err, "expected a creator listed as participant to not count twice")
+}
+
+func Test_CreateHangout_InvalidHangout_IsNotStored(t *testing.T) {
+	store := &recordingStorage{}
+	agg := NewHangoutAgg(store)
+
+	err := agg.CreateHangout(t.Context(), model.HangoutDetails{}, "creator", nil)
+	assert.Error(t, err, "invalid hangout should return an error")
+	assert.Empty(t, store.hangouts, "expected invalid hangout to not be stored")
+}
+
+func Test_CreateHangout_ValidHangout_IsStored(t *testing.T) {
+	store := &recordingStorage{}
+	agg := NewHangoutAgg(store)
+
+	err := agg.CreateHangout(t.Context(), validDetails(), "creator", []model.IndividualId{"other"})
+	require.NoError(t, err, "valid hangout should not return an error")
+	require.Len(t, store.hangouts, 1, "expected hangout to be stored")
+	assert.Equal(t, agg.Hangout, store.hangouts[0], "expected the aggregate to hold the stored hangout")
+}
+
+func Test_HangoutAgg_DeleteHangout_ByCreator_DeletesHangout(t *testing.T) {
+	store := &recordingStorage{creator: "creator"}
+	id := model.HangoutId{1}
+
+	err := NewHangoutAgg(store).DeleteHangout(t.Context(), id, "creator")
+	require.NoError(t, err, "expected the creator to be able to delete the hangout")
+	assert.Equal(t, []model.HangoutId{id}, store.deleted)
+
+	err = NewHangoutAgg(store).RestoreHangout(t.Context(), id, "creator")
+	require.NoError(t, err, "expected the creator to be able to restore the hangout")
+	assert.Equal(t, []model.HangoutId{id}, store.restored)
+}
+
+func Test_HangoutAgg_DeleteHangout_ByParticipant_ReturnsError(t *testing.T) {
+	store := &recordingStorage{creator: "creator"}
+
+	err := NewHangoutAgg(store).DeleteHangout(t.Context(), model.HangoutId{1}, "participant")
+	assert.ErrorIs(t, err, ErrNotHangoutCreator)
+	err = NewHangoutAgg(store).RestoreHangout(t.Context(), model.HangoutId{1}, "participant")
+	assert.ErrorIs(t, err, ErrNotHangoutCreator)
+	assert.Empty(t, store.deleted, "expected the storage to not be called")
+	assert.Empty(t, store.restored, "expected the storage to not be called")
+}
+
+func Test_checkParticipantRemoval(t *testing.T) {
+	tc := []struct {
+		name        string
+		by          model.IndividualId
+		participant model.IndividualId
+		want        error
+	}{
+		{name: "participant leaves", by: "participant", participant: "participant", want: nil},
+		{name: "creator removes participant", by: "creator", participant: "participant", want: nil},
+		{name: "participant removes another", by: "other", participant: "participant", want: ErrNotHangoutCreator},
+		{name: "creator leaves", by: "creator", participant: "creator", want: ErrCreatorNotRemovable},
+		{name: "participant removes creator", by: "participant", participant: "creator", want: ErrCreatorNotRemovable},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := checkParticipantRemoval("creator", tt.by, tt.participant)
+			if tt.want == nil {
+				assert.NoError(t, err, "expected removal to be allowed")
+				return
+			}
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func Test_HangoutAgg_RemoveParticipant_ParticipantLeaves(t *testing.T) {
+	store := &recordingStorage{creator: "creator"}
+
+	err := NewHangoutAgg(store).RemoveParticipant(t.Context(), model.HangoutId{1}, "participant", "participant")
+	require.NoError(t, err, "expected participant to be able to leave")
+	err = NewHangoutAgg(store).RemoveParticipant(t.Context(), model.HangoutId{1}, "creator", "creator")
+	assert.ErrorIs(t, err, ErrCreatorNotRemovable)
+	assert.Equal(t, []model.IndividualId{"participant"}, store.left, "expected only the participant to leave")
+}
+
+func Test_HangoutAgg_UpdateDetails_InvalidDetails_AreNotStored(t *testing.T) {
+	store := &recordingStorage{creator: "creator"}
+
+	err := NewHangoutAgg(store).UpdateDetails(t.Context(), model.HangoutId{1}, "creator", model.HangoutDetails{Duration: -1})
+	assert.ErrorIs(t, err, ErrEmptyLocation)
+	assert.ErrorIs(t, err, ErrNegativeMinutes)
+	assert.Empty(t, store.details, "expected the storage to not be called")
+
+	err = NewHangoutAgg(store).UpdateDetails(t.Context(), model.HangoutId{1}, "creator", validDetails())
+	require.NoError(t, err, "expected valid details to be stored")
+	assert.Equal(t, []model.HangoutDetails{validDetails()}, store.details)
+}
+
+func Test_HangoutAgg_UpdateDetails_ByParticipant_ReturnsError(t *testing.T) {
+	store := &recordingStorage{creator: "creator"}
+
+	err := NewHangoutAgg(store).UpdateDetails(t.Context(), model.HangoutId{1}, "participant", validDetails())
+	assert.ErrorIs(t, err, ErrNotHangoutCreator)
+	assert.Empty(t, store.details, "expected the storage to not be called")
+}
+
+func Test_HangoutAgg_UpdateParticipants_KeepsTheCreatorFirst(t *testing.T) {
+	store := &recordingStorage{creator: "creator", current: []model.IndividualId{"creator"}}
+
+	err := NewHangoutAgg(store).UpdateParticipants(t.Context(), model.HangoutId{1}, "creator", []model.IndividualId{"other", "other"})
+	require.NoError(t, err, "expected participants to be updated")
+	assert.Equal(t, [][]model.IndividualId{{"creator", "other"}}, store.participants, "expected deduplicated participants starting with the creator")
+}
+
+func Test_HangoutAgg_UpdateParticipants_ChecksEveryRemoval(t *testing.T) {
+	tc := []struct {
+		name         string
+		by           model.IndividualId
+		participants []model.IndividualId
+		want         error
+	}{
+		{name: "creator removes participants", by: "creator", participants: nil, want: nil},
+		{name: "participant leaves", by: "alice", participants: []model.IndividualId{"bob"}, want: nil},
+		{name: "participant adds someone", by: "alice", participants: []model.IndividualId{"alice", "bob", "carol"}, want: nil},
+		{name: "participant removes another", by: "alice", participants: []model.IndividualId{"alice"}, want: ErrNotHangoutCreator},
+		{name: "stranger removes participant", by: "mallory", participants: []model.IndividualId{"alice", "mallory"}, want: ErrNotHangoutCreator},
+		{name: "stranger adds themselves", by: "mallory", participants: []model.IndividualId{"creator", "alice", "bob", "mallory"}, want: ErrNotHangoutCreator},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := &recordingStorage{creator: "creator", current: []model.IndividualId{"creator", "alice", "bob"}}
+
+			err := NewHangoutAgg(store).UpdateParticipants(t.Context(), model.HangoutId{1}, tt.by, tt.participants)
+			if tt.want == nil {
+				assert.NoError(t, err, "expected the update to be allowed")
+				assert.Len(t, store.participants, 1, "expected the participants to be stored")
+				return
+			}
+			assert.ErrorIs(t, err, tt.want)
+			assert.Empty(t, store.participants, "expected the storage to not be called")
+		})
+	}
+}
+
+func Test_HangoutAgg_OrphanedHangout_IsManagedByItsParticipants(t *testing.T) {
+	store := &recordingStorage{current: []model.IndividualId{"alice", "bob"}}
+	id := model.HangoutId{1}
+
+	err := NewHangoutAgg(store).UpdateDetails(t.Context(), id, "alice", validDetails())
+	require.NoError(t, err, "expected a participant to be able to update the details")
+	err = NewHangoutAgg(store).DeleteHangout(t.Context(), id, "alice")
+	require.NoError(t, err, "expected a participant to be able to delete the hangout")
+	err = NewHangoutAgg(store).RestoreHangout(t.Context(), id, "bob")
+	require.NoError(t, err, "expected a participant to be able to restore the hangout")
+	assert.Len(t, store.details, 1, "expected the details to be stored")
+	assert.Equal(t, []model.HangoutId{id}, store.deleted)
+	assert.Equal(t, []model.HangoutId{id}, store.restored)
+
+	err = NewHangoutAgg(store).UpdateDetails(t.Context(), id, "mallory", validDetails())
+	assert.ErrorIs(t, err, ErrNotHangoutParticipant)
+	err = NewHangoutAgg(store).DeleteHangout(t.Context(), id, "mallory")
+	assert.ErrorIs(t, err, ErrNotHangoutParticipant)
+	err = NewHangoutAgg(store).RestoreHangout(t.Context(), id, "mallory")
+	assert.ErrorIs(t, err, ErrNotHangoutParticipant)
+	assert.Len(t, store.details, 1, "expected the storage to not be called")
+}
+
+func Test_HangoutAgg_RemoveParticipant_OrphanedHangout(t *testing.T) {
+	tc := []struct {
+		name        string
+		by          model.IndividualId
+		participant model.IndividualId
+		want        error
+	}{
+		{name: "participant leaves", by: "alice", participant: "alice", want: nil},
+		{name: "participant removes another", by: "alice", participant: "bob", want: nil},
+		{name: "stranger removes participant", by: "mallory", participant: "bob", want: ErrNotHangoutParticipant},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := &recordingStorage{current: []model.IndividualId{"alice", "bob"}}
+
+			err := NewHangoutAgg(store).RemoveParticipant(t.Context(), model.HangoutId{1}, tt.by, tt.participant)
+			if tt.want == nil {
+				assert.NoError(t, err, "expected removal to be allowed")
+				assert.Equal(t, []model.IndividualId{tt.participant}, store.left)
+				return
+			}
+			assert.ErrorIs(t, err, tt.want)
+			assert.Empty(t, store.left, "expected the storage to not be called")
+		})
+	}
+}
+
+func Test_HangoutAgg_UpdateParticipants_OrphanedHangout(t *testing.T) {
+	tc := []struct {
+		name         string
+		by           model.IndividualId
+		participants []model.IndividualId
+		want         []model.IndividualId
+		err          error
+	}{
+		{name: "participant leaves", by: "alice", participants: []model.IndividualId{"bob"}, want: []model.IndividualId{"bob"}},
+		{name: "participant removes another", by: "alice", participants: []model.IndividualId{"alice", "carol"}, want: []model.IndividualId{"alice", "carol"}},
+		{name: "participant adds someone", by: "bob", participants: []model.IndividualId{"alice", "bob", "carol"}, want: []model.IndividualId{"alice", "bob", "carol"}},
+		{name: "stranger adds themselves", by: "mallory", participants: []model.IndividualId{"alice", "bob", "mallory"}, err: ErrNotHangoutParticipant},
+		{name: "stranger removes participant", by: "mallory", participants: []model.IndividualId{"alice"}, err: ErrNotHangoutParticipant},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := &recordingStorage{current: []model.IndividualId{"alice", "bob"}}
+
+			err := NewHangoutAgg(store).UpdateParticipants(t.Context(), model.HangoutId{1}, tt.by, tt.participants)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Empty(t, store.participants, "expected the storage to not be called")
+				return
+			}
+			require.NoError(t, err, "expected the update to be allowed without a creator")
+			assert.Equal(t, [][]model.IndividualId{tt.want}, store.participants, "expected no creator to be added")
+		})
+	}
+}
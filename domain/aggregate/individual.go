@@ -3,6 +3,9 @@ package aggregate
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/Ozoniuss/hangcounts/domain/model"
 	"github.com/Ozoniuss/hangcounts/domain/storage"
@@ -16,8 +19,17 @@ var ErrEmptyName = errors.New("name cannot be empty")
 var ErrEmptyUsername = errors.New("username cannot be empty")
 var ErrDuplicateUser = errors.New("user already exists")
 
-// Explicit Hangout errors
-var ErrNegativeMinutes = errors.New("duration cannot be negative")
+// Explicit password policy errors
+var ErrPasswordTooShort = fmt.Errorf("password must have at least %d characters", MIN_PASSWORD_LENGTH)
+var ErrPasswordTooLong = fmt.Errorf("password must have at most %d bytes", MAX_PASSWORD_BYTES)
+var ErrPasswordContainsUsername = errors.New("password cannot contain the username")
+
+const (
+	MIN_PASSWORD_LENGTH = 12
+	// bcrypt ignores everything after 72 bytes, so do not pretend longer
+	// passwords are more secure
+	MAX_PASSWORD_BYTES = 72
+)
 
 type IndividualAgg struct {
 	model.Individual
@@ -25,7 +37,9 @@ type IndividualAgg struct {
 	storage storage.AppStorage
 }
 
-func (agg *IndividualAgg) CreateNewIndividualAccount(ctx context.Context, id uint64, name, email, username string) error {
+// NewIndividual validates the account fields of an individual and returns all
+// the validation errors at once, so that clients can fix them in one go.
+func NewIndividual(name, email, username string) (model.Individual, error) {
 	var errs error
 
 	// may move those to their own time
@@ -37,18 +51,48 @@ func (agg *IndividualAgg) CreateNewIndividualAccount(ctx context.Context, id uin
 	}
 	_email, err := model.NewEmail(email)
 	if err != nil {
-		errs = errors.Join(errs, err)
+		errs = errors.Join(errs, fmt.Errorf("%w: %w", ErrInvalidEmail, err))
 	}
-	// eager return to avoid database call
 	if errs != nil {
-		return IndividualValidationError(errs)
+		return model.Individual{}, IndividualValidationError(errs)
 	}
 
-	agg.Individual = model.Individual{
+	return model.Individual{
 		Username: model.IndividualId(username),
 		Name:     name,
-		Email:    model.Email(_email),
+		Email:    _email,
+	}, nil
+}
+
+// ValidatePassword checks the password of an individual against the password
+// policy, returning all violations at once.
+func ValidatePassword(password string, username model.IndividualId) error {
+	var errs error
+
+	if utf8.RuneCountInString(password) < MIN_PASSWORD_LENGTH {
+		errs = errors.Join(errs, ErrPasswordTooShort)
 	}
+	if len(password) > MAX_PASSWORD_BYTES {
+		errs = errors.Join(errs, ErrPasswordTooLong)
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(string(username))) {
+		errs = errors.Join(errs, ErrPasswordContainsUsername)
+	}
+	if errs != nil {
+		return IndividualValidationError(errs)
+	}
+
+	return nil
+}
+
+func (agg *IndividualAgg) CreateNewIndividualAccount(ctx context.Context, id uint64, name, email, username string) error {
+	individual, err := NewIndividual(name, email, username)
+	// eager return to avoid database call
+	if err != nil {
+		return err
+	}
+
+	agg.Individual = individual
 	err = agg.storage.StoreIndividual(ctx, agg.Individual)
 	if errors.Is(err, storage.ErrNotFound) {
 		return IndividualValidationError(ErrDuplicateUser)
@@ -0,0 +1,51 @@
+package aggregate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewIndividual_ValidFields_ReturnsIndividual(t *testing.T) {
+	individual, err := NewIndividual("name", "test@example.com", "username")
+	assert.NoError(t, err, "valid individual should not return an error")
+	assert.Equal(t, model.Individual{
+		Name:     "name",
+		Email:    "test@example.com",
+		Username: "username",
+	}, individual)
+}
+
+func Test_NewIndividual_InvalidFields_ReturnsAllErrors(t *testing.T) {
+	_, err := NewIndividual("", "invalid", "")
+	assert.ErrorIs(t, err, ErrEmptyName)
+	assert.ErrorIs(t, err, ErrEmptyUsername)
+	assert.ErrorIs(t, err, ErrInvalidEmail)
+}
+
+func Test_ValidatePassword_ValidPassword_ReturnsNoError(t *testing.T) {
+	err := ValidatePassword("correct horse battery staple", "username")
+	assert.NoError(t, err, "valid password should not return an error")
+}
+
+func Test_ValidatePassword_InvalidPassword_ReturnsError(t *testing.T) {
+	tc := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{name: "too short", password: "short", want: ErrPasswordTooShort},
+		{name: "too long", password: strings.Repeat("a", MAX_PASSWORD_BYTES+1), want: ErrPasswordTooLong},
+		{name: "contains username", password: "my-UserName-is-secret", want: ErrPasswordContainsUsername},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidatePassword(tt.password, "username")
+			assert.ErrorIs(t, err, tt.want, "invalid password should return error")
+		})
+	}
+}
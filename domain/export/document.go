@@ -0,0 +1,219 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/google/uuid"
+)
+
+// The documents are a public format, do not rename the fields.
+
+type individualDocument struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	// DeletedAt is set while the account can still be restored.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type hangoutDocument struct {
+	Id              string    `json:"id"`
+	Location        string    `json:"location"`
+	Description     *string   `json:"description,omitempty"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Date            time.Time `json:"date"`
+	CreatedBy       string    `json:"created_by"`
+	// Created is whether the exported individual created the hangout.
+	Created        bool     `json:"created"`
+	CoParticipants []string `json:"co_participants"`
+	// DeletedCoParticipants deleted their account after the hangout.
+	DeletedCoParticipants []string `json:"deleted_co_participants,omitempty"`
+	// LeftAt is set when the individual is no longer a participant.
+	LeftAt *time.Time `json:"left_at,omitempty"`
+	// DeletedAt is set when the hangout was deleted and not purged yet.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type sessionDocument struct {
+	CreatedAt    time.Time `json:"created_at"`
+	LastAccessed time.Time `json:"last_accessed"`
+}
+
+type document struct {
+	ExportedAt time.Time          `json:"exported_at"`
+	Individual individualDocument `json:"individual"`
+	Hangouts   []hangoutDocument  `json:"hangouts"`
+	Sessions   []sessionDocument  `json:"sessions"`
+}
+
+func without(ids []model.IndividualId, id model.IndividualId) []string {
+	out := []string{}
+	for _, other := range ids {
+		if other != id {
+			out = append(out, string(other))
+		}
+	}
+	return out
+}
+
+func newDocument(e Export) document {
+	username := e.Individual.Username
+
+	doc := document{
+		ExportedAt: e.ExportedAt,
+		Individual: individualDocument{
+			Name:      e.Individual.Name,
+			Email:     string(e.Individual.Email),
+			Username:  string(username),
+			DeletedAt: e.DeletedAt,
+		},
+		Hangouts: make([]hangoutDocument, 0, len(e.Hangouts)),
+		Sessions: make([]sessionDocument, 0, len(e.Sessions)),
+	}
+	for _, h := range e.Hangouts {
+		hd := hangoutDocument{
+			Id:              uuid.UUID(h.PublicId).String(),
+			Location:        h.Location,
+			Description:     h.Description,
+			DurationMinutes: int(h.Duration),
+			Date:            h.Date,
+			CreatedBy:       string(h.CreatedBy),
+			Created:         h.CreatedBy == username,
+			CoParticipants:  without(h.Individuals, username),
+			LeftAt:          h.LeftAt,
+			DeletedAt:       h.DeletedAt,
+		}
+		if deleted := without(h.DeletedIndividuals, username); len(deleted) > 0 {
+			hd.DeletedCoParticipants = deleted
+		}
+		doc.Hangouts = append(doc.Hangouts, hd)
+	}
+	for _, s := range e.Sessions {
+		doc.Sessions = append(doc.Sessions, sessionDocument{
+			CreatedAt:    s.CreatedAt,
+			LastAccessed: s.LastAccessed,
+		})
+	}
+	return doc
+}
+
+// Write writes the export to w in the given format.
+func (e Export) Write(w io.Writer, format Format) error {
+	switch format {
+	case FORMAT_JSON:
+		return writeJSON(w, newDocument(e))
+	case FORMAT_ZIP:
+		return writeZIP(w, newDocument(e))
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+func writeJSON(w io.Writer, doc document) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("could not encode export: %w", err)
+	}
+	return nil
+}
+
+// csvFile is one file of the zip archive, the first record is the header.
+type csvFile struct {
+	name    string
+	records [][]string
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// missing times are written as empty cells
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+// participants within a CSV cell are separated by semicolons
+func joinUsernames(usernames []string) string {
+	return strings.Join(usernames, ";")
+}
+
+func csvFiles(doc document) []csvFile {
+	individual := csvFile{
+		name: "individual.csv",
+		records: [][]string{
+			{"name", "email", "username", "exported_at", "deleted_at"},
+			{doc.Individual.Name, doc.Individual.Email, doc.Individual.Username, formatTime(doc.ExportedAt), formatOptionalTime(doc.Individual.DeletedAt)},
+		},
+	}
+
+	hangouts := csvFile{
+		name: "hangouts.csv",
+		records: [][]string{
+			{"id", "date", "location", "description", "duration_minutes", "created_by", "created", "co_participants", "deleted_co_participants", "left_at", "deleted_at"},
+		},
+	}
+	for _, h := range doc.Hangouts {
+		var description string
+		if h.Description != nil {
+			description = *h.Description
+		}
+		hangouts.records = append(hangouts.records, []string{
+			h.Id,
+			formatTime(h.Date),
+			h.Location,
+			description,
+			strconv.Itoa(h.DurationMinutes),
+			h.CreatedBy,
+			strconv.FormatBool(h.Created),
+			joinUsernames(h.CoParticipants),
+			joinUsernames(h.DeletedCoParticipants),
+			formatOptionalTime(h.LeftAt),
+			formatOptionalTime(h.DeletedAt),
+		})
+	}
+
+	sessions := csvFile{
+		name: "sessions.csv",
+		records: [][]string{
+			{"created_at", "last_accessed"},
+		},
+	}
+	for _, s := range doc.Sessions {
+		sessions.records = append(sessions.records, []string{formatTime(s.CreatedAt), formatTime(s.LastAccessed)})
+	}
+
+	return []csvFile{individual, hangouts, sessions}
+}
+
+func writeZIP(w io.Writer, doc document) error {
+	archive := zip.NewWriter(w)
+	for _, file := range csvFiles(doc) {
+		fw, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: doc.ExportedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create %s: %w", file.name, err)
+		}
+		cw := csv.NewWriter(fw)
+		if err := cw.WriteAll(file.records); err != nil {
+			return fmt.Errorf("could not write %s: %w", file.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("could not close archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+)
+
+// Format is the kind of document an export is written as.
+type Format string
+
+const (
+	FORMAT_JSON Format = "json"
+	// FORMAT_ZIP is an archive with one CSV file per kind of record.
+	FORMAT_ZIP Format = "zip"
+
+	// how many hangouts are read from the storage at once
+	HANGOUT_PAGE_SIZE = 100
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+func ParseFormat(f string) (Format, error) {
+	switch Format(f) {
+	case FORMAT_JSON, FORMAT_ZIP:
+		return Format(f), nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, f)
+}
+
+// Session is the metadata of a session. The cookie is deliberately left out,
+// exports are downloaded and stored outside of the application.
+type Session struct {
+	CreatedAt    time.Time
+	LastAccessed time.Time
+}
+
+// Hangout is a hangout the individual took part in, the participants are the
+// current ones.
+type Hangout struct {
+	model.Hangout
+	// LeftAt is set when the individual left the hangout or was removed from
+	// it.
+	LeftAt *time.Time
+	// DeletedAt is set when the hangout was deleted and is not purged yet.
+	DeletedAt *time.Time
+}
+
+type HangoutPage struct {
+	Hangouts []Hangout
+	// Next is nil on the last page.
+	Next *storage.HangoutCursor
+}
+
+// Export is everything stored about an individual.
+type Export struct {
+	ExportedAt time.Time
+	Individual model.Individual
+	// DeletedAt is set when the individual deleted their account and can
+	// still restore it.
+	DeletedAt *time.Time
+	// Hangouts the individual created or took part in, most recent first.
+	Hangouts []Hangout
+	Sessions []Session
+}
+
+// Storage reads everything stored about individuals, including the ones that
+// deleted their account and are not anonymised yet.
+type Storage interface {
+	// GetIndividualForExport also returns when the individual deleted their
+	// account, if they did.
+	GetIndividualForExport(ctx context.Context, username model.IndividualId) (model.Individual, *time.Time, error)
+	// ListHangoutsForExport returns every hangout the individual took part
+	// in, including the ones they left and the deleted ones, most recent
+	// first. The next page starts after the cursor of the previous one.
+	ListHangoutsForExport(ctx context.Context, username model.IndividualId, after *storage.HangoutCursor, limit int) (HangoutPage, error)
+	// ListSessionsOfIndividual returns the sessions of the individual, the
+	// most recently accessed first.
+	ListSessionsOfIndividual(ctx context.Context, username model.IndividualId) ([]Session, error)
+}
+
+type Service struct {
+	storage Storage
+	now     func() time.Time
+}
+
+func NewService(store Storage) *Service {
+	return &Service{
+		storage: store,
+		now:     time.Now,
+	}
+}
+
+// Export collects the data of the individual. The records are read one after
+// the other, so a change made during the export may only be partially
+// included.
+func (s *Service) Export(ctx context.Context, username model.IndividualId) (Export, error) {
+	exportedAt := s.now().UTC()
+
+	individual, deletedAt, err := s.storage.GetIndividualForExport(ctx, username)
+	if err != nil {
+		return Export{}, fmt.Errorf("could not get individual: %w", err)
+	}
+
+	hangouts := []Hangout{}
+	var after *storage.HangoutCursor
+	for {
+		page, err := s.storage.ListHangoutsForExport(ctx, username, after, HANGOUT_PAGE_SIZE)
+		if err != nil {
+			return Export{}, fmt.Errorf("could not list hangouts: %w", err)
+		}
+		hangouts = append(hangouts, page.Hangouts...)
+		if page.Next == nil {
+			break
+		}
+		after = page.Next
+	}
+
+	sessions, err := s.storage.ListSessionsOfIndividual(ctx, username)
+	if err != nil {
+		return Export{}, fmt.Errorf("could not list sessions: %w", err)
+	}
+
+	return Export{
+		ExportedAt: exportedAt,
+		Individual: individual,
+		DeletedAt:  deletedAt,
+		Hangouts:   hangouts,
+		Sessions:   sessions,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedStorage returns one hangout per page.
+type pagedStorage struct {
+	individual model.Individual
+	deletedAt  *time.Time
+	hangouts   []Hangout
+	sessions   []Session
+}
+
+func (p *pagedStorage) GetIndividualForExport(_ context.Context, _ model.IndividualId) (model.Individual, *time.Time, error) {
+	return p.individual, p.deletedAt, nil
+}
+
+func (p *pagedStorage) ListHangoutsForExport(_ context.Context, _ model.IndividualId, after *storage.HangoutCursor, _ int) (HangoutPage, error) {
+	i := 0
+	if after != nil {
+		for i < len(p.hangouts) && p.hangouts[i].PublicId != after.PublicId {
+			i++
+		}
+		i++
+	}
+	if i >= len(p.hangouts) {
+		return HangoutPage{}, nil
+	}
+	page := HangoutPage{Hangouts: p.hangouts[i : i+1]}
+	if i+1 < len(p.hangouts) {
+		page.Next = &storage.HangoutCursor{Date: p.hangouts[i].Date, PublicId: p.hangouts[i].PublicId}
+	}
+	return page, nil
+}
+
+func (p *pagedStorage) ListSessionsOfIndividual(_ context.Context, _ model.IndividualId) ([]Session, error) {
+	return p.sessions, nil
+}
+
+func newTestExport() Export {
+	description := "board games"
+	date := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	leftAt := date.AddDate(0, 0, 1)
+	return Export{
+		ExportedAt: time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC),
+		Individual: model.Individual{Name: "Alice", Email: "alice@example.com", Username: "alice"},
+		Hangouts: []Hangout{
+			{Hangout: model.Hangout{
+				PublicId:       model.HangoutId(uuid.MustParse("0195a5f2-7f43-7c2e-9a38-4f5a3c0b1e01")),
+				HangoutDetails: model.HangoutDetails{Location: "home", Description: &description, Duration: 90, Date: date},
+				CreatedBy:      "alice",
+				Individuals:    []model.IndividualId{"alice", "bob", "carol"},
+			}},
+			{Hangout: model.Hangout{
+				PublicId:           model.HangoutId(uuid.MustParse("0195a5f2-7f43-7c2e-9a38-4f5a3c0b1e02")),
+				HangoutDetails:     model.HangoutDetails{Location: "park", Duration: 30, Date: date.AddDate(0, 0, -1)},
+				CreatedBy:          "bob",
+				Individuals:        []model.IndividualId{"bob", "dave"},
+				DeletedIndividuals: []model.IndividualId{"dave"},
+			}, LeftAt: &leftAt},
+		},
+		Sessions: []Session{{CreatedAt: date, LastAccessed: date.Add(time.Hour)}},
+	}
+}
+
+func Test_ParseFormat_UnknownFormat_ReturnsError(t *testing.T) {
+	_, err := ParseFormat("xml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func Test_Service_Export_ReadsAllPages(t *testing.T) {
+	want := newTestExport()
+	deletedAt := want.ExportedAt.AddDate(0, 0, -1)
+	want.DeletedAt = &deletedAt
+	store := &pagedStorage{individual: want.Individual, deletedAt: want.DeletedAt, hangouts: want.Hangouts, sessions: want.Sessions}
+	service := NewService(store)
+	service.now = func() time.Time { return want.ExportedAt }
+
+	got, err := service.Export(t.Context(), "alice")
+	require.NoError(t, err, "expected no error when exporting")
+	assert.Equal(t, want, got, "expected every hangout to be exported")
+}
+
+func Test_Export_Write_JSON_ListsCoParticipants(t *testing.T) {
+	var buf bytes.Buffer
+	err := newTestExport().Write(&buf, FORMAT_JSON)
+	require.NoError(t, err, "expected no error when writing json")
+
+	var got document
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got), "expected valid json")
+	require.Len(t, got.Hangouts, 2, "expected both hangouts")
+	assert.True(t, got.Hangouts[0].Created, "expected the first hangout to be created by the individual")
+	assert.Equal(t, []string{"bob", "carol"}, got.Hangouts[0].CoParticipants, "expected the individual to not be a co-participant")
+	assert.False(t, got.Hangouts[1].Created, "expected the second hangout to be created by someone else")
+	assert.Equal(t, []string{"dave"}, got.Hangouts[1].DeletedCoParticipants, "expected deleted co-participants to be flagged")
+	assert.Nil(t, got.Hangouts[0].LeftAt, "expected the individual to still take part in the first hangout")
+	assert.NotNil(t, got.Hangouts[1].LeftAt, "expected the individual to have left the second hangout")
+	assert.Len(t, got.Sessions, 1, "expected the session metadata")
+}
+
+func Test_Export_Write_ZIP_ContainsOneCSVPerRecord(t *testing.T) {
+	var buf bytes.Buffer
+	err := newTestExport().Write(&buf, FORMAT_ZIP)
+	require.NoError(t, err, "expected no error when writing zip")
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err, "expected a valid zip archive")
+
+	rows := make(map[string]int)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err, "expected file to be readable")
+		records, err := csv.NewReader(r).ReadAll()
+		require.NoError(t, err, "expected valid csv in %s", f.Name)
+		r.Close()
+		rows[f.Name] = len(records)
+	}
+	assert.Equal(t, map[string]int{
+		"individual.csv": 2,
+		"hangouts.csv":   3,
+		"sessions.csv":   2,
+	}, rows, "expected a header and one row per record")
+}
+
+func Test_Export_Write_UnknownFormat_ReturnsError(t *testing.T) {
+	err := newTestExport().Write(&bytes.Buffer{}, "xml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
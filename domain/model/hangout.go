@@ -36,9 +36,17 @@ type Hangout struct {
 	// or participants remove their account, hangouts would work correctly with
 	// eventually consistent Individuals. (Reading Individual data is obviously
 	// functional under eventual consistency)
+	//
+	// CreatedBy is empty when reading a hangout whose creator erased their
+	// account.
 	CreatedBy IndividualId
 
-	// The creator must be part of the individuals. This should be enforced by
-	// the aggregate.
+	// The creator must be part of the individuals. This is enforced by the
+	// aggregate.
 	Individuals []IndividualId
+
+	// DeletedIndividuals are the individuals that deleted their account after
+	// taking part in the hangout. They are also part of Individuals and are
+	// only filled in when reading hangouts.
+	DeletedIndividuals []IndividualId
 }
@@ -0,0 +1,75 @@
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/batch"
+)
+
+// number of records deleted by a single query, to keep the purger from
+// holding locks on the tables for too long
+const PURGE_BATCH_SIZE = 1000
+
+type Storage interface {
+	// PurgeDeletedHangouts permanently removes at most limit hangouts that
+	// were soft-deleted before the given time, together with their
+	// participants.
+	PurgeDeletedHangouts(ctx context.Context, deletedBefore time.Time, limit int) (int64, error)
+	// AnonymiseDeletedIndividuals wipes the personal data of at most limit
+	// individuals that deleted their account before the given time, along
+	// with their credentials and sessions. Their rows are kept, so that they
+	// stay in the hangouts of the other participants, but the hangouts they
+	// created lose their creator.
+	AnonymiseDeletedIndividuals(ctx context.Context, deletedBefore time.Time, limit int) (int64, error)
+}
+
+// Purger permanently removes the soft-deleted records once their retention
+// window is over. Until then they can be restored.
+type Purger struct {
+	storage             Storage
+	hangoutRetention    time.Duration
+	individualRetention time.Duration
+	logger              *slog.Logger
+	now                 func() time.Time
+}
+
+func NewPurger(store Storage, hangoutRetention, individualRetention time.Duration, logger *slog.Logger) *Purger {
+	return &Purger{
+		storage:             store,
+		hangoutRetention:    hangoutRetention,
+		individualRetention: individualRetention,
+		logger:              logger,
+		now:                 time.Now,
+	}
+}
+
+// Run purges the expired records every interval, until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context, interval time.Duration, batchSize int) {
+	batch.RunEvery(ctx, "purger", interval, p.logger, func(ctx context.Context) {
+		p.purgeHangouts(ctx, batchSize)
+		p.anonymiseIndividuals(ctx, batchSize)
+	})
+}
+
+func (p *Purger) purgeHangouts(ctx context.Context, batchSize int) {
+	p.inBatches(ctx, "deleted hangouts", p.now().Add(-p.hangoutRetention), batchSize, p.storage.PurgeDeletedHangouts)
+}
+
+func (p *Purger) anonymiseIndividuals(ctx context.Context, batchSize int) {
+	p.inBatches(ctx, "deleted individuals", p.now().Add(-p.individualRetention), batchSize, p.storage.AnonymiseDeletedIndividuals)
+}
+
+func (p *Purger) inBatches(ctx context.Context, records string, deletedBefore time.Time, batchSize int, purge func(context.Context, time.Time, int) (int64, error)) {
+	total, err := batch.Drain(ctx, batchSize, func(ctx context.Context, limit int) (int64, error) {
+		return purge(ctx, deletedBefore, limit)
+	})
+	if err != nil {
+		// try again on the next tick
+		p.logger.ErrorContext(ctx, "could not purge "+records, slog.Any("error", err), slog.Int64("removed", total))
+		return
+	}
+
+	p.logger.InfoContext(ctx, "purged "+records, slog.Int64("removed", total))
+}
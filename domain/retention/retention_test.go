@@ -0,0 +1,90 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStorage struct {
+	// soft-deleted hangouts, by deletion time
+	hangouts []time.Time
+	purged   []int64
+	calls    int
+	err      error
+	// the cutoff of the last anonymisation
+	anonymisedBefore time.Time
+}
+
+func (f *fakeStorage) AnonymiseDeletedIndividuals(_ context.Context, deletedBefore time.Time, _ int) (int64, error) {
+	f.anonymisedBefore = deletedBefore
+	return 0, nil
+}
+
+func (f *fakeStorage) PurgeDeletedHangouts(_ context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	var kept []time.Time
+	var removed int64
+	for _, deletedAt := range f.hangouts {
+		if deletedAt.Before(deletedBefore) && removed < int64(limit) {
+			removed++
+			continue
+		}
+		kept = append(kept, deletedAt)
+	}
+	f.hangouts = kept
+	f.purged = append(f.purged, removed)
+	return removed, nil
+}
+
+func newTestPurger(store Storage, now time.Time) *Purger {
+	p := NewPurger(store, 30*24*time.Hour, 7*24*time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	p.now = func() time.Time { return now }
+	return p
+}
+
+func Test_Purger_PurgeHangouts_KeepsHangoutsInRetentionWindow(t *testing.T) {
+	now := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	recent := now.AddDate(0, 0, -29)
+	store := &fakeStorage{hangouts: []time.Time{now.AddDate(0, 0, -31), recent}}
+
+	newTestPurger(store, now).purgeHangouts(t.Context(), PURGE_BATCH_SIZE)
+
+	assert.Equal(t, []time.Time{recent}, store.hangouts, "expected only hangouts deleted over 30 days ago to be purged")
+}
+
+func Test_Purger_PurgeHangouts_PurgesInBatches(t *testing.T) {
+	now := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	old := now.AddDate(-1, 0, 0)
+	store := &fakeStorage{hangouts: []time.Time{old, old, old, old, old}}
+
+	newTestPurger(store, now).purgeHangouts(t.Context(), 2)
+
+	assert.Empty(t, store.hangouts, "expected all expired hangouts to be purged in one run")
+	assert.Equal(t, []int64{2, 2, 1}, store.purged, "expected hangouts to be purged in batches")
+}
+
+func Test_Purger_PurgeHangouts_StopsOnError(t *testing.T) {
+	store := &fakeStorage{err: errors.New("boom")}
+
+	newTestPurger(store, time.Now()).purgeHangouts(t.Context(), 2)
+
+	assert.Equal(t, 1, store.calls, "expected the purge to stop at the first error")
+}
+
+func Test_Purger_AnonymiseIndividuals_UsesTheGracePeriod(t *testing.T) {
+	now := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	store := &fakeStorage{}
+
+	newTestPurger(store, now).anonymiseIndividuals(t.Context(), PURGE_BATCH_SIZE)
+
+	assert.Equal(t, time.Date(2025, 3, 24, 0, 0, 0, 0, time.UTC), store.anonymisedBefore, "expected individuals deleted over 7 days ago to be anonymised")
+}
@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+)
+
+// Window is the period the statistics are computed over, ending now.
+type Window string
+
+const (
+	WINDOW_ALL_TIME Window = "all"
+	WINDOW_YEAR     Window = "year"
+	WINDOW_MONTH    Window = "month"
+)
+
+var ErrUnknownWindow = errors.New("unknown statistics window")
+
+func ParseWindow(w string) (Window, error) {
+	switch Window(w) {
+	case WINDOW_ALL_TIME, WINDOW_YEAR, WINDOW_MONTH:
+		return Window(w), nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownWindow, w)
+}
+
+// Since returns the start of the window ending at now, which is the zero
+// time for the all-time window.
+func (w Window) Since(now time.Time) time.Time {
+	switch w {
+	case WINDOW_YEAR:
+		return now.AddDate(-1, 0, 0)
+	case WINDOW_MONTH:
+		return now.AddDate(0, -1, 0)
+	}
+	return time.Time{}
+}
+
+// PairStats is how much time an individual spent with another individual.
+type PairStats struct {
+	With model.IndividualId
+	// With deleted their account.
+	Deleted  bool
+	Hangouts int
+	Minutes  model.Minutes
+}
+
+type Storage interface {
+	// PairStatsForIndividual aggregates the hangouts of the individual dated
+	// in [from, to) per co-participant, the ones spent together the most
+	// first. A zero from does not bound the hangouts.
+	PairStatsForIndividual(ctx context.Context, username model.IndividualId, from, to time.Time) ([]PairStats, error)
+	// PairHangoutDates returns the dates of the hangouts of the individual
+	// before the given time, grouped per co-participant.
+	PairHangoutDates(ctx context.Context, username model.IndividualId, before time.Time) ([]PairDates, error)
+	// IndividualsNotSeenSince returns the co-participants of the individual
+	// whose last hangout together before the given time is older than since,
+	// the most frequent ones first. Individuals that deleted their account
+	// are left out.
+	IndividualsNotSeenSince(ctx context.Context, username model.IndividualId, since, before time.Time) ([]Reminder, error)
+}
+
+type Service struct {
+	storage Storage
+	now     func() time.Time
+}
+
+func NewService(store Storage) *Service {
+	return &Service{
+		storage: store,
+		now:     time.Now,
+	}
+}
+
+// PairStats returns the statistics of the individual with everyone they had
+// a hangout with during the window. Planned hangouts are not counted.
+func (s *Service) PairStats(ctx context.Context, username model.IndividualId, window Window) ([]PairStats, error) {
+	now := s.now()
+	return s.storage.PairStatsForIndividual(ctx, username, window.Since(now), now)
+}
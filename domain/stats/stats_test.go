@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingStorage struct {
+	from, to time.Time
+	dates    []PairDates
+}
+
+func (r *recordingStorage) PairStatsForIndividual(_ context.Context, _ model.IndividualId, from, to time.Time) ([]PairStats, error) {
+	r.from, r.to = from, to
+	return nil, nil
+}
+
+func (r *recordingStorage) PairHangoutDates(_ context.Context, _ model.IndividualId, before time.Time) ([]PairDates, error) {
+	r.to = before
+	return r.dates, nil
+}
+
+func (r *recordingStorage) IndividualsNotSeenSince(_ context.Context, _ model.IndividualId, since, before time.Time) ([]Reminder, error) {
+	r.from, r.to = since, before
+	return nil, nil
+}
+
+func Test_ParseWindow_UnknownWindow_ReturnsError(t *testing.T) {
+	_, err := ParseWindow("week")
+	assert.ErrorIs(t, err, ErrUnknownWindow)
+}
+
+func Test_Service_PairStats_QueriesTheWindow(t *testing.T) {
+	now := time.Date(2025, 3, 16, 10, 0, 0, 0, time.UTC)
+	tc := []struct {
+		window Window
+		from   time.Time
+	}{
+		{window: WINDOW_ALL_TIME, from: time.Time{}},
+		{window: WINDOW_YEAR, from: time.Date(2024, 3, 16, 10, 0, 0, 0, time.UTC)},
+		{window: WINDOW_MONTH, from: time.Date(2025, 2, 16, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tc {
+		t.Run(string(tt.window), func(t *testing.T) {
+			store := &recordingStorage{}
+			service := NewService(store)
+			service.now = func() time.Time { return now }
+
+			window, err := ParseWindow(string(tt.window))
+			require.NoError(t, err, "expected window to be valid")
+			_, err = service.PairStats(t.Context(), "username", window)
+			require.NoError(t, err, "expected no error when computing statistics")
+			assert.Equal(t, tt.from, store.from, "expected the window to start at the right time")
+			assert.Equal(t, now, store.to, "expected the window to end now")
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package stats
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+)
+
+var ErrInvalidReminderDays = errors.New("reminder days must be positive")
+
+// PairDates are the dates of the hangouts an individual had with another
+// individual, oldest first.
+type PairDates struct {
+	With model.IndividualId
+	// With deleted their account.
+	Deleted bool
+	Dates   []time.Time
+}
+
+// PairStreak tells how regularly an individual sees another individual.
+// Streaks are counted in consecutive calendar weeks with at least one
+// hangout, weeks start on Monday in UTC.
+type PairStreak struct {
+	With          model.IndividualId
+	Deleted       bool
+	CurrentStreak int
+	LongestStreak int
+	DaysSinceLast int
+}
+
+// Reminder is an individual that has not been seen for a while.
+type Reminder struct {
+	With     model.IndividualId
+	LastSeen time.Time
+	Hangouts int
+}
+
+// Streaks returns the weekly streaks of the individual with everyone they
+// had a hangout with, the longest current streaks first. Planned hangouts are
+// not counted.
+func (s *Service) Streaks(ctx context.Context, username model.IndividualId) ([]PairStreak, error) {
+	now := s.now()
+	pairs, err := s.storage.PairHangoutDates(ctx, username, now)
+	if err != nil {
+		return nil, err
+	}
+
+	streaks := make([]PairStreak, 0, len(pairs))
+	for _, pair := range pairs {
+		if len(pair.Dates) == 0 {
+			continue
+		}
+		current, longest := weeklyStreaks(pair.Dates, now)
+		streaks = append(streaks, PairStreak{
+			With:          pair.With,
+			Deleted:       pair.Deleted,
+			CurrentStreak: current,
+			LongestStreak: longest,
+			DaysSinceLast: int(now.Sub(pair.Dates[len(pair.Dates)-1]) / (24 * time.Hour)),
+		})
+	}
+	slices.SortFunc(streaks, func(a, b PairStreak) int {
+		return cmp.Or(
+			cmp.Compare(b.CurrentStreak, a.CurrentStreak),
+			cmp.Compare(b.LongestStreak, a.LongestStreak),
+			cmp.Compare(a.With, b.With),
+		)
+	})
+	return streaks, nil
+}
+
+// Reminders returns the individuals the individual has not seen for more than
+// the given number of days, the ones they used to see the most first.
+func (s *Service) Reminders(ctx context.Context, username model.IndividualId, days int) ([]Reminder, error) {
+	if days < 1 {
+		return nil, ErrInvalidReminderDays
+	}
+	now := s.now()
+	return s.storage.IndividualsNotSeenSince(ctx, username, now.AddDate(0, 0, -days), now)
+}
+
+// week numbers the weeks since the epoch, starting on Monday in UTC.
+func week(t time.Time) int64 {
+	// the epoch is on a Thursday
+	days := floorDiv(t.Unix(), 24*60*60) + 3
+	return floorDiv(days, 7)
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// weeklyStreaks expects the dates sorted. A streak is still current during the
+// week following its last hangout, it only breaks once a full week is missed.
+func weeklyStreaks(dates []time.Time, now time.Time) (current, longest int) {
+	streak := 0
+	var last int64
+	for i, date := range dates {
+		w := week(date)
+		switch {
+		case i == 0 || w > last+1:
+			streak = 1
+		case w == last+1:
+			streak++
+		}
+		last = w
+		longest = max(longest, streak)
+	}
+
+	if week(now)-last <= 1 {
+		current = streak
+	}
+	return current, longest
+}
@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// day returns a day of March 2025, which starts on a Saturday.
+func day(d int) time.Time {
+	return time.Date(2025, 3, d, 18, 0, 0, 0, time.UTC)
+}
+
+func Test_week_WeeksStartOnMonday(t *testing.T) {
+	sunday := time.Date(2025, 3, 9, 23, 59, 0, 0, time.UTC)
+	monday := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, week(sunday)+1, week(monday), "expected a new week to start on Monday")
+	assert.Equal(t, week(monday), week(day(16)), "expected Monday and Sunday to be in the same week")
+}
+
+func Test_week_BeforeEpoch_IsContinuous(t *testing.T) {
+	sunday := time.Date(1969, 12, 28, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(1969, 12, 29, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, week(sunday)+1, week(monday), "expected a new week to start on Monday before the epoch")
+}
+
+func Test_weeklyStreaks(t *testing.T) {
+	tc := []struct {
+		name    string
+		dates   []time.Time
+		now     time.Time
+		current int
+		longest int
+	}{
+		{
+			name:    "single hangout this week",
+			dates:   []time.Time{day(10)},
+			now:     day(12),
+			current: 1,
+			longest: 1,
+		},
+		{
+			name:    "several hangouts in the same week count once",
+			dates:   []time.Time{day(10), day(11), day(16)},
+			now:     day(16),
+			current: 1,
+			longest: 1,
+		},
+		{
+			name:    "consecutive weeks",
+			dates:   []time.Time{day(2), day(3), day(12), day(17)},
+			now:     day(18),
+			current: 4,
+			longest: 4,
+		},
+		{
+			name:    "streak is kept during the following week",
+			dates:   []time.Time{day(3), day(10)},
+			now:     day(21),
+			current: 2,
+			longest: 2,
+		},
+		{
+			name:    "streak breaks after a missed week",
+			dates:   []time.Time{day(3), day(10)},
+			now:     day(24),
+			current: 0,
+			longest: 2,
+		},
+		{
+			name:    "longest streak in the past",
+			dates:   []time.Time{day(1), day(3), day(10), day(24)},
+			now:     day(25),
+			current: 1,
+			longest: 3,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			current, longest := weeklyStreaks(tt.dates, tt.now)
+			assert.Equal(t, tt.current, current, "expected the current streak to match")
+			assert.Equal(t, tt.longest, longest, "expected the longest streak to match")
+		})
+	}
+}
+
+func Test_Service_Streaks_SortsByCurrentStreak(t *testing.T) {
+	store := &recordingStorage{
+		dates: []PairDates{
+			{With: "bob", Dates: []time.Time{day(1), day(3), day(10)}},
+			{With: "carol", Dates: []time.Time{day(17), day(24)}},
+		},
+	}
+	service := NewService(store)
+	service.now = func() time.Time { return day(25) }
+
+	streaks, err := service.Streaks(t.Context(), "alice")
+	require.NoError(t, err, "expected no error when computing streaks")
+	assert.Equal(t, day(25), store.to, "expected planned hangouts not to be counted")
+	assert.Equal(t, []PairStreak{
+		{With: "carol", CurrentStreak: 2, LongestStreak: 2, DaysSinceLast: 1},
+		{With: "bob", CurrentStreak: 0, LongestStreak: 3, DaysSinceLast: 15},
+	}, streaks, "expected ongoing streaks first")
+}
+
+func Test_Service_Reminders_QueriesTheDays(t *testing.T) {
+	store := &recordingStorage{}
+	service := NewService(store)
+	service.now = func() time.Time { return day(31) }
+
+	_, err := service.Reminders(t.Context(), "alice", 30)
+	require.NoError(t, err, "expected no error when computing reminders")
+	assert.Equal(t, day(1), store.from, "expected individuals not seen in the last 30 days")
+	assert.Equal(t, day(31), store.to, "expected planned hangouts not to be counted")
+}
+
+func Test_Service_Reminders_InvalidDays_ReturnsError(t *testing.T) {
+	service := NewService(&recordingStorage{})
+
+	_, err := service.Reminders(t.Context(), "alice", 0)
+	assert.ErrorIs(t, err, ErrInvalidReminderDays)
+}
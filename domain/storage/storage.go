@@ -3,23 +3,74 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Ozoniuss/hangcounts/domain/model"
 )
 
 type AppStorage interface {
 	StoreIndividual(context.Context, model.Individual) error
-	GetIndividual(context.Context, model.IndividualId) error
+	GetIndividual(context.Context, model.IndividualId) (model.Individual, error)
 	MarkIndividualAsDeleted(context.Context, model.IndividualId) error
+	// EraseIndividual irreversibly replaces the personal data of the
+	// individual with tombstone values and removes their credentials and
+	// sessions. Their participations are kept, so that the hangouts of the
+	// other participants stay the same, while the hangouts they created lose
+	// their creator.
+	EraseIndividual(context.Context, model.IndividualId) error
 	StoreHangoutOfIndividuals(context.Context, model.Hangout) error
-	UpdateHangoutDetails(context.Context, model.HangoutId, model.HangoutDetails)
-	UpdateHangoutParticipants(context.Context, model.HangoutId, []model.IndividualId)
+	GetHangout(context.Context, model.HangoutId) (model.Hangout, error)
+	// ListHangoutsForIndividual returns the hangouts of an individual, most
+	// recent first. The next page starts after the cursor of the previous one.
+	ListHangoutsForIndividual(ctx context.Context, username model.IndividualId, filter HangoutFilter, after *HangoutCursor, limit int) (HangoutPage, error)
+	UpdateHangoutDetails(context.Context, model.HangoutId, model.HangoutDetails) error
+	// UpdateHangoutParticipants replaces the participants of the hangout, as
+	// long as they are still the expected ones. Individuals that deleted their
+	// account may stay participants, but cannot be added.
+	UpdateHangoutParticipants(ctx context.Context, hangoutId model.HangoutId, expected, participants []model.IndividualId) error
+	// GetHangoutCreator also returns the creator of soft-deleted hangouts.
+	GetHangoutCreator(context.Context, model.HangoutId) (model.IndividualId, error)
+	// IsHangoutParticipant tells whether the individual currently takes part
+	// in the hangout, soft-deleted or not.
+	IsHangoutParticipant(context.Context, model.HangoutId, model.IndividualId) (bool, error)
+	// MarkHangoutAsDeleted hides the hangout from reads, listings and
+	// statistics until it is restored or purged. The participants are kept
+	// as they are.
+	MarkHangoutAsDeleted(context.Context, model.HangoutId) error
+	RestoreHangout(context.Context, model.HangoutId) error
+	// RemoveHangoutParticipant soft-deletes the participation of the
+	// individual, the hangout stays in the history of the other participants.
+	RemoveHangoutParticipant(context.Context, model.HangoutId, model.IndividualId) error
+}
+
+// HangoutFilter narrows down a list of hangouts. The zero value does not
+// filter anything.
+type HangoutFilter struct {
+	// From is inclusive, To is exclusive.
+	From time.Time
+	To   time.Time
+	// With only keeps the hangouts this individual also took part in.
+	With model.IndividualId
+}
+
+// HangoutCursor is the position of a hangout in a list ordered by date. The
+// public id breaks ties between hangouts on the same date.
+type HangoutCursor struct {
+	Date     time.Time
+	PublicId model.HangoutId
+}
+
+type HangoutPage struct {
+	Hangouts []model.Hangout
+	// Next is nil on the last page.
+	Next *HangoutCursor
 }
 
 // generic
 var ErrAlreadyExists = errors.New("record already exists")
 var ErrNotFound = errors.New("record is not found in database")
 var ErrDeleted = errors.New("record is soft-deleted")
+var ErrNotDeleted = errors.New("record is not soft-deleted")
 var ErrUnknown = errors.New("unknown database error")
 
 // individual errors
@@ -31,5 +82,7 @@ var ErrHangoutCreatorNotFound = errors.New("hangout creator not found in databas
 var ErrHangoutCreatorDeleted = errors.New("hangout creator is deleted")
 var ErrHangoutParticipantNotFound = errors.New("hangout participant not found in database")
 var ErrHangoutParticipantDeleted = errors.New("hangout participant is deleted")
+var ErrHangoutParticipantsChanged = errors.New("hangout participants changed in the meantime")
 var ErrParticipantHangoutNotFound = errors.New("hangout not found when inserting a participant")
 var ErrParticipantIndividualNotFound = errors.New("individual not found when inserting a participant")
+var ErrNotParticipant = errors.New("individual is not a participant of the hangout")
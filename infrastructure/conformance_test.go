@@ -0,0 +1,1330 @@
+package infrastructure
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/retention"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// conformanceStore is the behaviour shared by all the storage backends.
+type conformanceStore interface {
+	storage.AppStorage
+	stats.Storage
+	export.Storage
+	retention.Storage
+	session.SessionStorage
+	auth.CredentialStorage
+	auth.MagicLinkStorage
+}
+
+// StoreConformanceSuite runs the same tests against every backend, to make
+// sure they return the same errors in the same situations.
+type StoreConformanceSuite struct {
+	suite.Suite
+	newStore func() conformanceStore
+	store    conformanceStore
+}
+
+func TestInMemoryStoreConformance(t *testing.T) {
+	suite.Run(t, &StoreConformanceSuite{
+		newStore: func() conformanceStore { return NewInMemoryStore() },
+	})
+}
+
+func TestPostgresStoreConformance(t *testing.T) {
+	if os.Getenv("HANGCOUNTS_RUN_INTEGRATION_TESTS") != "true" {
+		t.Skipf("Skipping integration tests, HANGCOUNTS_RUN_INTEGRATION_TESTS is not true")
+	}
+
+	pg, err := newTestPostgresStore(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	if err != nil {
+		t.Fatalf("could not connect to database: %s", err)
+	}
+	defer pg.Close()
+
+	suite.Run(t, &StoreConformanceSuite{
+		newStore: func() conformanceStore {
+			if err := truncateTables(pg); err != nil {
+				t.Fatalf("could not truncate tables: %s", err)
+			}
+			return pg
+		},
+	})
+}
+
+func (suite *StoreConformanceSuite) SetupTest() {
+	suite.store = suite.newStore()
+}
+
+func (suite *StoreConformanceSuite) addIndividual(username string) model.Individual {
+	suite.T().Helper()
+	individual := model.Individual{
+		Username: model.IndividualId(username),
+		Name:     "name",
+		Email:    model.Email(username + "@example.com"),
+	}
+	err := suite.store.StoreIndividual(suite.T().Context(), individual)
+	suite.Require().NoError(err, "expected no error when storing individual")
+	return individual
+}
+
+func (suite *StoreConformanceSuite) deleteIndividual(username model.IndividualId) {
+	suite.T().Helper()
+	err := suite.store.MarkIndividualAsDeleted(suite.T().Context(), username)
+	suite.Require().NoError(err, "expected no error when deleting individual")
+}
+
+func (suite *StoreConformanceSuite) addSession(username model.IndividualId) session.Session {
+	suite.T().Helper()
+	sesh, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	err = suite.store.StoreSession(suite.T().Context(), sesh)
+	suite.Require().NoError(err, "expected no error when storing session")
+	return sesh
+}
+
+func newConformanceHangout(creator model.IndividualId, participants ...model.IndividualId) model.Hangout {
+	description := "description"
+	return model.Hangout{
+		PublicId: model.HangoutId(uuid.New()),
+		HangoutDetails: model.HangoutDetails{
+			Description: &description,
+			Location:    "location",
+			Duration:    10,
+			Date:        time.Now(),
+		},
+		CreatedBy:   creator,
+		Individuals: participants,
+	}
+}
+
+func (suite *StoreConformanceSuite) TestGetIndividual_ReturnsStoredIndividual() {
+	individual := suite.addIndividual("alice")
+
+	got, err := suite.store.GetIndividual(suite.T().Context(), individual.Username)
+	suite.Require().NoError(err, "expected no error when retrieving individual")
+	assert.Equal(suite.T(), individual, got, "expected stored and retrieved individual to match")
+}
+
+func (suite *StoreConformanceSuite) TestGetIndividual_ReturnsError_WhenNotFound() {
+	_, err := suite.store.GetIndividual(suite.T().Context(), "alice")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected not found error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestGetIndividual_ReturnsError_WhenDeleted() {
+	individual := suite.addIndividual("alice")
+	suite.deleteIndividual(individual.Username)
+
+	_, err := suite.store.GetIndividual(suite.T().Context(), individual.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrDeleted, "expected deleted error for soft-deleted individual")
+}
+
+func (suite *StoreConformanceSuite) TestStoreIndividual_ReturnsError_WhenUsernameIsTaken() {
+	individual := suite.addIndividual("alice")
+	individual.Email = "other@example.com"
+
+	err := suite.store.StoreIndividual(suite.T().Context(), individual)
+	assert.ErrorIs(suite.T(), err, storage.ErrIndividualUsernameAlreadyExists, "expected error if username is taken")
+}
+
+func (suite *StoreConformanceSuite) TestStoreIndividual_ReturnsError_WhenEmailIsTaken() {
+	individual := suite.addIndividual("alice")
+	individual.Username = "other"
+
+	err := suite.store.StoreIndividual(suite.T().Context(), individual)
+	assert.ErrorIs(suite.T(), err, storage.ErrIndividualEmailAlreadyExists, "expected error if email is taken")
+}
+
+func (suite *StoreConformanceSuite) TestStoreIndividual_ReturnsError_WhenDeletedIndividualHasTheUsername() {
+	individual := suite.addIndividual("alice")
+	suite.deleteIndividual(individual.Username)
+	individual.Email = "other@example.com"
+
+	err := suite.store.StoreIndividual(suite.T().Context(), individual)
+	assert.ErrorIs(suite.T(), err, storage.ErrIndividualUsernameAlreadyExists, "expected soft-deleted usernames to stay taken")
+}
+
+func (suite *StoreConformanceSuite) TestMarkIndividualAsDeleted_ReturnsError_WhenNotFound() {
+	err := suite.store.MarkIndividualAsDeleted(suite.T().Context(), "alice")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected not found error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestMarkIndividualAsDeleted_ReturnsError_WhenAlreadyDeleted() {
+	individual := suite.addIndividual("alice")
+	suite.deleteIndividual(individual.Username)
+
+	err := suite.store.MarkIndividualAsDeleted(suite.T().Context(), individual.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrDeleted, "expected deleted error when deleting twice")
+}
+
+func (suite *StoreConformanceSuite) TestStoreHangout_ReturnsNoError_WhenEveryoneExists() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), newConformanceHangout(alice.Username, alice.Username, bob.Username))
+	assert.NoError(suite.T(), err, "expected no error when storing a valid hangout")
+}
+
+func (suite *StoreConformanceSuite) TestStoreHangout_ReturnsError_WhenCreatorDoesntExist() {
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), newConformanceHangout("alice"))
+	assert.ErrorIs(suite.T(), err, storage.ErrHangoutCreatorNotFound, "expected error when creator doesn't exist")
+}
+
+func (suite *StoreConformanceSuite) TestStoreHangout_ReturnsError_WhenCreatorIsDeleted() {
+	alice := suite.addIndividual("alice")
+	suite.deleteIndividual(alice.Username)
+
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), newConformanceHangout(alice.Username))
+	assert.ErrorIs(suite.T(), err, storage.ErrHangoutCreatorDeleted, "expected error when creator is deleted")
+}
+
+func (suite *StoreConformanceSuite) TestStoreHangout_ReturnsError_WhenParticipantDoesntExist() {
+	alice := suite.addIndividual("alice")
+
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), newConformanceHangout(alice.Username, alice.Username, "bob"))
+	assert.ErrorIs(suite.T(), err, storage.ErrHangoutParticipantNotFound, "expected error when participant doesn't exist")
+}
+
+func (suite *StoreConformanceSuite) TestStoreHangout_ReturnsError_WhenParticipantIsDeleted() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	suite.deleteIndividual(bob.Username)
+
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), newConformanceHangout(alice.Username, bob.Username))
+	assert.ErrorIs(suite.T(), err, storage.ErrHangoutParticipantDeleted, "expected error when participant is deleted")
+}
+
+func (suite *StoreConformanceSuite) TestStoreHangout_ReturnsError_WhenPublicIdExists() {
+	alice := suite.addIndividual("alice")
+	hangout := newConformanceHangout(alice.Username, alice.Username)
+
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), hangout)
+	suite.Require().NoError(err, "expected no error when storing hangout")
+
+	err = suite.store.StoreHangoutOfIndividuals(suite.T().Context(), hangout)
+	assert.ErrorIs(suite.T(), err, storage.ErrAlreadyExists, "expected error when public id is reused")
+}
+
+func (suite *StoreConformanceSuite) TestStoreHangout_StoresNothing_WhenAParticipantIsInvalid() {
+	alice := suite.addIndividual("alice")
+	hangout := newConformanceHangout(alice.Username, alice.Username, "bob")
+
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), hangout)
+	suite.Require().ErrorIs(err, storage.ErrHangoutParticipantNotFound, "expected error when participant doesn't exist")
+
+	hangout.Individuals = []model.IndividualId{alice.Username}
+	err = suite.store.StoreHangoutOfIndividuals(suite.T().Context(), hangout)
+	assert.NoError(suite.T(), err, "expected the failed hangout to not have been stored")
+}
+
+func (suite *StoreConformanceSuite) TestStoreSession_ReturnsError_WhenUserDoesntExist() {
+	sesh, err := session.NewSessionForUser("alice")
+	suite.Require().NoError(err, "expected no error when defining session")
+
+	err = suite.store.StoreSession(suite.T().Context(), sesh)
+	assert.ErrorIs(suite.T(), err, session.ErrUserNotFound, "expected error when user doesn't exist")
+}
+
+func (suite *StoreConformanceSuite) TestStoreSession_ReturnsError_WhenUserIsDeleted() {
+	alice := suite.addIndividual("alice")
+	suite.deleteIndividual(alice.Username)
+	sesh, err := session.NewSessionForUser(alice.Username)
+	suite.Require().NoError(err, "expected no error when defining session")
+
+	err = suite.store.StoreSession(suite.T().Context(), sesh)
+	assert.ErrorIs(suite.T(), err, session.ErrUserDeleted, "expected error when user is deleted")
+}
+
+func (suite *StoreConformanceSuite) TestStoreSession_ReturnsError_WhenCookieIsTooLong() {
+	alice := suite.addIndividual("alice")
+	sesh, err := session.NewSessionForUser(alice.Username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	sesh.CookieValue = strings.Repeat("a", 45)
+
+	err = suite.store.StoreSession(suite.T().Context(), sesh)
+	assert.ErrorIs(suite.T(), err, session.ErrCookieInvalidLength, "expected error when cookie is longer than 44 characters")
+}
+
+func (suite *StoreConformanceSuite) TestGetSession_ReturnsStoredSession() {
+	alice := suite.addIndividual("alice")
+	sesh := suite.addSession(alice.Username)
+
+	got, err := suite.store.GetSession(suite.T().Context(), sesh.CookieValue)
+	suite.Require().NoError(err, "expected no error when retrieving session")
+	assert.Equal(suite.T(), sesh.CookieValue, got.CookieValue, "expected the same cookie")
+	assert.Equal(suite.T(), alice.Username, got.UserID, "expected session to belong to the user")
+	assert.WithinDuration(suite.T(), sesh.CreatedAt, got.CreatedAt, time.Millisecond, "expected the same creation time")
+	assert.WithinDuration(suite.T(), sesh.LastAccessed, got.LastAccessed, time.Millisecond, "expected the same last access time")
+}
+
+func (suite *StoreConformanceSuite) TestGetSession_ReturnsError_WhenNotFound() {
+	_, err := suite.store.GetSession(suite.T().Context(), "unknown")
+	assert.ErrorIs(suite.T(), err, session.ErrNotFound, "expected error for unknown session")
+}
+
+func (suite *StoreConformanceSuite) TestGetSession_ReturnsError_WhenUserIsDeleted() {
+	alice := suite.addIndividual("alice")
+	sesh := suite.addSession(alice.Username)
+	suite.deleteIndividual(alice.Username)
+
+	_, err := suite.store.GetSession(suite.T().Context(), sesh.CookieValue)
+	assert.ErrorIs(suite.T(), err, session.ErrUserDeleted, "expected error when the session user is deleted")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateLastAccessed_UpdatesTheSession() {
+	alice := suite.addIndividual("alice")
+	sesh := suite.addSession(alice.Username)
+	lastAccessed := sesh.LastAccessed.Add(time.Minute)
+
+	err := suite.store.UpdateLastAccessed(suite.T().Context(), sesh.CookieValue, lastAccessed)
+	suite.Require().NoError(err, "expected no error when updating last access")
+
+	got, err := suite.store.GetSession(suite.T().Context(), sesh.CookieValue)
+	suite.Require().NoError(err, "expected no error when retrieving session")
+	assert.WithinDuration(suite.T(), lastAccessed, got.LastAccessed, time.Millisecond, "expected last access to be updated")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateLastAccessed_ReturnsError_WhenNotFound() {
+	err := suite.store.UpdateLastAccessed(suite.T().Context(), "unknown", time.Now())
+	assert.ErrorIs(suite.T(), err, session.ErrNotFound, "expected error for unknown session")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateLastAccessed_ReturnsError_WhenUserIsDeleted() {
+	alice := suite.addIndividual("alice")
+	sesh := suite.addSession(alice.Username)
+	suite.deleteIndividual(alice.Username)
+
+	err := suite.store.UpdateLastAccessed(suite.T().Context(), sesh.CookieValue, time.Now())
+	assert.ErrorIs(suite.T(), err, session.ErrUserDeleted, "expected error when the session user is deleted")
+}
+
+func (suite *StoreConformanceSuite) TestDeleteSession_ReturnsError_WhenNotFound() {
+	err := suite.store.DeleteSession(suite.T().Context(), "unknown")
+	assert.ErrorIs(suite.T(), err, session.ErrNotFound, "expected error for unknown session")
+}
+
+func (suite *StoreConformanceSuite) TestDeleteSessionsOfUser_DeletesOnlyTheSessionsOfTheUser() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	suite.addSession(alice.Username)
+	suite.addSession(alice.Username)
+	bobSession := suite.addSession(bob.Username)
+
+	removed, err := suite.store.DeleteSessionsOfUser(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error when deleting sessions")
+	assert.Equal(suite.T(), int64(2), removed, "expected both sessions of the user to be deleted")
+
+	_, err = suite.store.GetSession(suite.T().Context(), bobSession.CookieValue)
+	assert.NoError(suite.T(), err, "expected sessions of other users to be kept")
+}
+
+func (suite *StoreConformanceSuite) TestDeleteSessionsOfUser_ReturnsError_WhenUserDoesntExist() {
+	_, err := suite.store.DeleteSessionsOfUser(suite.T().Context(), "alice")
+	assert.ErrorIs(suite.T(), err, session.ErrUserNotFound, "expected error when user doesn't exist")
+}
+
+func (suite *StoreConformanceSuite) TestDeleteOtherSessionsOfUser_KeepsTheGivenSession() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	kept := suite.addSession(alice.Username)
+	suite.addSession(alice.Username)
+	bobSession := suite.addSession(bob.Username)
+
+	removed, err := suite.store.DeleteOtherSessionsOfUser(suite.T().Context(), alice.Username, kept.CookieValue)
+	suite.Require().NoError(err, "expected no error when deleting sessions")
+	assert.Equal(suite.T(), int64(1), removed, "expected the other session of the user to be deleted")
+
+	_, err = suite.store.GetSession(suite.T().Context(), kept.CookieValue)
+	assert.NoError(suite.T(), err, "expected the given session to be kept")
+	_, err = suite.store.GetSession(suite.T().Context(), bobSession.CookieValue)
+	assert.NoError(suite.T(), err, "expected sessions of other users to be kept")
+}
+
+func (suite *StoreConformanceSuite) TestRotateSession_ReplacesTheSession() {
+	alice := suite.addIndividual("alice")
+	old := suite.addSession(alice.Username)
+	rotated, err := session.NewSessionForUser(alice.Username)
+	suite.Require().NoError(err, "expected no error when defining session")
+
+	err = suite.store.RotateSession(suite.T().Context(), old.CookieValue, rotated)
+	suite.Require().NoError(err, "expected no error when rotating session")
+
+	_, err = suite.store.GetSession(suite.T().Context(), old.CookieValue)
+	assert.ErrorIs(suite.T(), err, session.ErrNotFound, "expected old session to be gone")
+	got, err := suite.store.GetSession(suite.T().Context(), rotated.CookieValue)
+	suite.Require().NoError(err, "expected new session to exist")
+	assert.Equal(suite.T(), alice.Username, got.UserID, "expected new session to belong to the same user")
+}
+
+func (suite *StoreConformanceSuite) TestRotateSession_ReturnsError_WhenNotFound() {
+	rotated, err := session.NewSessionForUser("alice")
+	suite.Require().NoError(err, "expected no error when defining session")
+
+	err = suite.store.RotateSession(suite.T().Context(), "unknown", rotated)
+	assert.ErrorIs(suite.T(), err, session.ErrNotFound, "expected error for unknown session")
+}
+
+func (suite *StoreConformanceSuite) TestRotateSession_KeepsOldSession_WhenNewCookieIsTooLong() {
+	alice := suite.addIndividual("alice")
+	old := suite.addSession(alice.Username)
+	rotated, err := session.NewSessionForUser(alice.Username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	rotated.CookieValue = strings.Repeat("a", 45)
+
+	err = suite.store.RotateSession(suite.T().Context(), old.CookieValue, rotated)
+	suite.Require().ErrorIs(err, session.ErrCookieInvalidLength, "expected error when cookie is longer than 44 characters")
+
+	_, err = suite.store.GetSession(suite.T().Context(), old.CookieValue)
+	assert.NoError(suite.T(), err, "expected old session to be kept after a failed rotation")
+}
+
+func (suite *StoreConformanceSuite) TestCountActiveSessions_IgnoresExpiredSessions() {
+	alice := suite.addIndividual("alice")
+	suite.addSession(alice.Username)
+	idle := suite.addSession(alice.Username)
+	err := suite.store.UpdateLastAccessed(suite.T().Context(), idle.CookieValue, time.Now().Add(-time.Hour))
+	suite.Require().NoError(err, "expected no error when updating last access")
+
+	count, err := suite.store.CountActiveSessions(suite.T().Context(), time.Now().Add(-time.Minute), time.Now().Add(-time.Hour))
+	suite.Require().NoError(err, "expected no error when counting sessions")
+	assert.Equal(suite.T(), int64(1), count, "expected the idle session to not be counted")
+
+	count, err = suite.store.CountActiveSessions(suite.T().Context(), time.Now().Add(-2*time.Hour), time.Now().Add(time.Minute))
+	suite.Require().NoError(err, "expected no error when counting sessions")
+	assert.Equal(suite.T(), int64(0), count, "expected sessions past the absolute expiration to not be counted")
+}
+
+func (suite *StoreConformanceSuite) TestDeleteExpiredSessions_RespectsTheLimit() {
+	alice := suite.addIndividual("alice")
+	for range 3 {
+		suite.addSession(alice.Username)
+	}
+	fresh := suite.addSession(alice.Username)
+	err := suite.store.UpdateLastAccessed(suite.T().Context(), fresh.CookieValue, time.Now().Add(time.Hour))
+	suite.Require().NoError(err, "expected no error when updating last access")
+
+	idleBefore := time.Now().Add(time.Minute)
+	createdBefore := time.Now().Add(-time.Hour)
+
+	removed, err := suite.store.DeleteExpiredSessions(suite.T().Context(), idleBefore, createdBefore, 2)
+	suite.Require().NoError(err, "expected no error when deleting expired sessions")
+	assert.Equal(suite.T(), int64(2), removed, "expected the batch to be limited")
+
+	removed, err = suite.store.DeleteExpiredSessions(suite.T().Context(), idleBefore, createdBefore, 2)
+	suite.Require().NoError(err, "expected no error when deleting expired sessions")
+	assert.Equal(suite.T(), int64(1), removed, "expected the remaining expired session to be deleted")
+
+	_, err = suite.store.GetSession(suite.T().Context(), fresh.CookieValue)
+	assert.NoError(suite.T(), err, "expected the active session to be kept")
+}
+
+func (suite *StoreConformanceSuite) TestListSessionsOfIndividual_ReturnsSessionsOfTheIndividual_MostRecentlyAccessedFirst() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	older := suite.addSession(alice.Username)
+	newer := suite.addSession(alice.Username)
+	suite.addSession(bob.Username)
+	err := suite.store.UpdateLastAccessed(suite.T().Context(), older.CookieValue, time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC))
+	suite.Require().NoError(err, "expected no error when updating last access")
+	err = suite.store.UpdateLastAccessed(suite.T().Context(), newer.CookieValue, time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC))
+	suite.Require().NoError(err, "expected no error when updating last access")
+
+	got, err := suite.store.ListSessionsOfIndividual(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error when listing sessions")
+	suite.Require().Len(got, 2, "expected only the sessions of the individual")
+	assert.Equal(suite.T(), []time.Time{
+		time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC),
+	}, []time.Time{got[0].LastAccessed.UTC(), got[1].LastAccessed.UTC()}, "expected the most recently accessed session first")
+}
+
+func (suite *StoreConformanceSuite) TestListSessionsOfIndividual_ReturnsSessions_WhenIndividualIsDeleted() {
+	alice := suite.addIndividual("alice")
+	suite.addSession(alice.Username)
+	suite.deleteIndividual(alice.Username)
+
+	got, err := suite.store.ListSessionsOfIndividual(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error for deleted individual")
+	assert.Len(suite.T(), got, 1, "expected the sessions kept for the restore")
+
+	_, err = suite.store.ListSessionsOfIndividual(suite.T().Context(), "bob")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestGetIndividualForExport_ReturnsDeletedIndividuals() {
+	alice := suite.addIndividual("alice")
+
+	got, deletedAt, err := suite.store.GetIndividualForExport(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error for active individual")
+	assert.Equal(suite.T(), alice, got, "expected the stored individual")
+	assert.Nil(suite.T(), deletedAt, "expected active individual to not be deleted")
+
+	suite.deleteIndividual(alice.Username)
+	got, deletedAt, err = suite.store.GetIndividualForExport(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error for deleted individual")
+	assert.Equal(suite.T(), alice, got, "expected the stored individual")
+	assert.NotNil(suite.T(), deletedAt, "expected the time of the deletion")
+
+	_, _, err = suite.store.GetIndividualForExport(suite.T().Context(), "bob")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestListHangoutsForExport_IncludesLeftAndDeletedHangouts() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	current := suite.addHangoutOn(time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	rejoined := suite.addHangoutOn(time.Date(2025, 3, 3, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	left := suite.addHangoutOn(time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	deleted := suite.addHangoutOn(time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	suite.addHangout(alice.Username, alice.Username)
+
+	err := suite.store.RemoveHangoutParticipant(suite.T().Context(), rejoined.PublicId, bob.Username)
+	suite.Require().NoError(err, "expected no error when leaving hangout")
+	err = suite.store.UpdateHangoutParticipants(suite.T().Context(), rejoined.PublicId, []model.IndividualId{alice.Username}, []model.IndividualId{alice.Username, bob.Username})
+	suite.Require().NoError(err, "expected no error when joining again")
+	err = suite.store.RemoveHangoutParticipant(suite.T().Context(), left.PublicId, bob.Username)
+	suite.Require().NoError(err, "expected no error when leaving hangout")
+	err = suite.store.MarkHangoutAsDeleted(suite.T().Context(), deleted.PublicId)
+	suite.Require().NoError(err, "expected no error when deleting hangout")
+	suite.deleteIndividual(bob.Username)
+
+	var listed []export.Hangout
+	var after *storage.HangoutCursor
+	for range 4 {
+		page, err := suite.store.ListHangoutsForExport(suite.T().Context(), bob.Username, after, 1)
+		suite.Require().NoError(err, "expected no error when listing hangouts")
+		listed = append(listed, page.Hangouts...)
+		if page.Next == nil {
+			break
+		}
+		after = page.Next
+	}
+
+	suite.Require().Len(listed, 4, "expected every hangout the individual took part in")
+	got := make([]model.Hangout, 0, len(listed))
+	for _, h := range listed {
+		got = append(got, h.Hangout)
+	}
+	assert.Equal(suite.T(), []model.HangoutId{current.PublicId, rejoined.PublicId, left.PublicId, deleted.PublicId}, publicIds(got), "expected most recent hangout first")
+	assert.Nil(suite.T(), listed[0].LeftAt, "expected the current participation")
+	assert.Nil(suite.T(), listed[1].LeftAt, "expected the participation to be current again")
+	assert.NotNil(suite.T(), listed[2].LeftAt, "expected the time the individual left")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username}, listed[2].Individuals, "expected the current participants")
+	assert.Nil(suite.T(), listed[2].DeletedAt, "expected the hangout to not be deleted")
+	assert.NotNil(suite.T(), listed[3].DeletedAt, "expected the time the hangout was deleted")
+
+	_, err = suite.store.ListHangoutsForExport(suite.T().Context(), "carol", nil, 10)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestGetPasswordHash_ReturnsStoredHash() {
+	individual := model.Individual{Username: "alice", Name: "name", Email: "alice@example.com"}
+	err := suite.store.StoreIndividualWithPassword(suite.T().Context(), individual, "hash")
+	suite.Require().NoError(err, "expected no error when storing individual with password")
+
+	hash, err := suite.store.GetPasswordHash(suite.T().Context(), individual.Username)
+	suite.Require().NoError(err, "expected no error when retrieving password hash")
+	assert.Equal(suite.T(), "hash", hash, "expected the stored hash")
+}
+
+func (suite *StoreConformanceSuite) TestStoreIndividualWithPassword_ReturnsError_WhenUsernameIsTaken() {
+	alice := suite.addIndividual("alice")
+	alice.Email = "other@example.com"
+
+	err := suite.store.StoreIndividualWithPassword(suite.T().Context(), alice, "hash")
+	assert.ErrorIs(suite.T(), err, storage.ErrIndividualUsernameAlreadyExists, "expected error if username is taken")
+}
+
+func (suite *StoreConformanceSuite) TestGetPasswordHash_ReturnsError_WhenPasswordIsNotSet() {
+	alice := suite.addIndividual("alice")
+
+	_, err := suite.store.GetPasswordHash(suite.T().Context(), alice.Username)
+	assert.ErrorIs(suite.T(), err, auth.ErrPasswordNotSet, "expected error when individual has no password")
+}
+
+func (suite *StoreConformanceSuite) TestGetPasswordHash_ReturnsError_WhenIndividualIsDeleted() {
+	alice := suite.addIndividual("alice")
+	suite.deleteIndividual(alice.Username)
+
+	_, err := suite.store.GetPasswordHash(suite.T().Context(), alice.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrDeleted, "expected error when individual is deleted")
+}
+
+func (suite *StoreConformanceSuite) TestUpdatePasswordHash_SetsAndReplacesTheHash() {
+	alice := suite.addIndividual("alice")
+
+	err := suite.store.UpdatePasswordHash(suite.T().Context(), alice.Username, "first")
+	suite.Require().NoError(err, "expected no error when setting password hash")
+	err = suite.store.UpdatePasswordHash(suite.T().Context(), alice.Username, "second")
+	suite.Require().NoError(err, "expected no error when replacing password hash")
+
+	hash, err := suite.store.GetPasswordHash(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error when retrieving password hash")
+	assert.Equal(suite.T(), "second", hash, "expected the latest hash")
+}
+
+func (suite *StoreConformanceSuite) TestUpdatePasswordHash_ReturnsError_WhenNotFound() {
+	err := suite.store.UpdatePasswordHash(suite.T().Context(), "alice", "hash")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error when individual doesn't exist")
+}
+
+func (suite *StoreConformanceSuite) TestConsumeMagicLink_ReturnsIndividual_OnlyOnce() {
+	alice := suite.addIndividual("alice")
+
+	userId, err := suite.store.StoreMagicLink(suite.T().Context(), alice.Email, testTokenHash, time.Now().Add(time.Minute))
+	suite.Require().NoError(err, "expected no error when storing magic link")
+	assert.Equal(suite.T(), alice.Username, userId, "expected magic link to belong to the email owner")
+
+	userId, err = suite.store.ConsumeMagicLink(suite.T().Context(), testTokenHash, time.Now())
+	suite.Require().NoError(err, "expected no error when consuming magic link")
+	assert.Equal(suite.T(), alice.Username, userId, "expected magic link to belong to the email owner")
+
+	_, err = suite.store.ConsumeMagicLink(suite.T().Context(), testTokenHash, time.Now())
+	assert.ErrorIs(suite.T(), err, auth.ErrMagicLinkInvalid, "expected magic link to be single use")
+}
+
+func (suite *StoreConformanceSuite) TestConsumeMagicLink_ReturnsError_WhenExpired() {
+	alice := suite.addIndividual("alice")
+
+	_, err := suite.store.StoreMagicLink(suite.T().Context(), alice.Email, testTokenHash, time.Now().Add(-time.Minute))
+	suite.Require().NoError(err, "expected no error when storing magic link")
+
+	_, err = suite.store.ConsumeMagicLink(suite.T().Context(), testTokenHash, time.Now())
+	assert.ErrorIs(suite.T(), err, auth.ErrMagicLinkInvalid, "expected expired magic link to be rejected")
+}
+
+func (suite *StoreConformanceSuite) TestConsumeMagicLink_ReturnsError_WhenIndividualIsDeleted() {
+	alice := suite.addIndividual("alice")
+
+	_, err := suite.store.StoreMagicLink(suite.T().Context(), alice.Email, testTokenHash, time.Now().Add(time.Minute))
+	suite.Require().NoError(err, "expected no error when storing magic link")
+	suite.deleteIndividual(alice.Username)
+
+	_, err = suite.store.ConsumeMagicLink(suite.T().Context(), testTokenHash, time.Now())
+	assert.ErrorIs(suite.T(), err, auth.ErrMagicLinkInvalid, "expected magic link of deleted individual to be rejected")
+}
+
+func (suite *StoreConformanceSuite) TestStoreMagicLink_ReturnsError_WhenEmailIsUnknown() {
+	_, err := suite.store.StoreMagicLink(suite.T().Context(), "alice@example.com", testTokenHash, time.Now().Add(time.Minute))
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown email")
+}
+
+func (suite *StoreConformanceSuite) TestStoreMagicLink_ReturnsError_WhenIndividualIsDeleted() {
+	alice := suite.addIndividual("alice")
+	suite.deleteIndividual(alice.Username)
+
+	_, err := suite.store.StoreMagicLink(suite.T().Context(), alice.Email, testTokenHash, time.Now().Add(time.Minute))
+	assert.ErrorIs(suite.T(), err, storage.ErrDeleted, "expected error for deleted individual")
+}
+
+func (suite *StoreConformanceSuite) addHangout(creator model.IndividualId, participants ...model.IndividualId) model.Hangout {
+	suite.T().Helper()
+	hangout := newConformanceHangout(creator, participants...)
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), hangout)
+	suite.Require().NoError(err, "expected no error when storing hangout")
+	return hangout
+}
+
+func (suite *StoreConformanceSuite) TestUpdateHangoutDetails_ReturnsNoError_WhenHangoutExists() {
+	alice := suite.addIndividual("alice")
+	hangout := suite.addHangout(alice.Username, alice.Username)
+
+	details := hangout.HangoutDetails
+	details.Location = "elsewhere"
+	details.Description = nil
+	err := suite.store.UpdateHangoutDetails(suite.T().Context(), hangout.PublicId, details)
+	assert.NoError(suite.T(), err, "expected no error when updating hangout details")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateHangoutDetails_ReturnsError_WhenHangoutDoesntExist() {
+	hangout := newConformanceHangout("alice")
+
+	err := suite.store.UpdateHangoutDetails(suite.T().Context(), hangout.PublicId, hangout.HangoutDetails)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown hangout")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateHangoutParticipants_ReturnsNoError_WhenParticipantsExist() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username, bob.Username}, []model.IndividualId{alice.Username, carol.Username, carol.Username})
+	assert.NoError(suite.T(), err, "expected no error when replacing participants")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateHangoutParticipants_ReturnsError_WhenHangoutDoesntExist() {
+	alice := suite.addIndividual("alice")
+	hangout := newConformanceHangout(alice.Username)
+
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username}, []model.IndividualId{alice.Username})
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown hangout")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateHangoutParticipants_ReturnsError_WhenParticipantDoesntExist() {
+	alice := suite.addIndividual("alice")
+	hangout := suite.addHangout(alice.Username, alice.Username)
+
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username}, []model.IndividualId{alice.Username, "bob"})
+	assert.ErrorIs(suite.T(), err, storage.ErrHangoutParticipantNotFound, "expected error when participant doesn't exist")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateHangoutParticipants_ReturnsError_WhenAddingDeletedIndividual() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	suite.deleteIndividual(bob.Username)
+	hangout := suite.addHangout(alice.Username, alice.Username)
+
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username}, []model.IndividualId{alice.Username, bob.Username})
+	assert.ErrorIs(suite.T(), err, storage.ErrHangoutParticipantDeleted, "expected error when adding a deleted individual")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateHangoutParticipants_ReturnsError_WhenParticipantsChanged() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+	err := suite.store.RemoveHangoutParticipant(suite.T().Context(), hangout.PublicId, bob.Username)
+	suite.Require().NoError(err, "expected no error when leaving hangout")
+
+	err = suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username, bob.Username}, []model.IndividualId{alice.Username, bob.Username, carol.Username})
+	suite.Require().ErrorIs(err, storage.ErrHangoutParticipantsChanged, "expected error when the participants changed in the meantime")
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when retrieving hangout")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username}, got.Individuals, "expected participants to be unchanged")
+}
+
+func (suite *StoreConformanceSuite) TestUpdateHangoutParticipants_KeepsParticipants_ThatWereDeletedAfterwards() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+	suite.deleteIndividual(bob.Username)
+
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username, bob.Username}, []model.IndividualId{alice.Username, bob.Username, carol.Username})
+	assert.NoError(suite.T(), err, "expected deleted participants to be allowed to stay")
+}
+
+func (suite *StoreConformanceSuite) TestGetHangout_ReturnsStoredHangout() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when retrieving hangout")
+	assert.Equal(suite.T(), hangout.PublicId, got.PublicId, "expected the same public id")
+	assert.Equal(suite.T(), hangout.Location, got.Location, "expected the same location")
+	assert.Equal(suite.T(), hangout.Description, got.Description, "expected the same description")
+	assert.Equal(suite.T(), hangout.Duration, got.Duration, "expected the same duration")
+	assert.WithinDuration(suite.T(), hangout.Date, got.Date, time.Millisecond, "expected the same date")
+	assert.Equal(suite.T(), alice.Username, got.CreatedBy, "expected the same creator")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username, bob.Username}, got.Individuals, "expected participants in insertion order")
+	assert.Empty(suite.T(), got.DeletedIndividuals, "expected no deleted participants")
+}
+
+func (suite *StoreConformanceSuite) TestGetHangout_ReturnsError_WhenNotFound() {
+	_, err := suite.store.GetHangout(suite.T().Context(), model.HangoutId(uuid.New()))
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown hangout")
+}
+
+func (suite *StoreConformanceSuite) TestGetHangout_FlagsDeletedParticipants() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+	suite.deleteIndividual(bob.Username)
+	suite.deleteIndividual(alice.Username)
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected hangouts to outlive the accounts of their participants")
+	assert.Equal(suite.T(), alice.Username, got.CreatedBy, "expected the creator to be kept")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username, bob.Username}, got.Individuals, "expected deleted participants to be kept")
+	assert.ElementsMatch(suite.T(), []model.IndividualId{alice.Username, bob.Username}, got.DeletedIndividuals, "expected deleted participants to be flagged")
+}
+
+func (suite *StoreConformanceSuite) TestGetHangout_ReturnsUpdatedDetails() {
+	alice := suite.addIndividual("alice")
+	hangout := suite.addHangout(alice.Username, alice.Username)
+
+	details := hangout.HangoutDetails
+	details.Location = "elsewhere"
+	details.Description = nil
+	details.Duration = 20
+	err := suite.store.UpdateHangoutDetails(suite.T().Context(), hangout.PublicId, details)
+	suite.Require().NoError(err, "expected no error when updating hangout details")
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when retrieving hangout")
+	assert.Equal(suite.T(), "elsewhere", got.Location, "expected location to be updated")
+	assert.Nil(suite.T(), got.Description, "expected description to be cleared")
+	assert.Equal(suite.T(), model.Minutes(20), got.Duration, "expected duration to be updated")
+}
+
+func (suite *StoreConformanceSuite) TestGetHangout_ReturnsUpdatedParticipants() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username, bob.Username}, []model.IndividualId{alice.Username, carol.Username, carol.Username})
+	suite.Require().NoError(err, "expected no error when replacing participants")
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when retrieving hangout")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username, carol.Username}, got.Individuals, "expected participants to be replaced")
+}
+
+func (suite *StoreConformanceSuite) TestGetHangout_IsUnchanged_AfterFailedParticipantsUpdate() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username, bob.Username}, []model.IndividualId{alice.Username, "dave"})
+	suite.Require().ErrorIs(err, storage.ErrHangoutParticipantNotFound, "expected error when participant doesn't exist")
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when retrieving hangout")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username, bob.Username}, got.Individuals, "expected participants to be unchanged")
+}
+
+func (suite *StoreConformanceSuite) addHangoutOn(date time.Time, creator model.IndividualId, participants ...model.IndividualId) model.Hangout {
+	suite.T().Helper()
+	hangout := newConformanceHangout(creator, participants...)
+	hangout.Date = date
+	err := suite.store.StoreHangoutOfIndividuals(suite.T().Context(), hangout)
+	suite.Require().NoError(err, "expected no error when storing hangout")
+	return hangout
+}
+
+func publicIds(hangouts []model.Hangout) []model.HangoutId {
+	ids := make([]model.HangoutId, 0, len(hangouts))
+	for _, h := range hangouts {
+		ids = append(ids, h.PublicId)
+	}
+	return ids
+}
+
+func (suite *StoreConformanceSuite) TestListHangoutsForIndividual_PagesThroughHangouts_OnTheSameDate() {
+	alice := suite.addIndividual("alice")
+	date := time.Date(2025, 3, 16, 18, 0, 0, 0, time.UTC)
+	var stored []model.Hangout
+	for range 5 {
+		stored = append(stored, suite.addHangoutOn(date, alice.Username, alice.Username))
+	}
+
+	var listed []model.Hangout
+	var after *storage.HangoutCursor
+	for range len(stored) {
+		page, err := suite.store.ListHangoutsForIndividual(suite.T().Context(), alice.Username, storage.HangoutFilter{}, after, 2)
+		suite.Require().NoError(err, "expected no error when listing hangouts")
+		listed = append(listed, page.Hangouts...)
+		if page.Next == nil {
+			break
+		}
+		after = page.Next
+	}
+
+	assert.ElementsMatch(suite.T(), publicIds(stored), publicIds(listed), "expected every hangout exactly once")
+	for i := 1; i < len(listed); i++ {
+		previous := uuid.UUID(listed[i-1].PublicId).String()
+		current := uuid.UUID(listed[i].PublicId).String()
+		assert.Greater(suite.T(), previous, current, "expected ties to be ordered by public id")
+	}
+}
+
+func (suite *StoreConformanceSuite) TestListHangoutsForIndividual_ReturnsMostRecentFirst_WithParticipants() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	older := suite.addHangoutOn(time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username)
+	newer := suite.addHangoutOn(time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	suite.deleteIndividual(bob.Username)
+
+	page, err := suite.store.ListHangoutsForIndividual(suite.T().Context(), alice.Username, storage.HangoutFilter{}, nil, 10)
+	suite.Require().NoError(err, "expected no error when listing hangouts")
+	suite.Require().Equal([]model.HangoutId{newer.PublicId, older.PublicId}, publicIds(page.Hangouts), "expected most recent hangout first")
+	assert.Nil(suite.T(), page.Next, "expected a single page")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username, bob.Username}, page.Hangouts[0].Individuals, "expected participants to be listed")
+	assert.Equal(suite.T(), []model.IndividualId{bob.Username}, page.Hangouts[0].DeletedIndividuals, "expected deleted participants to be flagged")
+	assert.Equal(suite.T(), alice.Username, page.Hangouts[0].CreatedBy, "expected the creator")
+}
+
+func (suite *StoreConformanceSuite) TestListHangoutsForIndividual_AppliesTheFilters() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	first := suite.addHangoutOn(time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	second := suite.addHangoutOn(time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, carol.Username)
+	third := suite.addHangoutOn(time.Date(2025, 3, 3, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+
+	tc := []struct {
+		name   string
+		filter storage.HangoutFilter
+		want   []model.HangoutId
+	}{
+		{name: "with", filter: storage.HangoutFilter{With: bob.Username}, want: []model.HangoutId{third.PublicId, first.PublicId}},
+		{name: "from is inclusive", filter: storage.HangoutFilter{From: second.Date}, want: []model.HangoutId{third.PublicId, second.PublicId}},
+		{name: "to is exclusive", filter: storage.HangoutFilter{To: second.Date}, want: []model.HangoutId{first.PublicId}},
+		{name: "unknown co-participant", filter: storage.HangoutFilter{With: "dave"}, want: []model.HangoutId{}},
+	}
+
+	for _, tt := range tc {
+		suite.Run(tt.name, func() {
+			page, err := suite.store.ListHangoutsForIndividual(suite.T().Context(), alice.Username, tt.filter, nil, 10)
+			suite.Require().NoError(err, "expected no error when listing hangouts")
+			assert.Equal(suite.T(), tt.want, publicIds(page.Hangouts))
+		})
+	}
+}
+
+func (suite *StoreConformanceSuite) TestListHangoutsForIndividual_SkipsHangoutsTheIndividualWasRemovedFrom() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username, bob.Username}, []model.IndividualId{alice.Username})
+	suite.Require().NoError(err, "expected no error when removing participant")
+
+	page, err := suite.store.ListHangoutsForIndividual(suite.T().Context(), bob.Username, storage.HangoutFilter{}, nil, 10)
+	suite.Require().NoError(err, "expected no error when listing hangouts")
+	assert.Empty(suite.T(), page.Hangouts, "expected removed participants to not see the hangout")
+}
+
+func (suite *StoreConformanceSuite) TestListHangoutsForIndividual_ReturnsError_WhenIndividualIsDeleted() {
+	alice := suite.addIndividual("alice")
+	suite.deleteIndividual(alice.Username)
+
+	_, err := suite.store.ListHangoutsForIndividual(suite.T().Context(), alice.Username, storage.HangoutFilter{}, nil, 10)
+	assert.ErrorIs(suite.T(), err, storage.ErrDeleted, "expected error for deleted individual")
+
+	_, err = suite.store.ListHangoutsForIndividual(suite.T().Context(), "bob", storage.HangoutFilter{}, nil, 10)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestPairStatsForIndividual_AggregatesPerCoParticipant() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	dave := suite.addIndividual("dave")
+	suite.addHangoutOn(time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username, carol.Username)
+	suite.addHangoutOn(time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username, bob.Username)
+	suite.addHangoutOn(time.Date(2025, 3, 3, 18, 0, 0, 0, time.UTC), bob.Username, bob.Username, dave.Username)
+	suite.deleteIndividual(carol.Username)
+
+	got, err := suite.store.PairStatsForIndividual(suite.T().Context(), alice.Username, time.Time{}, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	suite.Require().NoError(err, "expected no error when computing statistics")
+	assert.Equal(suite.T(), []stats.PairStats{
+		{With: bob.Username, Hangouts: 2, Minutes: 20},
+		{With: carol.Username, Deleted: true, Hangouts: 1, Minutes: 10},
+	}, got, "expected the hangouts of alice to be counted once per co-participant")
+}
+
+func (suite *StoreConformanceSuite) TestPairStatsForIndividual_OnlyCountsTheWindow() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	suite.addHangoutOn(time.Date(2025, 2, 28, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	suite.addHangoutOn(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	suite.addHangoutOn(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+
+	got, err := suite.store.PairStatsForIndividual(suite.T().Context(), alice.Username, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC))
+	suite.Require().NoError(err, "expected no error when computing statistics")
+	assert.Equal(suite.T(), []stats.PairStats{{With: bob.Username, Hangouts: 1, Minutes: 10}}, got, "expected from to be inclusive and to exclusive")
+}
+
+func (suite *StoreConformanceSuite) TestPairStatsForIndividual_ReturnsError_WhenIndividualDoesntExist() {
+	_, err := suite.store.PairStatsForIndividual(suite.T().Context(), "alice", time.Time{}, time.Now())
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestPairHangoutDates_GroupsDatesPerCoParticipant() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	first := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	second := time.Date(2025, 3, 8, 18, 0, 0, 0, time.UTC)
+	planned := time.Date(2025, 4, 1, 18, 0, 0, 0, time.UTC)
+	suite.addHangoutOn(second, alice.Username, alice.Username, bob.Username, bob.Username)
+	suite.addHangoutOn(first, alice.Username, alice.Username, bob.Username, carol.Username)
+	suite.addHangoutOn(planned, alice.Username, alice.Username, bob.Username)
+	suite.deleteIndividual(carol.Username)
+
+	got, err := suite.store.PairHangoutDates(suite.T().Context(), alice.Username, planned)
+	suite.Require().NoError(err, "expected no error when retrieving hangout dates")
+	for i := range got {
+		for j := range got[i].Dates {
+			got[i].Dates[j] = got[i].Dates[j].UTC()
+		}
+	}
+	assert.Equal(suite.T(), []stats.PairDates{
+		{With: bob.Username, Dates: []time.Time{first, second}},
+		{With: carol.Username, Deleted: true, Dates: []time.Time{first}},
+	}, got, "expected the past hangouts of alice once per co-participant, oldest first")
+}
+
+func (suite *StoreConformanceSuite) TestPairHangoutDates_ReturnsError_WhenIndividualDoesntExist() {
+	_, err := suite.store.PairHangoutDates(suite.T().Context(), "alice", time.Now())
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestIndividualsNotSeenSince_RanksByFrequency() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	dave := suite.addIndividual("dave")
+	erin := suite.addIndividual("erin")
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	suite.addHangoutOn(time.Date(2025, 1, 10, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username, carol.Username, erin.Username)
+	suite.addHangoutOn(time.Date(2025, 1, 20, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, carol.Username, dave.Username)
+	suite.addHangoutOn(time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, dave.Username)
+	suite.addHangoutOn(time.Date(2025, 4, 10, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	suite.deleteIndividual(erin.Username)
+
+	got, err := suite.store.IndividualsNotSeenSince(suite.T().Context(), alice.Username, since, now)
+	suite.Require().NoError(err, "expected no error when computing reminders")
+	for i := range got {
+		got[i].LastSeen = got[i].LastSeen.UTC()
+	}
+	assert.Equal(suite.T(), []stats.Reminder{
+		{With: carol.Username, LastSeen: time.Date(2025, 1, 20, 18, 0, 0, 0, time.UTC), Hangouts: 2},
+		{With: bob.Username, LastSeen: time.Date(2025, 1, 10, 18, 0, 0, 0, time.UTC), Hangouts: 1},
+	}, got, "expected individuals not seen recently, the most frequent first")
+}
+
+func (suite *StoreConformanceSuite) TestIndividualsNotSeenSince_ReturnsError_WhenIndividualIsDeleted() {
+	alice := suite.addIndividual("alice")
+	suite.deleteIndividual(alice.Username)
+
+	_, err := suite.store.IndividualsNotSeenSince(suite.T().Context(), alice.Username, time.Now(), time.Now())
+	assert.ErrorIs(suite.T(), err, storage.ErrDeleted, "expected error for deleted individual")
+}
+
+func (suite *StoreConformanceSuite) deleteHangout(hangoutId model.HangoutId) {
+	suite.T().Helper()
+	err := suite.store.MarkHangoutAsDeleted(suite.T().Context(), hangoutId)
+	suite.Require().NoError(err, "expected no error when deleting hangout")
+}
+
+func (suite *StoreConformanceSuite) TestMarkHangoutAsDeleted_HidesHangout() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	hangout := suite.addHangoutOn(time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	suite.deleteHangout(hangout.PublicId)
+
+	_, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	assert.ErrorIs(suite.T(), err, storage.ErrDeleted, "expected deleted hangout to not be returned")
+
+	page, err := suite.store.ListHangoutsForIndividual(suite.T().Context(), bob.Username, storage.HangoutFilter{}, nil, 10)
+	suite.Require().NoError(err, "expected no error when listing hangouts")
+	assert.Empty(suite.T(), page.Hangouts, "expected deleted hangout to not be listed")
+
+	got, err := suite.store.PairStatsForIndividual(suite.T().Context(), alice.Username, time.Time{}, time.Now())
+	suite.Require().NoError(err, "expected no error when computing statistics")
+	assert.Empty(suite.T(), got, "expected deleted hangout to not be counted")
+
+	err = suite.store.MarkHangoutAsDeleted(suite.T().Context(), hangout.PublicId)
+	assert.ErrorIs(suite.T(), err, storage.ErrDeleted, "expected hangout to be deleted only once")
+}
+
+func (suite *StoreConformanceSuite) TestMarkHangoutAsDeleted_ReturnsError_WhenHangoutDoesntExist() {
+	err := suite.store.MarkHangoutAsDeleted(suite.T().Context(), model.HangoutId(uuid.New()))
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown hangout")
+
+	err = suite.store.RestoreHangout(suite.T().Context(), model.HangoutId(uuid.New()))
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown hangout")
+
+	_, err = suite.store.GetHangoutCreator(suite.T().Context(), model.HangoutId(uuid.New()))
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown hangout")
+}
+
+func (suite *StoreConformanceSuite) TestRestoreHangout_BringsBackHangoutWithItsParticipants() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username, carol.Username)
+	err := suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, []model.IndividualId{alice.Username, bob.Username, carol.Username}, []model.IndividualId{alice.Username, bob.Username})
+	suite.Require().NoError(err, "expected no error when removing participant")
+	suite.deleteHangout(hangout.PublicId)
+
+	creator, err := suite.store.GetHangoutCreator(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected the creator of a deleted hangout to be returned")
+	assert.Equal(suite.T(), alice.Username, creator)
+
+	err = suite.store.RestoreHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when restoring hangout")
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected restored hangout to be returned")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username, bob.Username}, got.Individuals, "expected removed participants to stay removed")
+
+	err = suite.store.RestoreHangout(suite.T().Context(), hangout.PublicId)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotDeleted, "expected hangout to be restored only once")
+}
+
+func (suite *StoreConformanceSuite) TestPurgeDeletedHangouts_RemovesOnlyExpiredHangouts() {
+	alice := suite.addIndividual("alice")
+	kept := suite.addHangout(alice.Username, alice.Username)
+	recent := suite.addHangout(alice.Username, alice.Username)
+	suite.deleteHangout(recent.PublicId)
+	before := time.Now()
+	var expired []model.Hangout
+	for range 3 {
+		expired = append(expired, suite.addHangout(alice.Username, alice.Username))
+	}
+
+	removed, err := suite.store.PurgeDeletedHangouts(suite.T().Context(), time.Now().Add(time.Hour), 10)
+	suite.Require().NoError(err, "expected no error when purging hangouts")
+	assert.Equal(suite.T(), int64(1), removed, "expected only the deleted hangout to be purged")
+	_, err = suite.store.GetHangoutCreator(suite.T().Context(), recent.PublicId)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected purged hangout to be gone")
+
+	for _, h := range expired {
+		suite.deleteHangout(h.PublicId)
+	}
+	removed, err = suite.store.PurgeDeletedHangouts(suite.T().Context(), before, 10)
+	suite.Require().NoError(err, "expected no error when purging hangouts")
+	assert.Equal(suite.T(), int64(0), removed, "expected hangouts deleted after the cutoff to be kept")
+
+	removed, err = suite.store.PurgeDeletedHangouts(suite.T().Context(), time.Now().Add(time.Hour), 2)
+	suite.Require().NoError(err, "expected no error when purging hangouts")
+	assert.Equal(suite.T(), int64(2), removed, "expected the limit to be respected")
+
+	_, err = suite.store.GetHangout(suite.T().Context(), kept.PublicId)
+	assert.NoError(suite.T(), err, "expected hangouts that are not deleted to be kept")
+}
+
+func (suite *StoreConformanceSuite) TestRemoveHangoutParticipant_KeepsTheHangoutForTheOthers() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	hangout := suite.addHangoutOn(time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username, carol.Username)
+
+	err := suite.store.RemoveHangoutParticipant(suite.T().Context(), hangout.PublicId, bob.Username)
+	suite.Require().NoError(err, "expected no error when removing participant")
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when retrieving hangout")
+	assert.Equal(suite.T(), []model.IndividualId{alice.Username, carol.Username}, got.Individuals)
+
+	page, err := suite.store.ListHangoutsForIndividual(suite.T().Context(), bob.Username, storage.HangoutFilter{}, nil, 10)
+	suite.Require().NoError(err, "expected no error when listing hangouts")
+	assert.Empty(suite.T(), page.Hangouts, "expected the hangout to leave the history of bob")
+
+	pairs, err := suite.store.PairStatsForIndividual(suite.T().Context(), alice.Username, time.Time{}, time.Now())
+	suite.Require().NoError(err, "expected no error when computing statistics")
+	assert.Equal(suite.T(), []model.IndividualId{carol.Username}, pairUsernames(pairs), "expected the hangout to still count for the remaining participants")
+
+	err = suite.store.RemoveHangoutParticipant(suite.T().Context(), hangout.PublicId, bob.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotParticipant, "expected participant to be removed only once")
+}
+
+func (suite *StoreConformanceSuite) TestRemoveHangoutParticipant_ReturnsError_WhenNotAParticipant() {
+	alice := suite.addIndividual("alice")
+	suite.addIndividual("bob")
+	hangout := suite.addHangout(alice.Username, alice.Username)
+
+	err := suite.store.RemoveHangoutParticipant(suite.T().Context(), hangout.PublicId, "bob")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotParticipant, "expected error for individual outside the hangout")
+
+	err = suite.store.RemoveHangoutParticipant(suite.T().Context(), hangout.PublicId, "carol")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotParticipant, "expected error for unknown individual")
+
+	err = suite.store.RemoveHangoutParticipant(suite.T().Context(), model.HangoutId(uuid.New()), alice.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown hangout")
+}
+
+func pairUsernames(pairs []stats.PairStats) []model.IndividualId {
+	usernames := make([]model.IndividualId, 0, len(pairs))
+	for _, p := range pairs {
+		usernames = append(usernames, p.With)
+	}
+	return usernames
+}
+
+func (suite *StoreConformanceSuite) TestRestoreIndividual_ReenablesTheAccount() {
+	alice := model.Individual{Username: "alice", Name: "name", Email: "alice@example.com"}
+	err := suite.store.StoreIndividualWithPassword(suite.T().Context(), alice, "hash")
+	suite.Require().NoError(err, "expected no error when storing individual with password")
+	sesh := suite.addSession(alice.Username)
+	before := time.Now().Add(-time.Second)
+	suite.deleteIndividual(alice.Username)
+
+	hash, deletedAt, err := suite.store.GetDeletedPasswordHash(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error when retrieving the hash of a deleted individual")
+	assert.Equal(suite.T(), "hash", hash, "expected the stored hash")
+	assert.True(suite.T(), deletedAt.After(before), "expected the deletion time")
+
+	err = suite.store.RestoreIndividual(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error when restoring individual")
+
+	_, err = suite.store.GetIndividual(suite.T().Context(), alice.Username)
+	assert.NoError(suite.T(), err, "expected restored individual to be returned")
+	_, err = suite.store.GetSession(suite.T().Context(), sesh.CookieValue)
+	assert.NoError(suite.T(), err, "expected the sessions to work again")
+	suite.addHangout(alice.Username, alice.Username)
+
+	err = suite.store.RestoreIndividual(suite.T().Context(), alice.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotDeleted, "expected individual to be restored only once")
+	_, _, err = suite.store.GetDeletedPasswordHash(suite.T().Context(), alice.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotDeleted, "expected no deleted hash for an active individual")
+	err = suite.store.RestoreIndividual(suite.T().Context(), "bob")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown individual")
+}
+
+func (suite *StoreConformanceSuite) TestAnonymiseDeletedIndividuals_KeepsTheHangoutsOfOthers() {
+	alice := suite.addIndividual("alice")
+	bob := model.Individual{Username: "bob", Name: "name", Email: "bob@example.com"}
+	err := suite.store.StoreIndividualWithPassword(suite.T().Context(), bob, "hash")
+	suite.Require().NoError(err, "expected no error when storing individual with password")
+	carol := suite.addIndividual("carol")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+	suite.deleteIndividual(bob.Username)
+	cutoff := time.Now().Add(time.Second)
+	suite.deleteIndividual(carol.Username)
+
+	anonymised, err := suite.store.AnonymiseDeletedIndividuals(suite.T().Context(), cutoff, 10)
+	suite.Require().NoError(err, "expected no error when anonymising individuals")
+	assert.Equal(suite.T(), int64(2), anonymised, "expected the deleted individuals to be anonymised")
+	anonymised, err = suite.store.AnonymiseDeletedIndividuals(suite.T().Context(), cutoff, 10)
+	suite.Require().NoError(err, "expected no error when anonymising individuals")
+	assert.Equal(suite.T(), int64(0), anonymised, "expected individuals to be anonymised only once")
+
+	_, err = suite.store.GetIndividual(suite.T().Context(), bob.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected the username to be gone")
+	err = suite.store.RestoreIndividual(suite.T().Context(), bob.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected anonymised individual to not be restored")
+
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected the hangout to be kept")
+	suite.Require().Len(got.Individuals, 2, "expected the participation to be kept")
+	assert.Equal(suite.T(), got.Individuals[1:], got.DeletedIndividuals, "expected the anonymised participant to be flagged")
+	assert.NotEqual(suite.T(), bob.Username, got.Individuals[1], "expected the participant to be anonymised")
+
+	// the username and the email can be used again
+	suite.addIndividual("bob")
+}
+
+func (suite *StoreConformanceSuite) TestEraseIndividual_KeepsParticipations_AndOrphansCreatedHangouts() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	created := suite.addHangoutOn(time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC), alice.Username, alice.Username, bob.Username)
+	joined := suite.addHangoutOn(time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC), bob.Username, bob.Username, alice.Username)
+	aliceSession := suite.addSession(alice.Username)
+
+	err := suite.store.EraseIndividual(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error when erasing individual")
+
+	_, err = suite.store.GetIndividual(suite.T().Context(), alice.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected the username to be gone")
+	_, err = suite.store.GetSession(suite.T().Context(), aliceSession.CookieValue)
+	assert.ErrorIs(suite.T(), err, session.ErrNotFound, "expected the sessions to be deleted")
+
+	got, err := suite.store.GetHangout(suite.T().Context(), created.PublicId)
+	suite.Require().NoError(err, "expected the created hangout to be kept")
+	assert.Empty(suite.T(), got.CreatedBy, "expected the hangout to lose its creator")
+	assert.Len(suite.T(), got.Individuals, 2, "expected the participation to be kept")
+	_, err = suite.store.GetHangoutCreator(suite.T().Context(), created.PublicId)
+	assert.ErrorIs(suite.T(), err, storage.ErrHangoutCreatorNotFound, "expected no creator")
+
+	got, err = suite.store.GetHangout(suite.T().Context(), joined.PublicId)
+	suite.Require().NoError(err, "expected the joined hangout to be kept")
+	assert.Equal(suite.T(), bob.Username, got.CreatedBy, "expected the creator to be kept")
+	suite.Require().Len(got.Individuals, 2, "expected the participation to be kept")
+	assert.Equal(suite.T(), got.Individuals[1:], got.DeletedIndividuals, "expected the erased participant to be flagged")
+
+	page, err := suite.store.ListHangoutsForIndividual(suite.T().Context(), bob.Username, storage.HangoutFilter{}, nil, 10)
+	suite.Require().NoError(err, "expected no error when listing hangouts")
+	assert.Len(suite.T(), page.Hangouts, 2, "expected the hangouts without creator to be listed")
+	pairs, err := suite.store.PairStatsForIndividual(suite.T().Context(), bob.Username, time.Time{}, time.Now())
+	suite.Require().NoError(err, "expected no error when computing statistics")
+	suite.Require().Len(pairs, 1, "expected the erased individual to still count")
+	assert.Equal(suite.T(), 2, pairs[0].Hangouts, "expected both hangouts to be counted")
+
+	// the username and the email can be used again
+	suite.addIndividual("alice")
+}
+
+func (suite *StoreConformanceSuite) TestIsHangoutParticipant_AlsoChecksDeletedHangouts() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	suite.addIndividual("carol")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username)
+	err := suite.store.RemoveHangoutParticipant(suite.T().Context(), hangout.PublicId, bob.Username)
+	suite.Require().NoError(err, "expected no error when leaving hangout")
+	err = suite.store.MarkHangoutAsDeleted(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when deleting hangout")
+
+	tc := []struct {
+		name       string
+		individual model.IndividualId
+		want       bool
+	}{
+		{name: "participant", individual: alice.Username, want: true},
+		{name: "former participant", individual: bob.Username, want: false},
+		{name: "stranger", individual: "carol", want: false},
+		{name: "unknown individual", individual: "dave", want: false},
+	}
+
+	for _, tt := range tc {
+		suite.Run(tt.name, func() {
+			got, err := suite.store.IsHangoutParticipant(suite.T().Context(), hangout.PublicId, tt.individual)
+			suite.Require().NoError(err, "expected no error when checking participation")
+			assert.Equal(suite.T(), tt.want, got)
+		})
+	}
+
+	_, err = suite.store.IsHangoutParticipant(suite.T().Context(), model.HangoutId(uuid.New()), alice.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected error for unknown hangout")
+}
+
+func (suite *StoreConformanceSuite) TestEraseIndividual_KeepsOrphanedHangoutsEditable() {
+	alice := suite.addIndividual("alice")
+	bob := suite.addIndividual("bob")
+	carol := suite.addIndividual("carol")
+	dave := suite.addIndividual("dave")
+	hangout := suite.addHangout(alice.Username, alice.Username, bob.Username, carol.Username)
+	err := suite.store.EraseIndividual(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error when erasing individual")
+	got, err := suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when reading hangout")
+	erased := got.Individuals[0]
+
+	err = suite.store.UpdateHangoutParticipants(suite.T().Context(), hangout.PublicId, got.Individuals, []model.IndividualId{erased, bob.Username, carol.Username, dave.Username})
+	suite.Require().NoError(err, "expected participants of an orphaned hangout to be updated")
+	err = suite.store.RemoveHangoutParticipant(suite.T().Context(), hangout.PublicId, carol.Username)
+	suite.Require().NoError(err, "expected participants to leave an orphaned hangout")
+	err = suite.store.MarkHangoutAsDeleted(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected an orphaned hangout to be deleted")
+	err = suite.store.RestoreHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected an orphaned hangout to be restored")
+
+	got, err = suite.store.GetHangout(suite.T().Context(), hangout.PublicId)
+	suite.Require().NoError(err, "expected no error when reading hangout")
+	assert.Empty(suite.T(), got.CreatedBy, "expected the hangout to stay without creator")
+	assert.Equal(suite.T(), []model.IndividualId{erased, bob.Username, dave.Username}, got.Individuals, "expected the updated participants")
+}
+
+func (suite *StoreConformanceSuite) TestEraseIndividual_ErasesSoftDeletedIndividuals() {
+	alice := suite.addIndividual("alice")
+	suite.deleteIndividual(alice.Username)
+
+	err := suite.store.EraseIndividual(suite.T().Context(), alice.Username)
+	suite.Require().NoError(err, "expected no error when erasing a soft-deleted individual")
+
+	err = suite.store.RestoreIndividual(suite.T().Context(), alice.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected erased individual to not be restored")
+	err = suite.store.EraseIndividual(suite.T().Context(), alice.Username)
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected the individual to be erased only once")
+}
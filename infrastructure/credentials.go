@@ -0,0 +1,181 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/jackc/pgx/v5"
+)
+
+func (p *PostgresStore) StoreIndividualWithPassword(ctx context.Context, individual model.Individual, passwordHash string) error {
+	tx, err := p.conn().Begin(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	queryIndividual := `
+		INSERT INTO individuals (name, email, username, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;
+	`
+
+	createdAt := time.Now()
+	row := tx.QueryRow(ctx, queryIndividual, individual.Name, individual.Email, individual.Username, createdAt)
+	var id int
+	if err := row.Scan(&id); err != nil {
+		return p.mapInsertIndividualError(ctx, err)
+	}
+
+	queryCredentials := `
+		INSERT INTO credentials (user_id, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $3);
+	`
+
+	if _, err := tx.Exec(ctx, queryCredentials, id, passwordHash, createdAt); err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		p.logger.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	return nil
+}
+
+func (p *PostgresStore) GetPasswordHash(ctx context.Context, username model.IndividualId) (string, error) {
+	query := `
+		SELECT i.deleted_at, c.password_hash
+		FROM individuals i
+		LEFT JOIN credentials c ON c.user_id = i.id
+		WHERE i.username = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, query, username)
+	var deletedAt sql.NullTime
+	var hash sql.NullString
+
+	err := row.Scan(&deletedAt, &hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", storage.ErrNotFound
+	} else if err != nil {
+		p.logger.ErrorContext(ctx, "unknown error when retrieving password hash", slog.String("username", string(username)), slog.Any("error", err))
+		return "", storage.ErrUnknown
+	}
+
+	if deletedAt.Valid {
+		return "", storage.ErrDeleted
+	}
+	if !hash.Valid {
+		return "", auth.ErrPasswordNotSet
+	}
+
+	return hash.String, nil
+}
+
+func (p *PostgresStore) UpdatePasswordHash(ctx context.Context, username model.IndividualId, passwordHash string) error {
+	queryIndividual := `
+		SELECT id, deleted_at
+		FROM individuals
+		WHERE username = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, queryIndividual, username)
+	var id int
+	var deletedAt sql.NullTime
+	if err := row.Scan(&id, &deletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving individual", slog.String("username", string(username)), slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	if deletedAt.Valid {
+		return storage.ErrDeleted
+	}
+
+	// accounts created before credentials existed get their first password
+	query := `
+		INSERT INTO credentials (user_id, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET password_hash = EXCLUDED.password_hash, updated_at = EXCLUDED.updated_at;
+	`
+
+	if _, err := p.conn().Exec(ctx, query, id, passwordHash, time.Now()); err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	return nil
+}
+
+func (p *PostgresStore) GetDeletedPasswordHash(ctx context.Context, username model.IndividualId) (string, time.Time, error) {
+	query := `
+		SELECT i.deleted_at, c.password_hash
+		FROM individuals i
+		LEFT JOIN credentials c ON c.user_id = i.id
+		WHERE i.username = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, query, username)
+	var deletedAt sql.NullTime
+	var hash sql.NullString
+
+	err := row.Scan(&deletedAt, &hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", time.Time{}, storage.ErrNotFound
+	} else if err != nil {
+		p.logger.ErrorContext(ctx, "unknown error when retrieving password hash", slog.String("username", string(username)), slog.Any("error", err))
+		return "", time.Time{}, storage.ErrUnknown
+	}
+
+	if !deletedAt.Valid {
+		return "", time.Time{}, storage.ErrNotDeleted
+	}
+	if !hash.Valid {
+		return "", time.Time{}, auth.ErrPasswordNotSet
+	}
+
+	return hash.String, deletedAt.Time, nil
+}
+
+func (p *PostgresStore) RestoreIndividual(ctx context.Context, username model.IndividualId) error {
+	// anonymised individuals no longer have their username, so they cannot
+	// be found here
+	query := `
+		UPDATE individuals
+		SET deleted_at = NULL, updated_at = $2
+		WHERE username = $1 AND deleted_at IS NOT NULL;
+	`
+
+	result, err := p.conn().Exec(ctx, query, username, time.Now())
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	if result.RowsAffected() == 1 {
+		p.logger.InfoContext(ctx, "individual restored", slog.String("username", string(username)))
+		return nil
+	}
+
+	// tell apart the unknown individuals from the ones that are not deleted
+	if _, err := p.GetIndividual(ctx, username); err != nil {
+		return err
+	}
+	return storage.ErrNotDeleted
+}
@@ -0,0 +1,80 @@
+package infrastructure
+
+import (
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *PostgresStoreTestSuite) TestStoreIndividualWithPassword_StoresIndividualAndHash() {
+	individual := model.Individual{
+		Username: "username",
+		Name:     "name",
+		Email:    "email",
+	}
+	err := suite.pgStore.StoreIndividualWithPassword(suite.T().Context(), individual, "hash")
+	suite.Require().NoError(err, "expected no error when storing individual with password")
+
+	got, err := suite.pgStore.GetIndividual(suite.T().Context(), individual.Username)
+	suite.Require().NoError(err, "expected no error when retrieving individual")
+	assert.Equal(suite.T(), individual, got, "expected stored and retrieved individual to match")
+
+	hash, err := suite.pgStore.GetPasswordHash(suite.T().Context(), individual.Username)
+	suite.Require().NoError(err, "expected no error when retrieving password hash")
+	assert.Equal(suite.T(), "hash", hash, "expected stored and retrieved hash to match")
+}
+
+func (suite *PostgresStoreTestSuite) TestStoreIndividualWithPassword_ReturnsError_IfUsernameConstraintIsViolated() {
+	individual := model.Individual{
+		Username: "username",
+		Name:     "name",
+		Email:    "email",
+	}
+	err := suite.pgStore.StoreIndividualWithPassword(suite.T().Context(), individual, "hash")
+	suite.Require().NoError(err, "expected no error when storing individual with password")
+
+	individual.Email = "other"
+	err = suite.pgStore.StoreIndividualWithPassword(suite.T().Context(), individual, "hash")
+	suite.Require().ErrorIs(err, storage.ErrIndividualUsernameAlreadyExists, "expected error if username is taken")
+}
+
+func (suite *PostgresStoreTestSuite) TestGetPasswordHash_ReturnsError_WhenPasswordIsNotSet() {
+	username := suite.addUserForSession()
+
+	_, err := suite.pgStore.GetPasswordHash(suite.T().Context(), username)
+	suite.Require().ErrorIs(err, auth.ErrPasswordNotSet, "expected error when individual has no password")
+}
+
+func (suite *PostgresStoreTestSuite) TestGetPasswordHash_ReturnsError_WhenIndividualDoesntExist() {
+	_, err := suite.pgStore.GetPasswordHash(suite.T().Context(), "unknown")
+	suite.Require().ErrorIs(err, storage.ErrNotFound, "expected error when individual is not in the database")
+}
+
+func (suite *PostgresStoreTestSuite) TestGetPasswordHash_ReturnsError_WhenIndividualIsDeleted() {
+	individual := model.Individual{
+		Username: "username",
+		Name:     "name",
+		Email:    "email",
+	}
+	err := suite.pgStore.StoreIndividualWithPassword(suite.T().Context(), individual, "hash")
+	suite.Require().NoError(err, "expected no error when storing individual with password")
+	err = suite.pgStore.MarkIndividualAsDeleted(suite.T().Context(), individual.Username)
+	suite.Require().NoError(err, "expected no error when deleting individual")
+
+	_, err = suite.pgStore.GetPasswordHash(suite.T().Context(), individual.Username)
+	suite.Require().ErrorIs(err, storage.ErrDeleted, "expected error when individual is soft-deleted")
+}
+
+func (suite *PostgresStoreTestSuite) TestUpdatePasswordHash_SetsAndReplacesTheHash() {
+	username := suite.addUserForSession()
+
+	err := suite.pgStore.UpdatePasswordHash(suite.T().Context(), username, "first")
+	suite.Require().NoError(err, "expected no error when setting the first password")
+	err = suite.pgStore.UpdatePasswordHash(suite.T().Context(), username, "second")
+	suite.Require().NoError(err, "expected no error when replacing the password")
+
+	hash, err := suite.pgStore.GetPasswordHash(suite.T().Context(), username)
+	suite.Require().NoError(err, "expected no error when retrieving password hash")
+	assert.Equal(suite.T(), "second", hash, "expected password hash to be replaced")
+}
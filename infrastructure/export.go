@@ -0,0 +1,224 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ export.Storage = (*PostgresStore)(nil)
+
+// exportedIndividualId returns the internal id of an individual that was not
+// anonymised yet, whether or not they deleted their account.
+func (p *PostgresStore) exportedIndividualId(ctx context.Context, username model.IndividualId) (int, error) {
+	queryIndividual := `
+		SELECT id, anonymised_at
+		FROM individuals
+		WHERE username = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, queryIndividual, username)
+	var id int
+	var anonymisedAt sql.NullTime
+	if err := row.Scan(&id, &anonymisedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving individual", slog.String("username", string(username)), slog.Any("error", err))
+		return 0, storage.ErrUnknown
+	}
+	// nothing is left to export
+	if anonymisedAt.Valid {
+		return 0, storage.ErrDeleted
+	}
+	return id, nil
+}
+
+func (p *PostgresStore) GetIndividualForExport(ctx context.Context, username model.IndividualId) (model.Individual, *time.Time, error) {
+	query := `
+		SELECT username, email, name, deleted_at, anonymised_at
+		FROM individuals
+		WHERE username = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, query, username)
+	var individual model.Individual
+	var deletedAt, anonymisedAt sql.NullTime
+	err := row.Scan(&individual.Username, &individual.Email, &individual.Name, &deletedAt, &anonymisedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Individual{}, nil, storage.ErrNotFound
+	} else if err != nil {
+		p.logger.ErrorContext(ctx, "unknown error when retrieving individual", slog.String("username", string(username)), slog.Any("error", err))
+		return model.Individual{}, nil, storage.ErrUnknown
+	}
+	if anonymisedAt.Valid {
+		return model.Individual{}, nil, storage.ErrDeleted
+	}
+	if deletedAt.Valid {
+		return individual, &deletedAt.Time, nil
+	}
+	return individual, nil, nil
+}
+
+func (p *PostgresStore) ListHangoutsForExport(ctx context.Context, username model.IndividualId, after *storage.HangoutCursor, limit int) (export.HangoutPage, error) {
+	individualId, err := p.exportedIndividualId(ctx, username)
+	if err != nil {
+		return export.HangoutPage{}, err
+	}
+
+	// the hangouts and their participants must be read from the same snapshot
+	tx, err := p.conn().BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return export.HangoutPage{}, storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	var afterDate, afterId any
+	if after != nil {
+		afterDate = after.Date
+		afterId = after.PublicId
+	}
+
+	// an individual that left a hangout and joined it again has several
+	// participations, they only left it if none of them is current
+	//
+	// uses idx_hangout_individuals_individual
+	query := `
+		SELECT h.id, h.public_id, h.location, h.description, h.duration_minutes, h.date, c.username, p.left_at, h.deleted_at
+		FROM (
+			SELECT hangout_id, CASE WHEN bool_or(deleted_at IS NULL) THEN NULL ELSE max(deleted_at) END AS left_at
+			FROM hangout_individuals
+			WHERE individual_id = $1
+			GROUP BY hangout_id
+		) p
+		JOIN hangouts h ON h.id = p.hangout_id
+		LEFT JOIN individuals c ON c.id = h.created_by
+		WHERE ($2::timestamptz IS NULL OR (h.date, h.public_id) < ($2, $3::uuid))
+		ORDER BY h.date DESC, h.public_id DESC
+		LIMIT $4;
+	`
+
+	// one more row tells whether there is a next page
+	rows, err := tx.Query(ctx, query, individualId, afterDate, afterId, limit+1)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list hangouts", slog.Any("error", err))
+		return export.HangoutPage{}, storage.ErrUnknown
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var hangouts []export.Hangout
+	for rows.Next() {
+		var id int64
+		var duration int
+		var creator sql.NullString
+		var leftAt, deletedAt sql.NullTime
+		var hangout export.Hangout
+		if err := rows.Scan(&id, &hangout.PublicId, &hangout.Location, &hangout.Description, &duration, &hangout.Date, &creator, &leftAt, &deletedAt); err != nil {
+			p.logger.ErrorContext(ctx, "failed to scan hangout", slog.Any("error", err))
+			return export.HangoutPage{}, storage.ErrUnknown
+		}
+		hangout.Duration = model.Minutes(duration)
+		hangout.CreatedBy = model.IndividualId(creator.String)
+		if leftAt.Valid {
+			hangout.LeftAt = &leftAt.Time
+		}
+		if deletedAt.Valid {
+			hangout.DeletedAt = &deletedAt.Time
+		}
+		ids = append(ids, id)
+		hangouts = append(hangouts, hangout)
+	}
+	if err := rows.Err(); err != nil {
+		p.logger.ErrorContext(ctx, "failed to list hangouts", slog.Any("error", err))
+		return export.HangoutPage{}, storage.ErrUnknown
+	}
+
+	page := newExportPage(hangouts, limit)
+	if len(page.Hangouts) == 0 {
+		return page, nil
+	}
+
+	byId := make(map[int64]*model.Hangout, len(page.Hangouts))
+	for i := range page.Hangouts {
+		byId[ids[i]] = &page.Hangouts[i].Hangout
+	}
+	if err := p.loadParticipants(ctx, tx, byId); err != nil {
+		return export.HangoutPage{}, err
+	}
+
+	return page, nil
+}
+
+// newExportPage expects at most one hangout more than the limit, like
+// newHangoutPage.
+func newExportPage(hangouts []export.Hangout, limit int) export.HangoutPage {
+	if len(hangouts) <= limit {
+		return export.HangoutPage{Hangouts: hangouts}
+	}
+
+	hangouts = hangouts[:limit]
+	last := hangouts[len(hangouts)-1]
+	return export.HangoutPage{
+		Hangouts: hangouts,
+		Next: &storage.HangoutCursor{
+			Date:     last.Date,
+			PublicId: last.PublicId,
+		},
+	}
+}
+
+// ListSessionsOfIndividual also returns the sessions of individuals that
+// deleted their account, since they work again once it is restored.
+func (p *PostgresStore) ListSessionsOfIndividual(ctx context.Context, username model.IndividualId) ([]export.Session, error) {
+	id, err := p.exportedIndividualId(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// uses idx_sessions_user_id
+	query := `
+		SELECT created_at, last_accessed
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY last_accessed DESC, created_at DESC;
+	`
+
+	rows, err := p.conn().Query(ctx, query, id)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return nil, storage.ErrUnknown
+	}
+	defer rows.Close()
+
+	sessions := []export.Session{}
+	for rows.Next() {
+		var s export.Session
+		if err := rows.Scan(&s.CreatedAt, &s.LastAccessed); err != nil {
+			p.logger.ErrorContext(ctx, "failed to scan session", slog.Any("error", err))
+			return nil, storage.ErrUnknown
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		p.logger.ErrorContext(ctx, "failed to iterate over sessions", slog.Any("error", err))
+		return nil, storage.ErrUnknown
+	}
+	return sessions, nil
+}
@@ -0,0 +1,718 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// mapInsertParticipantError converts the error of an insertion into
+// hangout_individuals to a storage error.
+func (p *PostgresStore) mapInsertParticipantError(ctx context.Context, err error) error {
+	p.logger.ErrorContext(ctx, "failed to execute participant insertion query", slog.Any("error", err))
+	var pgerr *pgconn.PgError
+	if errors.As(err, &pgerr) {
+		p.logger.DebugContext(ctx, "got pgerr", slog.String("error", fmt.Sprintf("%#v", pgerr)))
+		if pgerr.Code != pgerrcode.ForeignKeyViolation {
+			return storage.ErrUnknown
+		}
+		// these may be redundant depending on isolation level, but
+		// in theory should never happen
+		switch pgerr.ConstraintName {
+		case CONSTRAINT_FOREIGN_KEY_HANGOUT:
+			p.logger.WarnContext(ctx, "foreign key constraint violation in hangout_individuals table", slog.String("constraint", CONSTRAINT_FOREIGN_KEY_HANGOUT))
+			return storage.ErrParticipantHangoutNotFound
+		case CONSTRAINT_FOREIGN_KEY_INDIVIDUAL:
+			p.logger.WarnContext(ctx, "foreign key constraint violation in hangout_individuals table", slog.String("constraint", CONSTRAINT_FOREIGN_KEY_INDIVIDUAL))
+			return storage.ErrParticipantIndividualNotFound
+		}
+	}
+	return storage.ErrUnknown
+}
+
+// lockHangout returns the internal id of a hangout that is not deleted and
+// prevents concurrent updates of the hangout until the transaction ends.
+func (p *PostgresStore) lockHangout(ctx context.Context, tx pgx.Tx, hangoutId model.HangoutId) (int64, error) {
+	query := `
+		SELECT id, deleted_at
+		FROM hangouts
+		WHERE public_id = $1
+		FOR UPDATE;
+	`
+
+	row := tx.QueryRow(ctx, query, hangoutId)
+	var id int64
+	var deletedAt sql.NullTime
+	if err := row.Scan(&id, &deletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving hangout", slog.Any("error", err))
+		return 0, storage.ErrUnknown
+	}
+	if deletedAt.Valid {
+		return 0, storage.ErrDeleted
+	}
+
+	return id, nil
+}
+
+func (p *PostgresStore) UpdateHangoutDetails(ctx context.Context, hangoutId model.HangoutId, details model.HangoutDetails) error {
+	tx, err := p.conn().Begin(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	id, err := p.lockHangout(ctx, tx, hangoutId)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE hangouts
+		SET location = $2, description = $3, duration_minutes = $4, date = $5, updated_at = $6
+		WHERE id = $1;
+	`
+
+	result, err := tx.Exec(ctx, query, id, details.Location, details.Description, details.Duration, details.Date, time.Now())
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	rows := result.RowsAffected()
+	if rows != 1 {
+		p.logger.ErrorContext(ctx, "expected one row to be affected", slog.Int64("rows_affected", rows))
+		return storage.ErrUnknown
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	p.logger.InfoContext(ctx, "hangout details updated", slog.String("hangout", uuid.UUID(hangoutId).String()))
+	return nil
+}
+
+func (p *PostgresStore) UpdateHangoutParticipants(ctx context.Context, hangoutId model.HangoutId, expected, participants []model.IndividualId) error {
+	tx, err := p.conn().Begin(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	id, err := p.lockHangout(ctx, tx, hangoutId)
+	if err != nil {
+		return err
+	}
+
+	queryCurrent := `
+		SELECT hi.individual_id, i.username
+		FROM hangout_individuals hi
+		JOIN individuals i ON i.id = hi.individual_id
+		WHERE hi.hangout_id = $1 AND hi.deleted_at IS NULL;
+	`
+
+	rows, err := tx.Query(ctx, queryCurrent, id)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to retrieve current participants", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	defer rows.Close()
+	isParticipant := make(map[int]bool)
+	var current []model.IndividualId
+	for rows.Next() {
+		var individualId int
+		var username string
+		if err := rows.Scan(&individualId, &username); err != nil {
+			p.logger.ErrorContext(ctx, "failed to scan current participant", slog.Any("error", err))
+			return storage.ErrUnknown
+		}
+		isParticipant[individualId] = true
+		current = append(current, model.IndividualId(username))
+	}
+	if err := rows.Err(); err != nil {
+		p.logger.ErrorContext(ctx, "failed to retrieve current participants", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	// the caller decided on the update from the participants it read before
+	if !sameParticipants(current, expected) {
+		return storage.ErrHangoutParticipantsChanged
+	}
+
+	queryIndividual := `
+		SELECT id, deleted_at
+		FROM individuals
+		WHERE username = $1;
+	`
+	queryInsertParticipant := `
+		INSERT INTO hangout_individuals (hangout_id, individual_id, created_at)
+		VALUES ($1, $2, $3);
+	`
+	currentTimestamp := time.Now()
+
+	kept := make([]int, 0, len(participants))
+	for _, participant := range participants {
+		row := tx.QueryRow(ctx, queryIndividual, participant)
+
+		var participantId int
+		var participantDeleted sql.NullTime
+		if err := row.Scan(&participantId, &participantDeleted); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				p.logger.ErrorContext(ctx, "hangout participant not found", slog.String("username", string(participant)))
+				return storage.ErrHangoutParticipantNotFound
+			}
+			p.logger.ErrorContext(ctx, "unknown error when retrieving participant", slog.String("username", string(participant)), slog.Any("error", err))
+			return storage.ErrUnknown
+		}
+		if isParticipant[participantId] {
+			kept = append(kept, participantId)
+			continue
+		}
+		if participantDeleted.Valid {
+			return storage.ErrHangoutParticipantDeleted
+		}
+
+		if _, err := tx.Exec(ctx, queryInsertParticipant, id, participantId, currentTimestamp); err != nil {
+			return p.mapInsertParticipantError(ctx, err)
+		}
+		// the same username may be listed twice
+		isParticipant[participantId] = true
+		kept = append(kept, participantId)
+	}
+
+	// the rows are kept to remember who took part in the hangout
+	queryRemove := `
+		UPDATE hangout_individuals
+		SET deleted_at = $3
+		WHERE hangout_id = $1 AND deleted_at IS NULL AND NOT (individual_id = ANY($2));
+	`
+	if _, err := tx.Exec(ctx, queryRemove, id, kept, currentTimestamp); err != nil {
+		p.logger.ErrorContext(ctx, "failed to remove participants", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	queryTouch := `
+		UPDATE hangouts
+		SET updated_at = $2
+		WHERE id = $1;
+	`
+	if _, err := tx.Exec(ctx, queryTouch, id, currentTimestamp); err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	p.logger.InfoContext(ctx, "hangout participants updated", slog.String("hangout", uuid.UUID(hangoutId).String()), slog.Any("participants", participants))
+	return nil
+}
+
+// sameParticipants tells whether both lists hold the same participants, in
+// any order.
+func sameParticipants(a, b []model.IndividualId) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+func (p *PostgresStore) GetHangout(ctx context.Context, hangoutId model.HangoutId) (model.Hangout, error) {
+	// the hangout and its participants must be read from the same snapshot
+	tx, err := p.conn().BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return model.Hangout{}, storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	queryHangout := `
+		SELECT h.id, h.location, h.description, h.duration_minutes, h.date, h.deleted_at, c.username
+		FROM hangouts h
+		LEFT JOIN individuals c ON c.id = h.created_by
+		WHERE h.public_id = $1;
+	`
+
+	row := tx.QueryRow(ctx, queryHangout, hangoutId)
+	var id int64
+	var duration int
+	var deletedAt sql.NullTime
+	// the creator is null once they erased their account
+	var creator sql.NullString
+	hangout := model.Hangout{PublicId: hangoutId}
+	err = row.Scan(&id, &hangout.Location, &hangout.Description, &duration, &hangout.Date, &deletedAt, &creator)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Hangout{}, storage.ErrNotFound
+	} else if err != nil {
+		p.logger.ErrorContext(ctx, "unknown error when retrieving hangout", slog.Any("error", err))
+		return model.Hangout{}, storage.ErrUnknown
+	}
+	if deletedAt.Valid {
+		return model.Hangout{}, storage.ErrDeleted
+	}
+	hangout.Duration = model.Minutes(duration)
+	hangout.CreatedBy = model.IndividualId(creator.String)
+
+	if err := p.loadParticipants(ctx, tx, map[int64]*model.Hangout{id: &hangout}); err != nil {
+		return model.Hangout{}, err
+	}
+
+	return hangout, nil
+}
+
+// loadParticipants fills in the current participants of the hangouts, which
+// are indexed by their internal id.
+func (p *PostgresStore) loadParticipants(ctx context.Context, tx pgx.Tx, hangouts map[int64]*model.Hangout) error {
+	ids := make([]int64, 0, len(hangouts))
+	for id := range hangouts {
+		ids = append(ids, id)
+	}
+
+	// uses idx_hangout_individuals_hangout
+	query := `
+		SELECT hi.hangout_id, i.username, i.deleted_at IS NOT NULL
+		FROM hangout_individuals hi
+		JOIN individuals i ON i.id = hi.individual_id
+		WHERE hi.hangout_id = ANY($1) AND hi.deleted_at IS NULL
+		ORDER BY hi.id;
+	`
+
+	rows, err := tx.Query(ctx, query, ids)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to retrieve participants", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hangoutId int64
+		var username string
+		var deleted bool
+		if err := rows.Scan(&hangoutId, &username, &deleted); err != nil {
+			p.logger.ErrorContext(ctx, "failed to scan participant", slog.Any("error", err))
+			return storage.ErrUnknown
+		}
+		hangout := hangouts[hangoutId]
+		hangout.Individuals = append(hangout.Individuals, model.IndividualId(username))
+		if deleted {
+			hangout.DeletedIndividuals = append(hangout.DeletedIndividuals, model.IndividualId(username))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		p.logger.ErrorContext(ctx, "failed to retrieve participants", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	return nil
+}
+
+func (p *PostgresStore) ListHangoutsForIndividual(ctx context.Context, username model.IndividualId, filter storage.HangoutFilter, after *storage.HangoutCursor, limit int) (storage.HangoutPage, error) {
+	tx, err := p.conn().BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return storage.HangoutPage{}, storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	queryIndividual := `
+		SELECT id, deleted_at
+		FROM individuals
+		WHERE username = $1;
+	`
+
+	row := tx.QueryRow(ctx, queryIndividual, username)
+	var individualId int
+	var deletedAt sql.NullTime
+	if err := row.Scan(&individualId, &deletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.HangoutPage{}, storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving individual", slog.String("username", string(username)), slog.Any("error", err))
+		return storage.HangoutPage{}, storage.ErrUnknown
+	}
+	if deletedAt.Valid {
+		return storage.HangoutPage{}, storage.ErrDeleted
+	}
+
+	// the arguments are nil when the corresponding filter is not set
+	var with, from, to, afterDate, afterId any
+	if filter.With != "" {
+		row := tx.QueryRow(ctx, queryIndividual, filter.With)
+		var withId int
+		var withDeletedAt sql.NullTime
+		if err := row.Scan(&withId, &withDeletedAt); err != nil {
+			// nobody took part in a hangout with an unknown individual
+			if errors.Is(err, pgx.ErrNoRows) {
+				return storage.HangoutPage{}, nil
+			}
+			p.logger.ErrorContext(ctx, "unknown error when retrieving individual", slog.String("username", string(filter.With)), slog.Any("error", err))
+			return storage.HangoutPage{}, storage.ErrUnknown
+		}
+		with = withId
+	}
+	if !filter.From.IsZero() {
+		from = filter.From
+	}
+	if !filter.To.IsZero() {
+		to = filter.To
+	}
+	if after != nil {
+		afterDate = after.Date
+		afterId = after.PublicId
+	}
+
+	// uses idx_hangout_individuals_individual, an individual does not have
+	// enough hangouts for the sort to matter
+	query := `
+		SELECT h.id, h.public_id, h.location, h.description, h.duration_minutes, h.date, c.username
+		FROM hangout_individuals hi
+		JOIN hangouts h ON h.id = hi.hangout_id
+		LEFT JOIN individuals c ON c.id = h.created_by
+		WHERE hi.individual_id = $1
+		  AND hi.deleted_at IS NULL
+		  AND h.deleted_at IS NULL
+		  AND ($2::int IS NULL OR EXISTS (
+			SELECT 1
+			FROM hangout_individuals o
+			WHERE o.hangout_id = h.id AND o.individual_id = $2 AND o.deleted_at IS NULL
+		  ))
+		  AND ($3::timestamptz IS NULL OR h.date >= $3)
+		  AND ($4::timestamptz IS NULL OR h.date < $4)
+		  AND ($5::timestamptz IS NULL OR (h.date, h.public_id) < ($5, $6::uuid))
+		ORDER BY h.date DESC, h.public_id DESC
+		LIMIT $7;
+	`
+
+	// one more row tells whether there is a next page
+	rows, err := tx.Query(ctx, query, individualId, with, from, to, afterDate, afterId, limit+1)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to list hangouts", slog.Any("error", err))
+		return storage.HangoutPage{}, storage.ErrUnknown
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var hangouts []model.Hangout
+	for rows.Next() {
+		var id int64
+		var duration int
+		var creator sql.NullString
+		var hangout model.Hangout
+		if err := rows.Scan(&id, &hangout.PublicId, &hangout.Location, &hangout.Description, &duration, &hangout.Date, &creator); err != nil {
+			p.logger.ErrorContext(ctx, "failed to scan hangout", slog.Any("error", err))
+			return storage.HangoutPage{}, storage.ErrUnknown
+		}
+		hangout.Duration = model.Minutes(duration)
+		hangout.CreatedBy = model.IndividualId(creator.String)
+		ids = append(ids, id)
+		hangouts = append(hangouts, hangout)
+	}
+	if err := rows.Err(); err != nil {
+		p.logger.ErrorContext(ctx, "failed to list hangouts", slog.Any("error", err))
+		return storage.HangoutPage{}, storage.ErrUnknown
+	}
+
+	page := newHangoutPage(hangouts, limit)
+	if len(page.Hangouts) == 0 {
+		return page, nil
+	}
+
+	byId := make(map[int64]*model.Hangout, len(page.Hangouts))
+	for i := range page.Hangouts {
+		byId[ids[i]] = &page.Hangouts[i]
+	}
+	if err := p.loadParticipants(ctx, tx, byId); err != nil {
+		return storage.HangoutPage{}, err
+	}
+
+	return page, nil
+}
+
+// newHangoutPage expects at most one hangout more than the limit, which is
+// only used to know whether there is a next page.
+func newHangoutPage(hangouts []model.Hangout, limit int) storage.HangoutPage {
+	if len(hangouts) <= limit {
+		return storage.HangoutPage{Hangouts: hangouts}
+	}
+
+	hangouts = hangouts[:limit]
+	last := hangouts[len(hangouts)-1]
+	return storage.HangoutPage{
+		Hangouts: hangouts,
+		Next: &storage.HangoutCursor{
+			Date:     last.Date,
+			PublicId: last.PublicId,
+		},
+	}
+}
+
+func (p *PostgresStore) GetHangoutCreator(ctx context.Context, hangoutId model.HangoutId) (model.IndividualId, error) {
+	query := `
+		SELECT c.username
+		FROM hangouts h
+		LEFT JOIN individuals c ON c.id = h.created_by
+		WHERE h.public_id = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, query, hangoutId)
+	var creator sql.NullString
+	if err := row.Scan(&creator); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving hangout creator", slog.Any("error", err))
+		return "", storage.ErrUnknown
+	}
+	if !creator.Valid {
+		return "", storage.ErrHangoutCreatorNotFound
+	}
+
+	return model.IndividualId(creator.String), nil
+}
+
+func (p *PostgresStore) IsHangoutParticipant(ctx context.Context, hangoutId model.HangoutId, individual model.IndividualId) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM hangout_individuals hi
+			JOIN individuals i ON i.id = hi.individual_id
+			WHERE hi.hangout_id = h.id AND i.username = $2 AND hi.deleted_at IS NULL
+		)
+		FROM hangouts h
+		WHERE h.public_id = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, query, hangoutId, individual)
+	var participant bool
+	if err := row.Scan(&participant); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving hangout participant", slog.Any("error", err))
+		return false, storage.ErrUnknown
+	}
+
+	return participant, nil
+}
+
+func (p *PostgresStore) MarkHangoutAsDeleted(ctx context.Context, hangoutId model.HangoutId) error {
+	tx, err := p.conn().Begin(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	id, err := p.lockHangout(ctx, tx, hangoutId)
+	if err != nil {
+		return err
+	}
+
+	// the participants are left untouched, so that restoring the hangout
+	// does not bring back the ones removed before the deletion
+	query := `
+		UPDATE hangouts
+		SET deleted_at = $2, updated_at = $2
+		WHERE id = $1;
+	`
+
+	if _, err := tx.Exec(ctx, query, id, time.Now()); err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	p.logger.InfoContext(ctx, "hangout deleted", slog.String("hangout", uuid.UUID(hangoutId).String()))
+	return nil
+}
+
+func (p *PostgresStore) RestoreHangout(ctx context.Context, hangoutId model.HangoutId) error {
+	tx, err := p.conn().Begin(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	queryHangout := `
+		SELECT id, deleted_at
+		FROM hangouts
+		WHERE public_id = $1
+		FOR UPDATE;
+	`
+
+	row := tx.QueryRow(ctx, queryHangout, hangoutId)
+	var id int64
+	var deletedAt sql.NullTime
+	if err := row.Scan(&id, &deletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving hangout", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	if !deletedAt.Valid {
+		return storage.ErrNotDeleted
+	}
+
+	query := `
+		UPDATE hangouts
+		SET deleted_at = NULL, updated_at = $2
+		WHERE id = $1;
+	`
+
+	if _, err := tx.Exec(ctx, query, id, time.Now()); err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	p.logger.InfoContext(ctx, "hangout restored", slog.String("hangout", uuid.UUID(hangoutId).String()))
+	return nil
+}
+
+func (p *PostgresStore) PurgeDeletedHangouts(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	// the participants are removed by the fk_hangout cascade. Uses
+	// idx_hangouts_deleted_at.
+	query := `
+		DELETE FROM hangouts
+		WHERE id IN (
+			SELECT id
+			FROM hangouts
+			WHERE deleted_at < $1
+			LIMIT $2
+		);
+	`
+
+	result, err := p.conn().Exec(ctx, query, deletedBefore, limit)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return 0, storage.ErrUnknown
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (p *PostgresStore) RemoveHangoutParticipant(ctx context.Context, hangoutId model.HangoutId, participant model.IndividualId) error {
+	tx, err := p.conn().Begin(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	id, err := p.lockHangout(ctx, tx, hangoutId)
+	if err != nil {
+		return err
+	}
+
+	// the row is kept so the participation stays in the history
+	now := time.Now()
+	queryRemove := `
+		UPDATE hangout_individuals hi
+		SET deleted_at = $3
+		FROM individuals i
+		WHERE hi.individual_id = i.id
+		  AND hi.hangout_id = $1
+		  AND i.username = $2
+		  AND hi.deleted_at IS NULL;
+	`
+
+	result, err := tx.Exec(ctx, queryRemove, id, participant, now)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	if result.RowsAffected() == 0 {
+		return storage.ErrNotParticipant
+	}
+
+	queryTouch := `
+		UPDATE hangouts
+		SET updated_at = $2
+		WHERE id = $1;
+	`
+
+	if _, err := tx.Exec(ctx, queryTouch, id, now); err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+
+	p.logger.InfoContext(ctx, "hangout participant removed", slog.String("hangout", uuid.UUID(hangoutId).String()), slog.String("participant", string(participant)))
+	return nil
+}
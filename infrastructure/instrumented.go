@@ -0,0 +1,280 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/retention"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/metrics"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+var _ storage.AppStorage = (*InstrumentedStore)(nil)
+var _ retention.Storage = (*InstrumentedStore)(nil)
+var _ session.SessionStorage = (*InstrumentedStore)(nil)
+var _ auth.CredentialStorage = (*InstrumentedStore)(nil)
+var _ auth.MagicLinkStorage = (*InstrumentedStore)(nil)
+var _ stats.Storage = (*InstrumentedStore)(nil)
+var _ export.Storage = (*InstrumentedStore)(nil)
+
+// the errors the storage methods return on purpose, the other ones are
+// labelled ERROR_LABEL_OTHER. The specific errors are listed before the
+// generic ones they may wrap.
+var storageErrors = []struct {
+	err   error
+	label string
+}{
+	{storage.ErrIndividualEmailAlreadyExists, "storage.ErrIndividualEmailAlreadyExists"},
+	{storage.ErrIndividualUsernameAlreadyExists, "storage.ErrIndividualUsernameAlreadyExists"},
+	{storage.ErrHangoutCreatorNotFound, "storage.ErrHangoutCreatorNotFound"},
+	{storage.ErrHangoutCreatorDeleted, "storage.ErrHangoutCreatorDeleted"},
+	{storage.ErrHangoutParticipantNotFound, "storage.ErrHangoutParticipantNotFound"},
+	{storage.ErrHangoutParticipantDeleted, "storage.ErrHangoutParticipantDeleted"},
+	{storage.ErrHangoutParticipantsChanged, "storage.ErrHangoutParticipantsChanged"},
+	{storage.ErrParticipantHangoutNotFound, "storage.ErrParticipantHangoutNotFound"},
+	{storage.ErrParticipantIndividualNotFound, "storage.ErrParticipantIndividualNotFound"},
+	{storage.ErrNotParticipant, "storage.ErrNotParticipant"},
+	{storage.ErrAlreadyExists, "storage.ErrAlreadyExists"},
+	{storage.ErrNotFound, "storage.ErrNotFound"},
+	{storage.ErrDeleted, "storage.ErrDeleted"},
+	{storage.ErrNotDeleted, "storage.ErrNotDeleted"},
+	{storage.ErrUnknown, "storage.ErrUnknown"},
+	{session.ErrNotFound, "session.ErrNotFound"},
+	{session.ErrUserNotFound, "session.ErrUserNotFound"},
+	{session.ErrUserDeleted, "session.ErrUserDeleted"},
+	{session.ErrCookieInvalidLength, "session.ErrCookieInvalidLength"},
+	{session.ErrUnknown, "session.ErrUnknown"},
+	{auth.ErrPasswordNotSet, "auth.ErrPasswordNotSet"},
+	{auth.ErrMagicLinkInvalid, "auth.ErrMagicLinkInvalid"},
+}
+
+const ERROR_LABEL_OTHER = "other"
+
+// errorLabel names the sentinel returned by a storage method, empty if it
+// succeeded.
+func errorLabel(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, e := range storageErrors {
+		if errors.Is(err, e.err) {
+			return e.label
+		}
+	}
+	return ERROR_LABEL_OTHER
+}
+
+// InstrumentedStore records the duration and the errors of every storage call
+// of the PostgresStore it wraps. The other methods, such as Ping, are used
+// as is.
+type InstrumentedStore struct {
+	*PostgresStore
+	recorder metrics.Recorder
+}
+
+func NewInstrumentedStore(p *PostgresStore, recorder metrics.Recorder) *InstrumentedStore {
+	return &InstrumentedStore{
+		PostgresStore: p,
+		recorder:      recorder,
+	}
+}
+
+func (s *InstrumentedStore) observe(method string, start time.Time, err *error) {
+	s.recorder.ObserveStorageCall(method, errorLabel(*err), time.Since(start))
+}
+
+func (s *InstrumentedStore) StoreIndividual(ctx context.Context, individual model.Individual) (err error) {
+	defer s.observe("StoreIndividual", time.Now(), &err)
+	return s.PostgresStore.StoreIndividual(ctx, individual)
+}
+
+func (s *InstrumentedStore) GetIndividual(ctx context.Context, individualUsername model.IndividualId) (_ model.Individual, err error) {
+	defer s.observe("GetIndividual", time.Now(), &err)
+	return s.PostgresStore.GetIndividual(ctx, individualUsername)
+}
+
+func (s *InstrumentedStore) MarkIndividualAsDeleted(ctx context.Context, individualUsername model.IndividualId) (err error) {
+	defer s.observe("MarkIndividualAsDeleted", time.Now(), &err)
+	return s.PostgresStore.MarkIndividualAsDeleted(ctx, individualUsername)
+}
+
+func (s *InstrumentedStore) AnonymiseDeletedIndividuals(ctx context.Context, deletedBefore time.Time, limit int) (_ int64, err error) {
+	defer s.observe("AnonymiseDeletedIndividuals", time.Now(), &err)
+	return s.PostgresStore.AnonymiseDeletedIndividuals(ctx, deletedBefore, limit)
+}
+
+func (s *InstrumentedStore) EraseIndividual(ctx context.Context, username model.IndividualId) (err error) {
+	defer s.observe("EraseIndividual", time.Now(), &err)
+	return s.PostgresStore.EraseIndividual(ctx, username)
+}
+
+func (s *InstrumentedStore) StoreHangoutOfIndividuals(ctx context.Context, hangout model.Hangout) (err error) {
+	defer s.observe("StoreHangoutOfIndividuals", time.Now(), &err)
+	return s.PostgresStore.StoreHangoutOfIndividuals(ctx, hangout)
+}
+
+func (s *InstrumentedStore) GetHangout(ctx context.Context, hangoutId model.HangoutId) (_ model.Hangout, err error) {
+	defer s.observe("GetHangout", time.Now(), &err)
+	return s.PostgresStore.GetHangout(ctx, hangoutId)
+}
+
+func (s *InstrumentedStore) ListHangoutsForIndividual(ctx context.Context, username model.IndividualId, filter storage.HangoutFilter, after *storage.HangoutCursor, limit int) (_ storage.HangoutPage, err error) {
+	defer s.observe("ListHangoutsForIndividual", time.Now(), &err)
+	return s.PostgresStore.ListHangoutsForIndividual(ctx, username, filter, after, limit)
+}
+
+func (s *InstrumentedStore) UpdateHangoutDetails(ctx context.Context, hangoutId model.HangoutId, details model.HangoutDetails) (err error) {
+	defer s.observe("UpdateHangoutDetails", time.Now(), &err)
+	return s.PostgresStore.UpdateHangoutDetails(ctx, hangoutId, details)
+}
+
+func (s *InstrumentedStore) UpdateHangoutParticipants(ctx context.Context, hangoutId model.HangoutId, expected, participants []model.IndividualId) (err error) {
+	defer s.observe("UpdateHangoutParticipants", time.Now(), &err)
+	return s.PostgresStore.UpdateHangoutParticipants(ctx, hangoutId, expected, participants)
+}
+
+func (s *InstrumentedStore) GetHangoutCreator(ctx context.Context, hangoutId model.HangoutId) (_ model.IndividualId, err error) {
+	defer s.observe("GetHangoutCreator", time.Now(), &err)
+	return s.PostgresStore.GetHangoutCreator(ctx, hangoutId)
+}
+
+func (s *InstrumentedStore) IsHangoutParticipant(ctx context.Context, hangoutId model.HangoutId, individual model.IndividualId) (_ bool, err error) {
+	defer s.observe("IsHangoutParticipant", time.Now(), &err)
+	return s.PostgresStore.IsHangoutParticipant(ctx, hangoutId, individual)
+}
+
+func (s *InstrumentedStore) MarkHangoutAsDeleted(ctx context.Context, hangoutId model.HangoutId) (err error) {
+	defer s.observe("MarkHangoutAsDeleted", time.Now(), &err)
+	return s.PostgresStore.MarkHangoutAsDeleted(ctx, hangoutId)
+}
+
+func (s *InstrumentedStore) RestoreHangout(ctx context.Context, hangoutId model.HangoutId) (err error) {
+	defer s.observe("RestoreHangout", time.Now(), &err)
+	return s.PostgresStore.RestoreHangout(ctx, hangoutId)
+}
+
+func (s *InstrumentedStore) RemoveHangoutParticipant(ctx context.Context, hangoutId model.HangoutId, participant model.IndividualId) (err error) {
+	defer s.observe("RemoveHangoutParticipant", time.Now(), &err)
+	return s.PostgresStore.RemoveHangoutParticipant(ctx, hangoutId, participant)
+}
+
+func (s *InstrumentedStore) PurgeDeletedHangouts(ctx context.Context, deletedBefore time.Time, limit int) (_ int64, err error) {
+	defer s.observe("PurgeDeletedHangouts", time.Now(), &err)
+	return s.PostgresStore.PurgeDeletedHangouts(ctx, deletedBefore, limit)
+}
+
+func (s *InstrumentedStore) StoreSession(ctx context.Context, sesh session.Session) (err error) {
+	defer s.observe("StoreSession", time.Now(), &err)
+	return s.PostgresStore.StoreSession(ctx, sesh)
+}
+
+func (s *InstrumentedStore) GetSession(ctx context.Context, cookie string) (_ session.Session, err error) {
+	defer s.observe("GetSession", time.Now(), &err)
+	return s.PostgresStore.GetSession(ctx, cookie)
+}
+
+func (s *InstrumentedStore) UpdateLastAccessed(ctx context.Context, cookie string, lastAccessed time.Time) (err error) {
+	defer s.observe("UpdateLastAccessed", time.Now(), &err)
+	return s.PostgresStore.UpdateLastAccessed(ctx, cookie, lastAccessed)
+}
+
+func (s *InstrumentedStore) DeleteSession(ctx context.Context, cookie string) (err error) {
+	defer s.observe("DeleteSession", time.Now(), &err)
+	return s.PostgresStore.DeleteSession(ctx, cookie)
+}
+
+func (s *InstrumentedStore) DeleteSessionsOfUser(ctx context.Context, userId model.IndividualId) (_ int64, err error) {
+	defer s.observe("DeleteSessionsOfUser", time.Now(), &err)
+	return s.PostgresStore.DeleteSessionsOfUser(ctx, userId)
+}
+
+func (s *InstrumentedStore) DeleteOtherSessionsOfUser(ctx context.Context, userId model.IndividualId, keepCookie string) (_ int64, err error) {
+	defer s.observe("DeleteOtherSessionsOfUser", time.Now(), &err)
+	return s.PostgresStore.DeleteOtherSessionsOfUser(ctx, userId, keepCookie)
+}
+
+func (s *InstrumentedStore) RotateSession(ctx context.Context, oldCookie string, newSession session.Session) (err error) {
+	defer s.observe("RotateSession", time.Now(), &err)
+	return s.PostgresStore.RotateSession(ctx, oldCookie, newSession)
+}
+
+func (s *InstrumentedStore) DeleteExpiredSessions(ctx context.Context, idleBefore, createdBefore time.Time, limit int) (_ int64, err error) {
+	defer s.observe("DeleteExpiredSessions", time.Now(), &err)
+	return s.PostgresStore.DeleteExpiredSessions(ctx, idleBefore, createdBefore, limit)
+}
+
+func (s *InstrumentedStore) CountActiveSessions(ctx context.Context, idleAfter, createdAfter time.Time) (_ int64, err error) {
+	defer s.observe("CountActiveSessions", time.Now(), &err)
+	return s.PostgresStore.CountActiveSessions(ctx, idleAfter, createdAfter)
+}
+
+func (s *InstrumentedStore) StoreIndividualWithPassword(ctx context.Context, individual model.Individual, passwordHash string) (err error) {
+	defer s.observe("StoreIndividualWithPassword", time.Now(), &err)
+	return s.PostgresStore.StoreIndividualWithPassword(ctx, individual, passwordHash)
+}
+
+func (s *InstrumentedStore) GetPasswordHash(ctx context.Context, username model.IndividualId) (_ string, err error) {
+	defer s.observe("GetPasswordHash", time.Now(), &err)
+	return s.PostgresStore.GetPasswordHash(ctx, username)
+}
+
+func (s *InstrumentedStore) UpdatePasswordHash(ctx context.Context, username model.IndividualId, passwordHash string) (err error) {
+	defer s.observe("UpdatePasswordHash", time.Now(), &err)
+	return s.PostgresStore.UpdatePasswordHash(ctx, username, passwordHash)
+}
+
+func (s *InstrumentedStore) GetDeletedPasswordHash(ctx context.Context, username model.IndividualId) (_ string, _ time.Time, err error) {
+	defer s.observe("GetDeletedPasswordHash", time.Now(), &err)
+	return s.PostgresStore.GetDeletedPasswordHash(ctx, username)
+}
+
+func (s *InstrumentedStore) RestoreIndividual(ctx context.Context, username model.IndividualId) (err error) {
+	defer s.observe("RestoreIndividual", time.Now(), &err)
+	return s.PostgresStore.RestoreIndividual(ctx, username)
+}
+
+func (s *InstrumentedStore) StoreMagicLink(ctx context.Context, email model.Email, tokenHash string, expiresAt time.Time) (_ model.IndividualId, err error) {
+	defer s.observe("StoreMagicLink", time.Now(), &err)
+	return s.PostgresStore.StoreMagicLink(ctx, email, tokenHash, expiresAt)
+}
+
+func (s *InstrumentedStore) ConsumeMagicLink(ctx context.Context, tokenHash string, now time.Time) (_ model.IndividualId, err error) {
+	defer s.observe("ConsumeMagicLink", time.Now(), &err)
+	return s.PostgresStore.ConsumeMagicLink(ctx, tokenHash, now)
+}
+
+func (s *InstrumentedStore) PairStatsForIndividual(ctx context.Context, username model.IndividualId, from, to time.Time) (_ []stats.PairStats, err error) {
+	defer s.observe("PairStatsForIndividual", time.Now(), &err)
+	return s.PostgresStore.PairStatsForIndividual(ctx, username, from, to)
+}
+
+func (s *InstrumentedStore) PairHangoutDates(ctx context.Context, username model.IndividualId, before time.Time) (_ []stats.PairDates, err error) {
+	defer s.observe("PairHangoutDates", time.Now(), &err)
+	return s.PostgresStore.PairHangoutDates(ctx, username, before)
+}
+
+func (s *InstrumentedStore) IndividualsNotSeenSince(ctx context.Context, username model.IndividualId, since, before time.Time) (_ []stats.Reminder, err error) {
+	defer s.observe("IndividualsNotSeenSince", time.Now(), &err)
+	return s.PostgresStore.IndividualsNotSeenSince(ctx, username, since, before)
+}
+
+func (s *InstrumentedStore) GetIndividualForExport(ctx context.Context, username model.IndividualId) (_ model.Individual, _ *time.Time, err error) {
+	defer s.observe("GetIndividualForExport", time.Now(), &err)
+	return s.PostgresStore.GetIndividualForExport(ctx, username)
+}
+
+func (s *InstrumentedStore) ListHangoutsForExport(ctx context.Context, username model.IndividualId, after *storage.HangoutCursor, limit int) (_ export.HangoutPage, err error) {
+	defer s.observe("ListHangoutsForExport", time.Now(), &err)
+	return s.PostgresStore.ListHangoutsForExport(ctx, username, after, limit)
+}
+
+func (s *InstrumentedStore) ListSessionsOfIndividual(ctx context.Context, username model.IndividualId) (_ []export.Session, err error) {
+	defer s.observe("ListSessionsOfIndividual", time.Now(), &err)
+	return s.PostgresStore.ListSessionsOfIndividual(ctx, username)
+}
@@ -0,0 +1,58 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ErrorLabel_NamesTheStorageSentinel(t *testing.T) {
+	tc := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "success", err: nil, want: ""},
+		{name: "generic sentinel", err: storage.ErrNotFound, want: "storage.ErrNotFound"},
+		{name: "wrapped sentinel", err: fmt.Errorf("could not get hangout: %w", storage.ErrDeleted), want: "storage.ErrDeleted"},
+		{name: "specific before generic", err: errors.Join(storage.ErrIndividualEmailAlreadyExists, storage.ErrAlreadyExists), want: "storage.ErrIndividualEmailAlreadyExists"},
+		{name: "session sentinel", err: session.ErrUnknown, want: "session.ErrUnknown"},
+		{name: "unexpected error", err: errors.New("connection reset"), want: ERROR_LABEL_OTHER},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorLabel(tt.err))
+		})
+	}
+}
+
+type storageCall struct {
+	method     string
+	errorLabel string
+}
+
+type fakeRecorder struct {
+	calls []storageCall
+}
+
+func (f *fakeRecorder) ObserveRequest(string, int, time.Duration) {}
+
+func (f *fakeRecorder) ObserveStorageCall(method string, errorLabel string, _ time.Duration) {
+	f.calls = append(f.calls, storageCall{method: method, errorLabel: errorLabel})
+}
+
+func (suite *PostgresStoreTestSuite) TestInstrumentedStore_RecordsCallsAndErrors() {
+	recorder := &fakeRecorder{}
+	store := NewInstrumentedStore(suite.pgStore, recorder)
+
+	_, err := store.GetIndividual(suite.T().Context(), "username")
+	suite.Require().ErrorIs(err, storage.ErrNotFound, "expected the error of the wrapped store")
+
+	assert.Equal(suite.T(), []storageCall{{method: "GetIndividual", errorLabel: "storage.ErrNotFound"}}, recorder.calls, "expected the call to be recorded")
+}
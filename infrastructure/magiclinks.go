@@ -0,0 +1,77 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/jackc/pgx/v5"
+)
+
+func (p *PostgresStore) StoreMagicLink(ctx context.Context, email model.Email, tokenHash string, expiresAt time.Time) (model.IndividualId, error) {
+	queryIndividual := `
+		SELECT id, username, deleted_at
+		FROM individuals
+		WHERE email = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, queryIndividual, email)
+	var id int
+	var username string
+	var deletedAt sql.NullTime
+	if err := row.Scan(&id, &username, &deletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving individual", slog.Any("error", err))
+		return "", storage.ErrUnknown
+	}
+	if deletedAt.Valid {
+		return "", storage.ErrDeleted
+	}
+
+	query := `
+		INSERT INTO magic_links (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	if _, err := p.conn().Exec(ctx, query, tokenHash, id, expiresAt, time.Now()); err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return "", storage.ErrUnknown
+	}
+
+	return model.IndividualId(username), nil
+}
+
+func (p *PostgresStore) ConsumeMagicLink(ctx context.Context, tokenHash string, now time.Time) (model.IndividualId, error) {
+	// a single statement, so that two concurrent exchanges of the same token
+	// cannot both succeed
+	query := `
+		UPDATE magic_links m
+		SET used_at = $2
+		FROM individuals i
+		WHERE m.token_hash = $1
+		  AND m.used_at IS NULL
+		  AND m.expires_at > $2
+		  AND i.id = m.user_id
+		  AND i.deleted_at IS NULL
+		RETURNING i.username;
+	`
+
+	row := p.conn().QueryRow(ctx, query, tokenHash, now)
+	var username string
+	if err := row.Scan(&username); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", auth.ErrMagicLinkInvalid
+		}
+		p.logger.ErrorContext(ctx, "unknown error when consuming magic link", slog.Any("error", err))
+		return "", storage.ErrUnknown
+	}
+
+	return model.IndividualId(username), nil
+}
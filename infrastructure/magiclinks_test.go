@@ -0,0 +1,66 @@
+package infrastructure
+
+import (
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTokenHash = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func (suite *PostgresStoreTestSuite) addUserWithEmail() model.Individual {
+	suite.T().Helper()
+	individual := model.Individual{
+		Username: "username",
+		Name:     "name",
+		Email:    "test@example.com",
+	}
+	err := suite.pgStore.StoreIndividual(suite.T().Context(), individual)
+	suite.Require().NoError(err, "expected no error when storing individual")
+	return individual
+}
+
+func (suite *PostgresStoreTestSuite) TestConsumeMagicLink_ReturnsIndividual_OnlyOnce() {
+	individual := suite.addUserWithEmail()
+
+	userId, err := suite.pgStore.StoreMagicLink(suite.T().Context(), individual.Email, testTokenHash, time.Now().Add(time.Minute))
+	suite.Require().NoError(err, "expected no error when storing magic link")
+	assert.Equal(suite.T(), individual.Username, userId, "expected magic link to belong to the email owner")
+
+	userId, err = suite.pgStore.ConsumeMagicLink(suite.T().Context(), testTokenHash, time.Now())
+	suite.Require().NoError(err, "expected no error when consuming magic link")
+	assert.Equal(suite.T(), individual.Username, userId, "expected magic link to belong to the email owner")
+
+	_, err = suite.pgStore.ConsumeMagicLink(suite.T().Context(), testTokenHash, time.Now())
+	suite.Require().ErrorIs(err, auth.ErrMagicLinkInvalid, "expected magic link to be single use")
+}
+
+func (suite *PostgresStoreTestSuite) TestConsumeMagicLink_ReturnsError_WhenExpired() {
+	individual := suite.addUserWithEmail()
+
+	_, err := suite.pgStore.StoreMagicLink(suite.T().Context(), individual.Email, testTokenHash, time.Now().Add(time.Minute))
+	suite.Require().NoError(err, "expected no error when storing magic link")
+
+	_, err = suite.pgStore.ConsumeMagicLink(suite.T().Context(), testTokenHash, time.Now().Add(2*time.Minute))
+	suite.Require().ErrorIs(err, auth.ErrMagicLinkInvalid, "expected expired magic link to be rejected")
+}
+
+func (suite *PostgresStoreTestSuite) TestConsumeMagicLink_ReturnsError_WhenIndividualIsDeleted() {
+	individual := suite.addUserWithEmail()
+
+	_, err := suite.pgStore.StoreMagicLink(suite.T().Context(), individual.Email, testTokenHash, time.Now().Add(time.Minute))
+	suite.Require().NoError(err, "expected no error when storing magic link")
+	err = suite.pgStore.MarkIndividualAsDeleted(suite.T().Context(), individual.Username)
+	suite.Require().NoError(err, "expected no error when deleting individual")
+
+	_, err = suite.pgStore.ConsumeMagicLink(suite.T().Context(), testTokenHash, time.Now())
+	suite.Require().ErrorIs(err, auth.ErrMagicLinkInvalid, "expected magic link of deleted individual to be rejected")
+}
+
+func (suite *PostgresStoreTestSuite) TestStoreMagicLink_ReturnsError_WhenEmailIsUnknown() {
+	_, err := suite.pgStore.StoreMagicLink(suite.T().Context(), "unknown@example.com", testTokenHash, time.Now().Add(time.Minute))
+	suite.Require().ErrorIs(err, storage.ErrNotFound, "expected error when no individual owns the email")
+}
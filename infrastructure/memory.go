@@ -0,0 +1,1144 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/retention"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/google/uuid"
+)
+
+var _ storage.AppStorage = (*InMemoryStore)(nil)
+var _ stats.Storage = (*InMemoryStore)(nil)
+var _ export.Storage = (*InMemoryStore)(nil)
+var _ retention.Storage = (*InMemoryStore)(nil)
+var _ session.SessionStorage = (*InMemoryStore)(nil)
+var _ auth.CredentialStorage = (*InMemoryStore)(nil)
+var _ auth.MagicLinkStorage = (*InMemoryStore)(nil)
+
+// mirrors the CHAR(44) column of the sessions table
+const MAX_COOKIE_LENGTH = 44
+
+// The in-memory rows mirror the tables, including the internal ids, so that
+// the store behaves like PostgresStore when individuals change or disappear.
+type memoryIndividual struct {
+	id           int
+	individual   model.Individual
+	createdAt    time.Time
+	updatedAt    time.Time
+	deletedAt    *time.Time
+	anonymisedAt *time.Time
+	passwordHash *string
+}
+
+type memoryHangout struct {
+	id       int64
+	publicId model.HangoutId
+	details  model.HangoutDetails
+	// nil once the creator erased their account
+	createdBy *int
+	createdAt time.Time
+	updatedAt time.Time
+	deletedAt *time.Time
+}
+
+type memoryParticipant struct {
+	hangoutId    int64
+	individualId int
+	createdAt    time.Time
+	deletedAt    *time.Time
+}
+
+type memorySession struct {
+	userId       int
+	createdAt    time.Time
+	lastAccessed time.Time
+}
+
+type memoryMagicLink struct {
+	userId    int
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+// InMemoryStore is a concurrency safe storage meant for tests and local
+// development, returning the same errors as PostgresStore.
+type InMemoryStore struct {
+	mu sync.Mutex
+
+	lastIndividualId int
+	lastHangoutId    int64
+
+	individuals  map[int]*memoryIndividual
+	hangouts     map[int64]*memoryHangout
+	participants []*memoryParticipant
+	sessions     map[string]*memorySession
+	magicLinks   map[string]*memoryMagicLink
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		individuals: make(map[int]*memoryIndividual),
+		hangouts:    make(map[int64]*memoryHangout),
+		sessions:    make(map[string]*memorySession),
+		magicLinks:  make(map[string]*memoryMagicLink),
+	}
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) individualByUsername(username model.IndividualId) (*memoryIndividual, bool) {
+	for _, ind := range m.individuals {
+		if ind.individual.Username == username {
+			return ind, true
+		}
+	}
+	return nil, false
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) individualByEmail(email model.Email) (*memoryIndividual, bool) {
+	for _, ind := range m.individuals {
+		if ind.individual.Email == email {
+			return ind, true
+		}
+	}
+	return nil, false
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) insertIndividual(individual model.Individual) (*memoryIndividual, error) {
+	// same order as the constraints of the individuals table
+	if _, ok := m.individualByUsername(individual.Username); ok {
+		return nil, storage.ErrIndividualUsernameAlreadyExists
+	}
+	if _, ok := m.individualByEmail(individual.Email); ok {
+		return nil, storage.ErrIndividualEmailAlreadyExists
+	}
+
+	m.lastIndividualId++
+	now := time.Now()
+	ind := &memoryIndividual{
+		id:         m.lastIndividualId,
+		individual: individual,
+		createdAt:  now,
+		updatedAt:  now,
+	}
+	m.individuals[ind.id] = ind
+	return ind, nil
+}
+
+func (m *InMemoryStore) StoreIndividual(_ context.Context, individual model.Individual) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.insertIndividual(individual)
+	return err
+}
+
+func (m *InMemoryStore) GetIndividual(_ context.Context, username model.IndividualId) (model.Individual, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return model.Individual{}, storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return model.Individual{}, storage.ErrDeleted
+	}
+	return ind.individual, nil
+}
+
+func (m *InMemoryStore) MarkIndividualAsDeleted(_ context.Context, username model.IndividualId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return storage.ErrDeleted
+	}
+	now := time.Now()
+	ind.deletedAt = &now
+	return nil
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) anonymise(ind *memoryIndividual, now time.Time) {
+	ind.individual = model.Individual{
+		Username: model.IndividualId("deleted-" + uuid.NewString()),
+		Email:    model.Email(uuid.NewString() + "@deleted.invalid"),
+	}
+	ind.passwordHash = nil
+	if ind.deletedAt == nil {
+		ind.deletedAt = &now
+	}
+	ind.anonymisedAt = &now
+	ind.updatedAt = now
+	for cookie, s := range m.sessions {
+		if s.userId == ind.id {
+			delete(m.sessions, cookie)
+		}
+	}
+	for tokenHash, link := range m.magicLinks {
+		if link.userId == ind.id {
+			delete(m.magicLinks, tokenHash)
+		}
+	}
+	for _, h := range m.hangouts {
+		if h.createdBy != nil && *h.createdBy == ind.id {
+			h.createdBy = nil
+		}
+	}
+}
+
+func (m *InMemoryStore) AnonymiseDeletedIndividuals(_ context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var anonymised int64
+	for _, ind := range m.individuals {
+		if anonymised >= int64(limit) {
+			break
+		}
+		if ind.deletedAt == nil || ind.anonymisedAt != nil || !ind.deletedAt.Before(deletedBefore) {
+			continue
+		}
+		m.anonymise(ind, now)
+		anonymised++
+	}
+	return anonymised, nil
+}
+
+func (m *InMemoryStore) EraseIndividual(_ context.Context, username model.IndividualId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if ind.anonymisedAt != nil {
+		return storage.ErrDeleted
+	}
+	m.anonymise(ind, time.Now())
+	return nil
+}
+
+func (m *InMemoryStore) StoreHangoutOfIndividuals(_ context.Context, hangout model.Hangout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	creator, ok := m.individualByUsername(hangout.CreatedBy)
+	if !ok {
+		return storage.ErrHangoutCreatorNotFound
+	}
+	if creator.deletedAt != nil {
+		return storage.ErrHangoutCreatorDeleted
+	}
+	for _, h := range m.hangouts {
+		if h.publicId == hangout.PublicId {
+			return storage.ErrAlreadyExists
+		}
+	}
+
+	// resolve everything before writing, the postgres transaction is rolled
+	// back on the first invalid participant
+	participantIds := make([]int, 0, len(hangout.Individuals))
+	for _, username := range hangout.Individuals {
+		participant, ok := m.individualByUsername(username)
+		if !ok {
+			return storage.ErrHangoutParticipantNotFound
+		}
+		if participant.deletedAt != nil {
+			return storage.ErrHangoutParticipantDeleted
+		}
+		participantIds = append(participantIds, participant.id)
+	}
+
+	now := time.Now()
+	m.lastHangoutId++
+	h := &memoryHangout{
+		id:        m.lastHangoutId,
+		publicId:  hangout.PublicId,
+		details:   copyDetails(hangout.HangoutDetails),
+		createdBy: &creator.id,
+		createdAt: now,
+		updatedAt: now,
+	}
+	m.hangouts[h.id] = h
+	for _, id := range participantIds {
+		m.participants = append(m.participants, &memoryParticipant{
+			hangoutId:    h.id,
+			individualId: id,
+			createdAt:    now,
+		})
+	}
+
+	return nil
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) activeHangout(hangoutId model.HangoutId) (*memoryHangout, error) {
+	h, ok := m.hangoutByPublicId(hangoutId)
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	if h.deletedAt != nil {
+		return nil, storage.ErrDeleted
+	}
+	return h, nil
+}
+
+func (m *InMemoryStore) GetHangout(_ context.Context, hangoutId model.HangoutId) (model.Hangout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, err := m.activeHangout(hangoutId)
+	if err != nil {
+		return model.Hangout{}, err
+	}
+	return m.toModel(h), nil
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) toModel(h *memoryHangout) model.Hangout {
+	hangout := model.Hangout{
+		PublicId:       h.publicId,
+		HangoutDetails: copyDetails(h.details),
+	}
+	if h.createdBy != nil {
+		hangout.CreatedBy = m.individuals[*h.createdBy].individual.Username
+	}
+	for _, p := range m.participants {
+		if p.hangoutId != h.id || p.deletedAt != nil {
+			continue
+		}
+		participant := m.individuals[p.individualId]
+		hangout.Individuals = append(hangout.Individuals, participant.individual.Username)
+		if participant.deletedAt != nil {
+			hangout.DeletedIndividuals = append(hangout.DeletedIndividuals, participant.individual.Username)
+		}
+	}
+	return hangout
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) isParticipant(hangoutId int64, individualId int) bool {
+	for _, p := range m.participants {
+		if p.hangoutId == hangoutId && p.individualId == individualId && p.deletedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// compareHangouts orders hangouts the same way postgres does, most recent
+// first.
+func compareHangouts(a, b *memoryHangout) int {
+	if c := b.details.Date.Compare(a.details.Date); c != 0 {
+		return c
+	}
+	return bytes.Compare(b.publicId[:], a.publicId[:])
+}
+
+func (m *InMemoryStore) ListHangoutsForIndividual(_ context.Context, username model.IndividualId, filter storage.HangoutFilter, after *storage.HangoutCursor, limit int) (storage.HangoutPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return storage.HangoutPage{}, storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return storage.HangoutPage{}, storage.ErrDeleted
+	}
+	var with *memoryIndividual
+	if filter.With != "" {
+		with, ok = m.individualByUsername(filter.With)
+		if !ok {
+			return storage.HangoutPage{}, nil
+		}
+	}
+
+	var matching []*memoryHangout
+	for _, h := range m.hangouts {
+		if h.deletedAt != nil || !m.isParticipant(h.id, ind.id) {
+			continue
+		}
+		if with != nil && !m.isParticipant(h.id, with.id) {
+			continue
+		}
+		if !filter.From.IsZero() && h.details.Date.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !h.details.Date.Before(filter.To) {
+			continue
+		}
+		if after != nil && compareHangouts(h, &memoryHangout{publicId: after.PublicId, details: model.HangoutDetails{Date: after.Date}}) <= 0 {
+			continue
+		}
+		matching = append(matching, h)
+	}
+	slices.SortFunc(matching, compareHangouts)
+
+	hangouts := make([]model.Hangout, 0, min(len(matching), limit+1))
+	for _, h := range matching[:min(len(matching), limit+1)] {
+		hangouts = append(hangouts, m.toModel(h))
+	}
+	return newHangoutPage(hangouts, limit), nil
+}
+
+func (m *InMemoryStore) UpdateHangoutDetails(_ context.Context, hangoutId model.HangoutId, details model.HangoutDetails) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, err := m.activeHangout(hangoutId)
+	if err != nil {
+		return err
+	}
+	h.details = copyDetails(details)
+	h.updatedAt = time.Now()
+	return nil
+}
+
+func (m *InMemoryStore) UpdateHangoutParticipants(_ context.Context, hangoutId model.HangoutId, expected, participants []model.IndividualId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, err := m.activeHangout(hangoutId)
+	if err != nil {
+		return err
+	}
+
+	isParticipant := make(map[int]bool)
+	var current []model.IndividualId
+	for _, p := range m.participants {
+		if p.hangoutId == h.id && p.deletedAt == nil {
+			isParticipant[p.individualId] = true
+			current = append(current, m.individuals[p.individualId].individual.Username)
+		}
+	}
+	if !sameParticipants(current, expected) {
+		return storage.ErrHangoutParticipantsChanged
+	}
+
+	// resolve everything before writing, same as the postgres transaction
+	kept := make(map[int]bool, len(participants))
+	var added []int
+	for _, username := range participants {
+		participant, ok := m.individualByUsername(username)
+		if !ok {
+			return storage.ErrHangoutParticipantNotFound
+		}
+		if isParticipant[participant.id] || kept[participant.id] {
+			kept[participant.id] = true
+			continue
+		}
+		if participant.deletedAt != nil {
+			return storage.ErrHangoutParticipantDeleted
+		}
+		kept[participant.id] = true
+		added = append(added, participant.id)
+	}
+
+	now := time.Now()
+	for _, p := range m.participants {
+		if p.hangoutId == h.id && p.deletedAt == nil && !kept[p.individualId] {
+			p.deletedAt = &now
+		}
+	}
+	for _, id := range added {
+		m.participants = append(m.participants, &memoryParticipant{
+			hangoutId:    h.id,
+			individualId: id,
+			createdAt:    now,
+		})
+	}
+	h.updatedAt = now
+
+	return nil
+}
+
+func (m *InMemoryStore) hangoutByPublicId(hangoutId model.HangoutId) (*memoryHangout, bool) {
+	for _, h := range m.hangouts {
+		if h.publicId == hangoutId {
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+func (m *InMemoryStore) GetHangoutCreator(_ context.Context, hangoutId model.HangoutId) (model.IndividualId, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.hangoutByPublicId(hangoutId)
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	if h.createdBy == nil {
+		return "", storage.ErrHangoutCreatorNotFound
+	}
+	return m.individuals[*h.createdBy].individual.Username, nil
+}
+
+func (m *InMemoryStore) IsHangoutParticipant(_ context.Context, hangoutId model.HangoutId, individual model.IndividualId) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.hangoutByPublicId(hangoutId)
+	if !ok {
+		return false, storage.ErrNotFound
+	}
+	ind, ok := m.individualByUsername(individual)
+	if !ok {
+		return false, nil
+	}
+	return m.isParticipant(h.id, ind.id), nil
+}
+
+func (m *InMemoryStore) MarkHangoutAsDeleted(_ context.Context, hangoutId model.HangoutId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, err := m.activeHangout(hangoutId)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	h.deletedAt = &now
+	h.updatedAt = now
+	return nil
+}
+
+func (m *InMemoryStore) RestoreHangout(_ context.Context, hangoutId model.HangoutId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.hangoutByPublicId(hangoutId)
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if h.deletedAt == nil {
+		return storage.ErrNotDeleted
+	}
+	h.deletedAt = nil
+	h.updatedAt = time.Now()
+	return nil
+}
+
+func (m *InMemoryStore) PurgeDeletedHangouts(_ context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed int64
+	for id, h := range m.hangouts {
+		if removed >= int64(limit) {
+			break
+		}
+		if h.deletedAt == nil || !h.deletedAt.Before(deletedBefore) {
+			continue
+		}
+		delete(m.hangouts, id)
+		// mirrors the fk_hangout cascade
+		m.participants = slices.DeleteFunc(m.participants, func(p *memoryParticipant) bool {
+			return p.hangoutId == id
+		})
+		removed++
+	}
+	return removed, nil
+}
+
+func (m *InMemoryStore) RemoveHangoutParticipant(_ context.Context, hangoutId model.HangoutId, participant model.IndividualId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, err := m.activeHangout(hangoutId)
+	if err != nil {
+		return err
+	}
+	ind, ok := m.individualByUsername(participant)
+	if !ok || !m.isParticipant(h.id, ind.id) {
+		return storage.ErrNotParticipant
+	}
+
+	now := time.Now()
+	for _, p := range m.participants {
+		if p.hangoutId == h.id && p.individualId == ind.id && p.deletedAt == nil {
+			p.deletedAt = &now
+		}
+	}
+	h.updatedAt = now
+	return nil
+}
+
+// the description is a pointer, do not share it with the caller
+func copyDetails(details model.HangoutDetails) model.HangoutDetails {
+	if details.Description != nil {
+		description := *details.Description
+		details.Description = &description
+	}
+	return details
+}
+
+func (m *InMemoryStore) StoreSession(_ context.Context, sesh session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(sesh.UserID)
+	if !ok {
+		return session.ErrUserNotFound
+	}
+	if ind.deletedAt != nil {
+		return session.ErrUserDeleted
+	}
+	if len(sesh.CookieValue) > MAX_COOKIE_LENGTH {
+		return session.ErrCookieInvalidLength
+	}
+	// primary key violation
+	if _, ok := m.sessions[sesh.CookieValue]; ok {
+		return session.ErrUnknown
+	}
+
+	m.sessions[sesh.CookieValue] = &memorySession{
+		userId:       ind.id,
+		createdAt:    sesh.CreatedAt,
+		lastAccessed: sesh.LastAccessed,
+	}
+	return nil
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) sessionOfActiveUser(cookie string) (*memorySession, *memoryIndividual, error) {
+	s, ok := m.sessions[cookie]
+	if !ok {
+		return nil, nil, session.ErrNotFound
+	}
+	ind := m.individuals[s.userId]
+	if ind.deletedAt != nil {
+		return nil, nil, session.ErrUserDeleted
+	}
+	return s, ind, nil
+}
+
+func (m *InMemoryStore) GetSession(_ context.Context, cookie string) (session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ind, err := m.sessionOfActiveUser(cookie)
+	if err != nil {
+		return session.Session{}, err
+	}
+	return session.Session{
+		CreatedAt:    s.createdAt,
+		LastAccessed: s.lastAccessed,
+		CookieValue:  cookie,
+		UserID:       ind.individual.Username,
+	}, nil
+}
+
+func (m *InMemoryStore) UpdateLastAccessed(_ context.Context, cookie string, lastAccessed time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, _, err := m.sessionOfActiveUser(cookie)
+	if err != nil {
+		return err
+	}
+	s.lastAccessed = lastAccessed
+	return nil
+}
+
+func (m *InMemoryStore) DeleteSession(_ context.Context, cookie string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[cookie]; !ok {
+		return session.ErrNotFound
+	}
+	delete(m.sessions, cookie)
+	return nil
+}
+
+func (m *InMemoryStore) DeleteSessionsOfUser(_ context.Context, userId model.IndividualId) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(userId)
+	if !ok {
+		return 0, session.ErrUserNotFound
+	}
+	var removed int64
+	for cookie, s := range m.sessions {
+		if s.userId == ind.id {
+			delete(m.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (m *InMemoryStore) DeleteOtherSessionsOfUser(_ context.Context, userId model.IndividualId, keepCookie string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(userId)
+	if !ok {
+		return 0, session.ErrUserNotFound
+	}
+	var removed int64
+	for cookie, s := range m.sessions {
+		if s.userId == ind.id && cookie != keepCookie {
+			delete(m.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (m *InMemoryStore) RotateSession(_ context.Context, oldCookie string, newSession session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.sessions[oldCookie]
+	if !ok {
+		return session.ErrNotFound
+	}
+	if len(newSession.CookieValue) > MAX_COOKIE_LENGTH {
+		return session.ErrCookieInvalidLength
+	}
+	if _, ok := m.sessions[newSession.CookieValue]; ok {
+		return session.ErrUnknown
+	}
+
+	delete(m.sessions, oldCookie)
+	m.sessions[newSession.CookieValue] = &memorySession{
+		userId:       old.userId,
+		createdAt:    newSession.CreatedAt,
+		lastAccessed: newSession.LastAccessed,
+	}
+	return nil
+}
+
+func (m *InMemoryStore) DeleteExpiredSessions(_ context.Context, idleBefore, createdBefore time.Time, limit int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed int64
+	for cookie, s := range m.sessions {
+		if removed == int64(limit) {
+			break
+		}
+		if s.lastAccessed.Before(idleBefore) || s.createdAt.Before(createdBefore) {
+			delete(m.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (m *InMemoryStore) CountActiveSessions(_ context.Context, idleAfter, createdAfter time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for _, s := range m.sessions {
+		if !s.lastAccessed.Before(idleAfter) && !s.createdAt.Before(createdAfter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *InMemoryStore) StoreIndividualWithPassword(_ context.Context, individual model.Individual, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, err := m.insertIndividual(individual)
+	if err != nil {
+		return err
+	}
+	ind.passwordHash = &passwordHash
+	return nil
+}
+
+func (m *InMemoryStore) GetPasswordHash(_ context.Context, username model.IndividualId) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return "", storage.ErrDeleted
+	}
+	if ind.passwordHash == nil {
+		return "", auth.ErrPasswordNotSet
+	}
+	return *ind.passwordHash, nil
+}
+
+func (m *InMemoryStore) UpdatePasswordHash(_ context.Context, username model.IndividualId, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return storage.ErrDeleted
+	}
+	ind.passwordHash = &passwordHash
+	return nil
+}
+
+func (m *InMemoryStore) GetDeletedPasswordHash(_ context.Context, username model.IndividualId) (string, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return "", time.Time{}, storage.ErrNotFound
+	}
+	if ind.deletedAt == nil {
+		return "", time.Time{}, storage.ErrNotDeleted
+	}
+	if ind.passwordHash == nil {
+		return "", time.Time{}, auth.ErrPasswordNotSet
+	}
+	return *ind.passwordHash, *ind.deletedAt, nil
+}
+
+func (m *InMemoryStore) RestoreIndividual(_ context.Context, username model.IndividualId) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if ind.deletedAt == nil {
+		return storage.ErrNotDeleted
+	}
+	ind.deletedAt = nil
+	ind.updatedAt = time.Now()
+	return nil
+}
+
+func (m *InMemoryStore) StoreMagicLink(_ context.Context, email model.Email, tokenHash string, expiresAt time.Time) (model.IndividualId, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByEmail(email)
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return "", storage.ErrDeleted
+	}
+	if _, ok := m.magicLinks[tokenHash]; ok {
+		return "", storage.ErrUnknown
+	}
+
+	m.magicLinks[tokenHash] = &memoryMagicLink{
+		userId:    ind.id,
+		expiresAt: expiresAt,
+	}
+	return ind.individual.Username, nil
+}
+
+func (m *InMemoryStore) ConsumeMagicLink(_ context.Context, tokenHash string, now time.Time) (model.IndividualId, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.magicLinks[tokenHash]
+	if !ok || link.usedAt != nil || !link.expiresAt.After(now) {
+		return "", auth.ErrMagicLinkInvalid
+	}
+	ind := m.individuals[link.userId]
+	if ind.deletedAt != nil {
+		return "", auth.ErrMagicLinkInvalid
+	}
+
+	link.usedAt = &now
+	return ind.individual.Username, nil
+}
+
+func (m *InMemoryStore) PairStatsForIndividual(_ context.Context, username model.IndividualId, from, to time.Time) ([]stats.PairStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return nil, storage.ErrDeleted
+	}
+
+	type pair struct {
+		hangoutId    int64
+		individualId int
+	}
+	counted := make(map[pair]bool)
+	byIndividual := make(map[int]*stats.PairStats)
+	for _, h := range m.hangouts {
+		if h.deletedAt != nil || !m.isParticipant(h.id, ind.id) {
+			continue
+		}
+		if h.details.Date.Before(from) || !h.details.Date.Before(to) {
+			continue
+		}
+		for _, p := range m.participants {
+			if p.hangoutId != h.id || p.deletedAt != nil || p.individualId == ind.id {
+				continue
+			}
+			if counted[pair{h.id, p.individualId}] {
+				continue
+			}
+			counted[pair{h.id, p.individualId}] = true
+
+			s, ok := byIndividual[p.individualId]
+			if !ok {
+				other := m.individuals[p.individualId]
+				s = &stats.PairStats{
+					With:    other.individual.Username,
+					Deleted: other.deletedAt != nil,
+				}
+				byIndividual[p.individualId] = s
+			}
+			s.Hangouts++
+			s.Minutes += h.details.Duration
+		}
+	}
+
+	result := make([]stats.PairStats, 0, len(byIndividual))
+	for _, s := range byIndividual {
+		result = append(result, *s)
+	}
+	slices.SortFunc(result, func(a, b stats.PairStats) int {
+		if a.Hangouts != b.Hangouts {
+			return b.Hangouts - a.Hangouts
+		}
+		if a.Minutes != b.Minutes {
+			return int(b.Minutes - a.Minutes)
+		}
+		return strings.Compare(string(a.With), string(b.With))
+	})
+	return result, nil
+}
+
+// pairHangouts groups the hangouts of the individual dated before the given
+// time per co-participant, oldest first.
+func (m *InMemoryStore) pairHangouts(ind *memoryIndividual, before time.Time) map[int][]*memoryHangout {
+	byIndividual := make(map[int][]*memoryHangout)
+	for _, h := range m.hangouts {
+		if h.deletedAt != nil || !m.isParticipant(h.id, ind.id) || !h.details.Date.Before(before) {
+			continue
+		}
+		for _, p := range m.participants {
+			if p.hangoutId != h.id || p.deletedAt != nil || p.individualId == ind.id {
+				continue
+			}
+			if slices.Contains(byIndividual[p.individualId], h) {
+				continue
+			}
+			byIndividual[p.individualId] = append(byIndividual[p.individualId], h)
+		}
+	}
+	for _, hangouts := range byIndividual {
+		slices.SortFunc(hangouts, func(a, b *memoryHangout) int {
+			return -compareHangouts(a, b)
+		})
+	}
+	return byIndividual
+}
+
+func (m *InMemoryStore) PairHangoutDates(_ context.Context, username model.IndividualId, before time.Time) ([]stats.PairDates, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return nil, storage.ErrDeleted
+	}
+
+	var result []stats.PairDates
+	for individualId, hangouts := range m.pairHangouts(ind, before) {
+		other := m.individuals[individualId]
+		pair := stats.PairDates{
+			With:    other.individual.Username,
+			Deleted: other.deletedAt != nil,
+		}
+		for _, h := range hangouts {
+			pair.Dates = append(pair.Dates, h.details.Date)
+		}
+		result = append(result, pair)
+	}
+	slices.SortFunc(result, func(a, b stats.PairDates) int {
+		return strings.Compare(string(a.With), string(b.With))
+	})
+	return result, nil
+}
+
+func (m *InMemoryStore) IndividualsNotSeenSince(_ context.Context, username model.IndividualId, since, before time.Time) ([]stats.Reminder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	if ind.deletedAt != nil {
+		return nil, storage.ErrDeleted
+	}
+
+	var result []stats.Reminder
+	for individualId, hangouts := range m.pairHangouts(ind, before) {
+		other := m.individuals[individualId]
+		lastSeen := hangouts[len(hangouts)-1].details.Date
+		if other.deletedAt != nil || !lastSeen.Before(since) {
+			continue
+		}
+		result = append(result, stats.Reminder{
+			With:     other.individual.Username,
+			LastSeen: lastSeen,
+			Hangouts: len(hangouts),
+		})
+	}
+	slices.SortFunc(result, func(a, b stats.Reminder) int {
+		if a.Hangouts != b.Hangouts {
+			return b.Hangouts - a.Hangouts
+		}
+		if c := a.LastSeen.Compare(b.LastSeen); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.With), string(b.With))
+	})
+	return result, nil
+}
+
+// must be called with the lock held
+func (m *InMemoryStore) exportedIndividual(username model.IndividualId) (*memoryIndividual, error) {
+	ind, ok := m.individualByUsername(username)
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	if ind.anonymisedAt != nil {
+		return nil, storage.ErrDeleted
+	}
+	return ind, nil
+}
+
+func (m *InMemoryStore) GetIndividualForExport(_ context.Context, username model.IndividualId) (model.Individual, *time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, err := m.exportedIndividual(username)
+	if err != nil {
+		return model.Individual{}, nil, err
+	}
+	if ind.deletedAt != nil {
+		deletedAt := *ind.deletedAt
+		return ind.individual, &deletedAt, nil
+	}
+	return ind.individual, nil, nil
+}
+
+func (m *InMemoryStore) ListHangoutsForExport(_ context.Context, username model.IndividualId, after *storage.HangoutCursor, limit int) (export.HangoutPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, err := m.exportedIndividual(username)
+	if err != nil {
+		return export.HangoutPage{}, err
+	}
+
+	// when the individual last left each hangout, nil while one of their
+	// participations is current
+	leftAt := make(map[int64]*time.Time)
+	for _, p := range m.participants {
+		if p.individualId != ind.id {
+			continue
+		}
+		left, seen := leftAt[p.hangoutId]
+		switch {
+		case p.deletedAt == nil:
+			leftAt[p.hangoutId] = nil
+		case !seen || (left != nil && left.Before(*p.deletedAt)):
+			at := *p.deletedAt
+			leftAt[p.hangoutId] = &at
+		}
+	}
+
+	var matching []*memoryHangout
+	for _, h := range m.hangouts {
+		if _, participated := leftAt[h.id]; !participated {
+			continue
+		}
+		if after != nil && compareHangouts(h, &memoryHangout{publicId: after.PublicId, details: model.HangoutDetails{Date: after.Date}}) <= 0 {
+			continue
+		}
+		matching = append(matching, h)
+	}
+	slices.SortFunc(matching, compareHangouts)
+
+	hangouts := make([]export.Hangout, 0, min(len(matching), limit+1))
+	for _, h := range matching[:min(len(matching), limit+1)] {
+		hangout := export.Hangout{Hangout: m.toModel(h), LeftAt: leftAt[h.id]}
+		if h.deletedAt != nil {
+			deletedAt := *h.deletedAt
+			hangout.DeletedAt = &deletedAt
+		}
+		hangouts = append(hangouts, hangout)
+	}
+	return newExportPage(hangouts, limit), nil
+}
+
+// ListSessionsOfIndividual also returns the sessions of individuals that
+// deleted their account, since they work again once it is restored.
+func (m *InMemoryStore) ListSessionsOfIndividual(_ context.Context, username model.IndividualId) ([]export.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ind, err := m.exportedIndividual(username)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []export.Session{}
+	for _, s := range m.sessions {
+		if s.userId == ind.id {
+			sessions = append(sessions, export.Session{
+				CreatedAt:    s.createdAt,
+				LastAccessed: s.lastAccessed,
+			})
+		}
+	}
+	slices.SortFunc(sessions, func(a, b export.Session) int {
+		if c := b.LastAccessed.Compare(a.LastAccessed); c != 0 {
+			return c
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return sessions, nil
+}
@@ -0,0 +1,361 @@
+package infrastructure
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Ozoniuss/hangcounts/web/health"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The migrator is compatible with golang-migrate v4, which applied the
+// migrations before: it uses the same version table and the same advisory
+// lock, so the two can be used interchangeably on the same database.
+const (
+	MIGRATIONS_TABLE = "schema_migrations"
+
+	// the version of a database without any migration
+	NIL_VERSION int64 = -1
+
+	// golang-migrate salts the checksum of the lock name with this
+	ADVISORY_LOCK_SALT uint32 = 1486364155
+)
+
+var ErrInvalidMigrations = errors.New("invalid migration files")
+var ErrDirtyDatabase = errors.New("database is dirty, fix the failed migration and force the version")
+var ErrUnknownVersion = errors.New("version has no migration file")
+var ErrMissingDownMigration = errors.New("migration has no down file")
+var ErrNotEnoughMigrations = errors.New("not enough applied migrations")
+
+var _ health.Migrations = (*Migrator)(nil)
+
+// same as the file names accepted by golang-migrate
+var migrationFileName = regexp.MustCompile(`^([0-9]+)_(.*)\.(down|up)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	hasDown bool
+}
+
+// LoadMigrations reads the migrations at the root of fsys, ordered by version.
+// Every migration must have an up file.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid version in %s: %w", ErrInvalidMigrations, entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d has different names", ErrInvalidMigrations, version)
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+			m.hasDown = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has no up file", ErrInvalidMigrations, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// advisoryLockId returns the lock golang-migrate takes on the version table.
+func advisoryLockId(database, schema, table string) int64 {
+	sum := crc32.ChecksumIEEE([]byte(strings.Join([]string{schema, table, database}, "\x00")))
+	return int64(sum * ADVISORY_LOCK_SALT)
+}
+
+// Migrator applies the migrations to the database of a store. Every operation
+// holds an advisory lock, so replicas starting at the same time wait for each
+// other instead of racing.
+type Migrator struct {
+	// the pool of the store is replaced when the password is rotated
+	store      *PostgresStore
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+func NewMigrator(p *PostgresStore, migrations fs.FS) (*Migrator, error) {
+	loaded, err := LoadMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		store:      p,
+		migrations: loaded,
+		logger:     p.logger,
+	}, nil
+}
+
+// withLock runs f on a single connection, since advisory locks belong to the
+// session that took them.
+func (m *Migrator) withLock(ctx context.Context, f func(conn *pgxpool.Conn) error) error {
+	conn, err := m.store.conn().Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("could not acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var database, schema string
+	if err := conn.QueryRow(ctx, `SELECT current_database(), current_schema();`).Scan(&database, &schema); err != nil {
+		return fmt.Errorf("could not read current schema: %w", err)
+	}
+	lockId := advisoryLockId(database, schema, MIGRATIONS_TABLE)
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1);`, lockId); err != nil {
+		return fmt.Errorf("could not take migration lock: %w", err)
+	}
+	defer func() {
+		// the lock must be released even if ctx is cancelled, otherwise it
+		// stays with the connection in the pool
+		unlockCtx := context.WithoutCancel(ctx)
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1);`, lockId); err != nil {
+			m.logger.ErrorContext(ctx, "could not release migration lock, closing the connection", slog.Any("error", err))
+			conn.Hijack().Close(unlockCtx)
+		}
+	}()
+
+	query := `
+		CREATE TABLE IF NOT EXISTS ` + MIGRATIONS_TABLE + ` (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty   BOOLEAN NOT NULL
+		);
+	`
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return fmt.Errorf("could not create version table: %w", err)
+	}
+
+	return f(conn)
+}
+
+func (m *Migrator) version(ctx context.Context, conn *pgxpool.Conn) (int64, bool, error) {
+	row := conn.QueryRow(ctx, `SELECT version, dirty FROM `+MIGRATIONS_TABLE+` LIMIT 1;`)
+	var version int64
+	var dirty bool
+	if err := row.Scan(&version, &dirty); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return NIL_VERSION, false, nil
+		}
+		return 0, false, fmt.Errorf("could not read version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// setVersion stores the version the same way golang-migrate does, with the
+// table left empty for a clean database without migrations.
+func (m *Migrator) setVersion(ctx context.Context, conn *pgxpool.Conn, version int64, dirty bool) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not start a transaction: %w", err)
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			m.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	if _, err := tx.Exec(ctx, `TRUNCATE `+MIGRATIONS_TABLE+`;`); err != nil {
+		return fmt.Errorf("could not clear version: %w", err)
+	}
+	if version != NIL_VERSION || dirty {
+		if _, err := tx.Exec(ctx, `INSERT INTO `+MIGRATIONS_TABLE+` (version, dirty) VALUES ($1, $2);`, version, dirty); err != nil {
+			return fmt.Errorf("could not store version: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit version: %w", err)
+	}
+	return nil
+}
+
+// position returns the index of the migration of the version, which is -1 for
+// a database without migrations.
+func (m *Migrator) position(version int64) (int, error) {
+	if version == NIL_VERSION {
+		return -1, nil
+	}
+	i := slices.IndexFunc(m.migrations, func(mig Migration) bool {
+		return mig.Version == version
+	})
+	if i < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return i, nil
+}
+
+// cleanPosition returns the position of the current version of the database,
+// which must not be dirty.
+func (m *Migrator) cleanPosition(ctx context.Context, conn *pgxpool.Conn) (int, error) {
+	current, dirty, err := m.version(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w: version %d", ErrDirtyDatabase, current)
+	}
+	return m.position(current)
+}
+
+// run executes a migration file. The database stays dirty at the target
+// version if it fails, as with golang-migrate.
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, target int64, body string) error {
+	if err := m.setVersion(ctx, conn, target, true); err != nil {
+		return err
+	}
+	// without arguments the file is sent with the simple protocol, which
+	// allows multiple statements
+	if _, err := conn.Exec(ctx, body); err != nil {
+		return fmt.Errorf("%w: %w", ErrDirtyDatabase, err)
+	}
+	return m.setVersion(ctx, conn, target, false)
+}
+
+// Up applies all the migrations after the current version.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		i, err := m.cleanPosition(ctx, conn)
+		if err != nil {
+			return err
+		}
+		pending := m.migrations[i+1:]
+		if len(pending) == 0 {
+			m.logger.InfoContext(ctx, "database is up to date")
+			return nil
+		}
+		for _, mig := range pending {
+			if err := m.run(ctx, conn, mig.Version, mig.Up); err != nil {
+				return fmt.Errorf("could not apply migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			m.logger.InfoContext(ctx, "applied migration", slog.Int64("version", mig.Version), slog.String("name", mig.Name))
+		}
+		return nil
+	})
+}
+
+// Down reverts the last n applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		i, err := m.cleanPosition(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if n > i+1 {
+			return fmt.Errorf("%w: cannot revert %d, only %d are applied", ErrNotEnoughMigrations, n, i+1)
+		}
+		// check first to not stop halfway through
+		reverted := m.migrations[i+1-n : i+1]
+		for _, mig := range reverted {
+			if !mig.hasDown {
+				return fmt.Errorf("%w: %d_%s", ErrMissingDownMigration, mig.Version, mig.Name)
+			}
+		}
+
+		for j := i; j > i-n; j-- {
+			mig := m.migrations[j]
+			target := NIL_VERSION
+			if j > 0 {
+				target = m.migrations[j-1].Version
+			}
+			if err := m.run(ctx, conn, target, mig.Down); err != nil {
+				return fmt.Errorf("could not revert migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			m.logger.InfoContext(ctx, "reverted migration", slog.Int64("version", mig.Version), slog.String("name", mig.Name))
+		}
+		return nil
+	})
+}
+
+// Latest returns the version of the last migration, which the database has
+// once it is up to date.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return NIL_VERSION
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns the version of the database like Version, but without taking
+// the lock, so it does not wait for the migrations being applied.
+func (m *Migrator) Status(ctx context.Context) (int64, bool, error) {
+	row := m.store.conn().QueryRow(ctx, `SELECT version, dirty FROM `+MIGRATIONS_TABLE+` LIMIT 1;`)
+	var version int64
+	var dirty bool
+	if err := row.Scan(&version, &dirty); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UndefinedTable) {
+			return NIL_VERSION, false, nil
+		}
+		return 0, false, fmt.Errorf("could not read version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// Version returns the current version of the database, and whether the last
+// migration failed.
+func (m *Migrator) Version(ctx context.Context) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		var err error
+		version, dirty, err = m.version(ctx, conn)
+		return err
+	})
+	return version, dirty, err
+}
+
+// Force sets the version without running any migration, which is how a dirty
+// database is recovered after the failed migration was fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if _, err := m.position(version); err != nil {
+		return err
+	}
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		return m.setVersion(ctx, conn, version, false)
+	})
+}
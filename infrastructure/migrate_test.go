@@ -0,0 +1,74 @@
+package infrastructure
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Ozoniuss/hangcounts/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadMigrations_PairsFilesAndSortsByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"2_second.up.sql":   {Data: []byte("up 2")},
+		"1_first.up.sql":    {Data: []byte("up 1")},
+		"1_first.down.sql":  {Data: []byte("down 1")},
+		"migrations.go":     {Data: []byte("package migrations")},
+		"10_tenth.up.sql":   {Data: []byte("up 10")},
+		"10_tenth.down.sql": {Data: []byte("down 10")},
+	}
+
+	got, err := LoadMigrations(fsys)
+	require.NoError(t, err, "expected migrations to be valid")
+	assert.Equal(t, []Migration{
+		{Version: 1, Name: "first", Up: "up 1", Down: "down 1", hasDown: true},
+		{Version: 2, Name: "second", Up: "up 2"},
+		{Version: 10, Name: "tenth", Up: "up 10", Down: "down 10", hasDown: true},
+	}, got)
+}
+
+func Test_LoadMigrations_MissingUpFile_ReturnsError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_first.down.sql": {Data: []byte("down 1")},
+	}
+
+	_, err := LoadMigrations(fsys)
+	assert.ErrorIs(t, err, ErrInvalidMigrations)
+}
+
+func Test_LoadMigrations_EmbeddedMigrations_CanAllBeReverted(t *testing.T) {
+	got, err := LoadMigrations(migrations.FS)
+	require.NoError(t, err, "expected embedded migrations to be valid")
+	require.NotEmpty(t, got, "expected the migrations to be embedded")
+	for _, m := range got {
+		assert.True(t, m.hasDown, "expected migration %d_%s to have a down file", m.Version, m.Name)
+	}
+}
+
+func Test_Migrator_Up_IsNoop_AfterGolangMigrate(t *testing.T) {
+	if os.Getenv("HANGCOUNTS_RUN_INTEGRATION_TESTS") != "true" {
+		t.Skipf("Skipping integration tests, HANGCOUNTS_RUN_INTEGRATION_TESTS is not true")
+	}
+
+	pg, err := newTestPostgresStore(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	require.NoError(t, err, "could not connect to database")
+	defer pg.Close()
+
+	migrator, err := NewMigrator(pg, migrations.FS)
+	require.NoError(t, err, "expected embedded migrations to be valid")
+
+	// the integration tests database is migrated by golang-migrate
+	require.NoError(t, migrator.Up(t.Context()), "expected no error when the database is up to date")
+	version, dirty, err := migrator.Version(t.Context())
+	require.NoError(t, err, "expected no error when reading the version")
+	assert.False(t, dirty, "expected the database to be clean")
+	assert.Equal(t, migrator.Latest(), version, "expected the latest version")
+
+	status, dirty, err := migrator.Status(t.Context())
+	require.NoError(t, err, "expected no error when reading the status")
+	assert.False(t, dirty, "expected the database to be clean")
+	assert.Equal(t, version, status, "expected the status to match the locked version")
+}
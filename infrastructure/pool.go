@@ -0,0 +1,134 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/Ozoniuss/hangcounts/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// connString returns the postgres:// url of the configuration, without the
+// password. The settings are query parameters, which override the parts of
+// the configured url.
+func connString(cfg config.PostgresConfig) (string, error) {
+	// the path makes the url start with postgres://, which pgx expects
+	u := &url.URL{Scheme: "postgres", Path: "/"}
+	if !cfg.URL.IsEmpty() {
+		parsed, err := url.Parse(cfg.URL.Reveal())
+		if err != nil {
+			// the error would contain the url and its password
+			return "", errors.New("could not parse postgres url")
+		}
+		u = parsed
+	}
+
+	q := u.Query()
+	set := func(key, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	set("host", cfg.Host)
+	if cfg.Port != 0 {
+		set("port", strconv.Itoa(cfg.Port))
+	}
+	set("user", cfg.User)
+	set("dbname", cfg.DbName)
+	set("sslmode", cfg.SSLMode)
+	set("sslrootcert", cfg.SSLRootCert)
+	set("sslcert", cfg.SSLCert)
+	set("sslkey", cfg.SSLKey)
+	set("application_name", cfg.ApplicationName)
+	if cfg.ConnectTimeout > 0 {
+		// libpq only accepts seconds, rounded up to not disable the timeout
+		set("connect_timeout", strconv.Itoa(int(math.Ceil(cfg.ConnectTimeout.Seconds()))))
+	}
+	if cfg.StatementTimeout > 0 {
+		// sent as a runtime parameter, in milliseconds
+		set("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
+	}
+	if cfg.MaxConns > 0 {
+		set("pool_max_conns", strconv.Itoa(int(cfg.MaxConns)))
+	}
+	if cfg.MinConns > 0 {
+		set("pool_min_conns", strconv.Itoa(int(cfg.MinConns)))
+	}
+	if cfg.MaxConnLifetime > 0 {
+		set("pool_max_conn_lifetime", cfg.MaxConnLifetime.String())
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		set("pool_max_conn_idle_time", cfg.MaxConnIdleTime.String())
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		set("pool_health_check_period", cfg.HealthCheckPeriod.String())
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func newPoolConfig(cfg config.PostgresConfig) (*pgxpool.Config, error) {
+	connStr, err := connString(cfg)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		// pgx redacts the password of the url in its errors
+		return nil, fmt.Errorf("could not parse connection settings: %w", err)
+	}
+	// set after parsing, the password would need to be escaped in the url
+	if !cfg.Password.IsEmpty() {
+		poolCfg.ConnConfig.Password = cfg.Password.Reveal()
+	}
+	return poolCfg, nil
+}
+
+// logPoolConfig logs the effective settings, after the defaults of pgx and
+// the url were applied, without the password.
+func logPoolConfig(ctx context.Context, logger *slog.Logger, poolCfg *pgxpool.Config) {
+	connCfg := poolCfg.ConnConfig
+	logger.InfoContext(ctx, "pool configuration",
+		slog.String("host", connCfg.Host),
+		slog.Int("port", int(connCfg.Port)),
+		slog.String("user", connCfg.User),
+		slog.String("database", connCfg.Database),
+		slog.Bool("password_set", connCfg.Password != ""),
+		slog.Bool("tls", connCfg.TLSConfig != nil),
+		slog.Int("fallbacks", len(connCfg.Fallbacks)),
+		slog.String("application_name", connCfg.RuntimeParams["application_name"]),
+		slog.String("statement_timeout_ms", connCfg.RuntimeParams["statement_timeout"]),
+		slog.Duration("connect_timeout", connCfg.ConnectTimeout),
+		slog.Int("max_conns", int(poolCfg.MaxConns)),
+		slog.Int("min_conns", int(poolCfg.MinConns)),
+		slog.Duration("max_conn_lifetime", poolCfg.MaxConnLifetime),
+		slog.Duration("max_conn_idle_time", poolCfg.MaxConnIdleTime),
+		slog.Duration("health_check_period", poolCfg.HealthCheckPeriod),
+	)
+}
+
+// newPool connects to the database, checking that the credentials work.
+func newPool(ctx context.Context, cfg config.PostgresConfig, logger *slog.Logger) (*pgxpool.Pool, error) {
+	poolCfg, err := newPoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logPoolConfig(ctx, logger, poolCfg)
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	err = pool.Ping(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return pool, nil
+}
@@ -6,11 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Ozoniuss/hangcounts/config"
 	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/retention"
 	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/health"
 	"github.com/Ozoniuss/hangcounts/web/session"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
@@ -28,38 +33,130 @@ const (
 	CONSTRAINT_FOREIGN_KEY_SESSION_USER    = "fk_session_user"
 )
 
+var _ storage.AppStorage = (*PostgresStore)(nil)
+var _ retention.Storage = (*PostgresStore)(nil)
+var _ session.SessionStorage = (*PostgresStore)(nil)
+var _ auth.CredentialStorage = (*PostgresStore)(nil)
+var _ auth.MagicLinkStorage = (*PostgresStore)(nil)
+var _ health.Database = (*PostgresStore)(nil)
+
 type PostgresStore struct {
-	conn   *pgxpool.Pool
+	// the pool is replaced when the password is rotated, use conn to get the
+	// current one
+	pool   atomic.Pointer[pgxpool.Pool]
 	logger *slog.Logger
+
+	// reloadMu serializes the reloads, cfg has the password of the current pool
+	reloadMu sync.Mutex
+	cfg      config.PostgresConfig
 }
 
 func NewPostgresStore(ctx context.Context, cfg config.PostgresConfig, logger *slog.Logger) (*PostgresStore, error) {
-
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s",
-		cfg.Host,
-		cfg.Port,
-		cfg.User,
-		cfg.Password,
-		cfg.DbName)
-	connCfg, err := pgxpool.ParseConfig(dsn)
+	pool, err := newPool(ctx, cfg, logger)
 	if err != nil {
-		return nil, fmt.Errorf("could not parse dsn: %w", err)
+		return nil, err
+	}
+
+	p := &PostgresStore{
+		logger: logger,
+		cfg:    cfg,
 	}
-	conn, err := pgxpool.NewWithConfig(ctx, connCfg)
+	p.pool.Store(pool)
+	return p, nil
+}
+
+func (p *PostgresStore) conn() *pgxpool.Pool {
+	return p.pool.Load()
+}
+
+// Ping checks that the current pool can reach the database.
+func (p *PostgresStore) Ping(ctx context.Context) error {
+	return p.conn().Ping(ctx)
+}
+
+func (p *PostgresStore) PoolStats() health.PoolStats {
+	stat := p.conn().Stat()
+	return health.PoolStats{
+		TotalConns:           stat.TotalConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		MaxConns:             stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+	}
+}
+
+// Close waits for the acquired connections to be released and closes the pool.
+func (p *PostgresStore) Close() {
+	p.conn().Close()
+}
+
+// ReloadPassword replaces the pool by one using the new password. The pool is
+// only replaced once the new password works, and the queries running on the
+// old pool finish before it is closed.
+func (p *PostgresStore) ReloadPassword(ctx context.Context, password config.Secret) error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	cfg := p.cfg
+	cfg.Password = password
+	pool, err := newPool(ctx, cfg, p.logger)
 	if err != nil {
-		return nil, fmt.Errorf("could not connect to database: %w", err)
+		return fmt.Errorf("could not reload password: %w", err)
+	}
+	old := p.pool.Swap(pool)
+	p.cfg = cfg
+
+	// Close blocks until the acquired connections are released
+	go old.Close()
+	return nil
+}
+
+// WatchPasswordFile rereads the password file at every interval and reloads
+// the pool when the password was rotated, until ctx is cancelled. It does
+// nothing if the password was not read from a file.
+func (p *PostgresStore) WatchPasswordFile(ctx context.Context) {
+	p.reloadMu.Lock()
+	file, interval := p.cfg.PasswordFile, p.cfg.SecretReloadInterval
+	p.reloadMu.Unlock()
+	if file == "" {
+		return
 	}
-	logger.Debug("pool configuration", slog.Any("config", fmt.Sprintf("%+v", conn.Config())))
 
-	err = conn.Ping(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.reloadIfRotated(ctx, file)
+		}
+	}
+}
+
+func (p *PostgresStore) reloadIfRotated(ctx context.Context, file string) {
+	password, err := config.ReadSecretFile(file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		// the file is briefly missing while kubernetes swaps the mount
+		p.logger.WarnContext(ctx, "could not read password file", slog.Any("error", err))
+		return
 	}
 
-	return &PostgresStore{
-		conn:   conn,
-		logger: logger,
-	}, nil
+	p.reloadMu.Lock()
+	rotated := password != p.cfg.Password
+	p.reloadMu.Unlock()
+	if !rotated {
+		return
+	}
+
+	if err := p.ReloadPassword(ctx, password); err != nil {
+		p.logger.ErrorContext(ctx, "could not reload rotated password, keeping the current pool", slog.Any("error", err))
+		return
+	}
+	p.logger.InfoContext(ctx, "reloaded rotated database password", slog.String("file", file))
 }
 
 func (p *PostgresStore) StoreIndividual(ctx context.Context, individual model.Individual) error {
@@ -69,24 +166,10 @@ func (p *PostgresStore) StoreIndividual(ctx context.Context, individual model.In
 	`
 
 	createdAt := time.Now()
-	result, err := p.conn.Exec(ctx, query, individual.Name, individual.Email, individual.Username, createdAt)
+	result, err := p.conn().Exec(ctx, query, individual.Name, individual.Email, individual.Username, createdAt)
 
 	if err != nil {
-		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
-		var pgerr *pgconn.PgError
-		if errors.As(err, &pgerr) {
-			p.logger.DebugContext(ctx, "got pgerr", slog.String("error", fmt.Sprintf("%#v", pgerr)))
-			if pgerr.Code != pgerrcode.UniqueViolation {
-				return storage.ErrUnknown
-			}
-			switch pgerr.ConstraintName {
-			case CONSTRAINT_UNIQUE_INDIVIDUAL_EMAIL:
-				return storage.ErrIndividualEmailAlreadyExists
-			case CONSTRAINT_UNIQUE_INDIVIDUAL_USERNAME:
-				return storage.ErrIndividualUsernameAlreadyExists
-			}
-		}
-		return storage.ErrUnknown
+		return p.mapInsertIndividualError(ctx, err)
 	}
 
 	rows := result.RowsAffected()
@@ -98,6 +181,24 @@ func (p *PostgresStore) StoreIndividual(ctx context.Context, individual model.In
 	return nil
 }
 
+func (p *PostgresStore) mapInsertIndividualError(ctx context.Context, err error) error {
+	p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+	var pgerr *pgconn.PgError
+	if errors.As(err, &pgerr) {
+		p.logger.DebugContext(ctx, "got pgerr", slog.String("error", fmt.Sprintf("%#v", pgerr)))
+		if pgerr.Code != pgerrcode.UniqueViolation {
+			return storage.ErrUnknown
+		}
+		switch pgerr.ConstraintName {
+		case CONSTRAINT_UNIQUE_INDIVIDUAL_EMAIL:
+			return storage.ErrIndividualEmailAlreadyExists
+		case CONSTRAINT_UNIQUE_INDIVIDUAL_USERNAME:
+			return storage.ErrIndividualUsernameAlreadyExists
+		}
+	}
+	return storage.ErrUnknown
+}
+
 func (p *PostgresStore) GetIndividual(ctx context.Context, individualUsername model.IndividualId) (model.Individual, error) {
 	query := `
 		SELECT username, email, name, created_at, updated_at, deleted_at
@@ -105,7 +206,7 @@ func (p *PostgresStore) GetIndividual(ctx context.Context, individualUsername mo
 		WHERE username=$1;
 	`
 
-	row := p.conn.QueryRow(ctx, query, individualUsername)
+	row := p.conn().QueryRow(ctx, query, individualUsername)
 	var username string
 	var name string
 	var email string
@@ -141,7 +242,7 @@ func (p *PostgresStore) MarkIndividualAsDeleted(ctx context.Context, individualU
 		WHERE username=$1;
 	`
 
-	row := p.conn.QueryRow(ctx, selectQuery, individualUsername)
+	row := p.conn().QueryRow(ctx, selectQuery, individualUsername)
 	var username string
 	var deleted_at sql.NullTime
 
@@ -154,7 +255,8 @@ func (p *PostgresStore) MarkIndividualAsDeleted(ctx context.Context, individualU
 		return storage.ErrUnknown
 	}
 
-	// once deleted_at was populated do not allow any changes to the record
+	// once deleted_at was populated do not allow any changes to the record,
+	// until the individual restores their account
 	if deleted_at.Valid {
 		return storage.ErrDeleted
 	}
@@ -166,7 +268,7 @@ func (p *PostgresStore) MarkIndividualAsDeleted(ctx context.Context, individualU
 	`
 
 	deletedAt := time.Now()
-	result, err := p.conn.Exec(ctx, deleteQuery, individualUsername, deletedAt)
+	result, err := p.conn().Exec(ctx, deleteQuery, individualUsername, deletedAt)
 	if err != nil {
 		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
 		return storage.ErrUnknown
@@ -181,6 +283,86 @@ func (p *PostgresStore) MarkIndividualAsDeleted(ctx context.Context, individualU
 	return nil
 }
 
+// anonymiseQuery wipes the personal data of the individuals selected by the
+// batch query, which must lock them. The usernames and emails are replaced by
+// random values to keep them unique, and to free the original ones. The name
+// is not nullable in GetIndividual so it is only emptied. The rows are kept
+// for the participations, but the hangouts lose their creator.
+//
+// $1 is the time of the anonymisation, the batch query starts at $2.
+func anonymiseQuery(batch string) string {
+	return `
+		WITH batch AS (` + batch + `
+		), wiped_credentials AS (
+			DELETE FROM credentials WHERE user_id IN (SELECT id FROM batch)
+		), wiped_sessions AS (
+			DELETE FROM sessions WHERE user_id IN (SELECT id FROM batch)
+		), wiped_magic_links AS (
+			DELETE FROM magic_links WHERE user_id IN (SELECT id FROM batch)
+		), orphaned_hangouts AS (
+			UPDATE hangouts SET created_by = NULL WHERE created_by IN (SELECT id FROM batch)
+		)
+		UPDATE individuals
+		SET name = '',
+			username = 'deleted-' || gen_random_uuid()::text,
+			email = gen_random_uuid()::text || '@deleted.invalid',
+			deleted_at = COALESCE(deleted_at, $1),
+			anonymised_at = $1,
+			updated_at = $1
+		WHERE id IN (SELECT id FROM batch);
+	`
+}
+
+func (p *PostgresStore) AnonymiseDeletedIndividuals(ctx context.Context, deletedBefore time.Time, limit int) (int64, error) {
+	// uses idx_individuals_deleted_at
+	query := anonymiseQuery(`
+			SELECT id
+			FROM individuals
+			WHERE deleted_at < $2 AND anonymised_at IS NULL
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED`)
+
+	result, err := p.conn().Exec(ctx, query, time.Now(), deletedBefore, limit)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return 0, storage.ErrUnknown
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// EraseIndividual does not wait for the restore period of soft-deleted
+// individuals to be over.
+func (p *PostgresStore) EraseIndividual(ctx context.Context, username model.IndividualId) error {
+	query := anonymiseQuery(`
+			SELECT id
+			FROM individuals
+			WHERE username = $2 AND anonymised_at IS NULL
+			FOR UPDATE`)
+
+	result, err := p.conn().Exec(ctx, query, time.Now(), username)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	if result.RowsAffected() == 1 {
+		return nil
+	}
+
+	// the username of anonymised individuals is random, so they can only be
+	// found by it if it was read after the anonymisation
+	row := p.conn().QueryRow(ctx, `SELECT 1 FROM individuals WHERE username = $1;`, username)
+	var exists int
+	if err := row.Scan(&exists); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving individual", slog.String("username", string(username)), slog.Any("error", err))
+		return storage.ErrUnknown
+	}
+	return storage.ErrDeleted
+}
+
 func (p *PostgresStore) StoreHangoutOfIndividuals(ctx context.Context, hangout model.Hangout) error {
 
 	queryHangoutIndividual := `
@@ -201,7 +383,7 @@ func (p *PostgresStore) StoreHangoutOfIndividuals(ctx context.Context, hangout m
 	`
 	currentTimestamp := time.Now()
 
-	tx, err := p.conn.Begin(ctx)
+	tx, err := p.conn().Begin(ctx)
 	if err != nil {
 		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
 		return storage.ErrUnknown
@@ -278,27 +460,8 @@ func (p *PostgresStore) StoreHangoutOfIndividuals(ctx context.Context, hangout m
 		p.logger.Debug("retrieved participant", slog.Int("id", int(participantId)), slog.Any("deleted_at", participantDeleted))
 
 		result, err := tx.Exec(ctx, queryInsertParticipant, hangoutId, participantId, currentTimestamp)
-		// depending on the isolation level, it might not be necessary to check those
 		if err != nil {
-			p.logger.ErrorContext(ctx, "failed to execute participant insertion query", slog.Any("error", err))
-			var pgerr *pgconn.PgError
-			if errors.As(err, &pgerr) {
-				p.logger.DebugContext(ctx, "got pgerr", slog.String("error", fmt.Sprintf("%#v", pgerr)))
-				if pgerr.Code != pgerrcode.ForeignKeyViolation {
-					return storage.ErrUnknown
-				}
-				// these may be redundant depending on isolation level, but
-				// in theory should never happen
-				switch pgerr.ConstraintName {
-				case CONSTRAINT_FOREIGN_KEY_HANGOUT:
-					p.logger.WarnContext(ctx, "foreign key constraint violation in hangout_individuals table", slog.String("constraint", CONSTRAINT_FOREIGN_KEY_HANGOUT))
-					return storage.ErrParticipantHangoutNotFound
-				case CONSTRAINT_FOREIGN_KEY_INDIVIDUAL:
-					p.logger.WarnContext(ctx, "foreign key constraint violation in hangout_individuals table", slog.String("constraint", CONSTRAINT_FOREIGN_KEY_INDIVIDUAL))
-					return storage.ErrParticipantIndividualNotFound
-				}
-			}
-			return storage.ErrUnknown
+			return p.mapInsertParticipantError(ctx, err)
 		}
 
 		rows := result.RowsAffected()
@@ -321,7 +484,7 @@ func (p *PostgresStore) StoreHangoutOfIndividuals(ctx context.Context, hangout m
 func (p *PostgresStore) StoreSession(ctx context.Context, sesh session.Session) error {
 
 	// use snapshot isolation level here I think
-	tx, err := p.conn.BeginTx(ctx, pgx.TxOptions{
+	tx, err := p.conn().BeginTx(ctx, pgx.TxOptions{
 		IsoLevel: pgx.Serializable,
 	})
 	if err != nil {
@@ -361,7 +524,7 @@ func (p *PostgresStore) StoreSession(ctx context.Context, sesh session.Session)
 		VALUES ($1, $2, $3, $4);
 	`
 
-	result, err := p.conn.Exec(ctx, querySession, sesh.CookieValue, userId, sesh.LastAccessed, sesh.CreatedAt)
+	result, err := p.conn().Exec(ctx, querySession, sesh.CookieValue, userId, sesh.LastAccessed, sesh.CreatedAt)
 	if err != nil {
 		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
 		var pgerr *pgconn.PgError
@@ -396,3 +559,268 @@ func (p *PostgresStore) StoreSession(ctx context.Context, sesh session.Session)
 	p.logger.Info("created session for user", slog.String("username", string(sesh.UserID)))
 	return nil
 }
+
+func (p *PostgresStore) GetSession(ctx context.Context, cookie string) (session.Session, error) {
+	query := `
+		SELECT s.last_accessed, s.created_at, i.username, i.deleted_at
+		FROM sessions s
+		JOIN individuals i ON i.id = s.user_id
+		WHERE s.cookie = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, query, cookie)
+	var lastAccessed, createdAt time.Time
+	var username string
+	var userDeleted sql.NullTime
+
+	err := row.Scan(&lastAccessed, &createdAt, &username, &userDeleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		p.logger.DebugContext(ctx, "session not found")
+		return session.Session{}, session.ErrNotFound
+	} else if err != nil {
+		p.logger.ErrorContext(ctx, "unknown error when retrieving session", slog.Any("error", err))
+		return session.Session{}, session.ErrUnknown
+	}
+
+	// sessions are not removed when the user is soft deleted, so they have to
+	// be refused here
+	if userDeleted.Valid {
+		p.logger.WarnContext(ctx, "found session of deleted user", slog.String("username", username))
+		return session.Session{}, session.ErrUserDeleted
+	}
+
+	// the cookie column is fixed length and comes back padded, so return the
+	// value that was looked up instead
+	return session.Session{
+		CreatedAt:    createdAt,
+		LastAccessed: lastAccessed,
+		CookieValue:  cookie,
+		UserID:       model.IndividualId(username),
+	}, nil
+}
+
+func (p *PostgresStore) UpdateLastAccessed(ctx context.Context, cookie string, lastAccessed time.Time) error {
+	selectQuery := `
+		SELECT i.deleted_at
+		FROM sessions s
+		JOIN individuals i ON i.id = s.user_id
+		WHERE s.cookie = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, selectQuery, cookie)
+	var userDeleted sql.NullTime
+
+	err := row.Scan(&userDeleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		p.logger.DebugContext(ctx, "session not found")
+		return session.ErrNotFound
+	} else if err != nil {
+		p.logger.ErrorContext(ctx, "unknown error when retrieving session", slog.Any("error", err))
+		return session.ErrUnknown
+	}
+	if userDeleted.Valid {
+		return session.ErrUserDeleted
+	}
+
+	updateQuery := `
+		UPDATE sessions
+		SET last_accessed = $2
+		WHERE cookie = $1;
+	`
+
+	result, err := p.conn().Exec(ctx, updateQuery, cookie, lastAccessed)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return session.ErrUnknown
+	}
+
+	// the session may have been removed between the two queries
+	rows := result.RowsAffected()
+	if rows == 0 {
+		return session.ErrNotFound
+	}
+	if rows != 1 {
+		p.logger.ErrorContext(ctx, "expected one row to be affected", slog.Int64("rows_affected", rows))
+		return session.ErrUnknown
+	}
+
+	return nil
+}
+
+func (p *PostgresStore) DeleteSession(ctx context.Context, cookie string) error {
+	query := `
+		DELETE FROM sessions
+		WHERE cookie = $1;
+	`
+
+	result, err := p.conn().Exec(ctx, query, cookie)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return session.ErrUnknown
+	}
+
+	rows := result.RowsAffected()
+	if rows == 0 {
+		return session.ErrNotFound
+	}
+	if rows != 1 {
+		p.logger.ErrorContext(ctx, "expected one row to be affected", slog.Int64("rows_affected", rows))
+		return session.ErrUnknown
+	}
+
+	return nil
+}
+
+func (p *PostgresStore) DeleteSessionsOfUser(ctx context.Context, userId model.IndividualId) (int64, error) {
+	queryIndividual := `
+		SELECT id
+		FROM individuals
+		WHERE username = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, queryIndividual, userId)
+	var id int
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, session.ErrUserNotFound
+		}
+		p.logger.ErrorContext(ctx, "could not retrieve user id", slog.Any("error", err))
+		return 0, session.ErrUnknown
+	}
+
+	// uses idx_sessions_user_id
+	query := `
+		DELETE FROM sessions
+		WHERE user_id = $1;
+	`
+
+	result, err := p.conn().Exec(ctx, query, id)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return 0, session.ErrUnknown
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (p *PostgresStore) DeleteOtherSessionsOfUser(ctx context.Context, userId model.IndividualId, keepCookie string) (int64, error) {
+	queryIndividual := `
+		SELECT id
+		FROM individuals
+		WHERE username = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, queryIndividual, userId)
+	var id int
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, session.ErrUserNotFound
+		}
+		p.logger.ErrorContext(ctx, "could not retrieve user id", slog.Any("error", err))
+		return 0, session.ErrUnknown
+	}
+
+	// uses idx_sessions_user_id
+	query := `
+		DELETE FROM sessions
+		WHERE user_id = $1 AND cookie <> $2;
+	`
+
+	result, err := p.conn().Exec(ctx, query, id, keepCookie)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return 0, session.ErrUnknown
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (p *PostgresStore) RotateSession(ctx context.Context, oldCookie string, newSession session.Session) error {
+	tx, err := p.conn().Begin(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to start a transaction", slog.Any("error", err))
+		return session.ErrUnknown
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			p.logger.ErrorContext(ctx, "failed to rollback transaction", slog.Any("error", err))
+		}
+	}()
+
+	deleteQuery := `
+		DELETE FROM sessions
+		WHERE cookie = $1
+		RETURNING user_id;
+	`
+
+	row := tx.QueryRow(ctx, deleteQuery, oldCookie)
+	var userId int
+	if err := row.Scan(&userId); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return session.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "could not delete old session", slog.Any("error", err))
+		return session.ErrUnknown
+	}
+
+	insertQuery := `
+		INSERT INTO sessions (cookie, user_id, last_accessed, created_at)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	_, err = tx.Exec(ctx, insertQuery, newSession.CookieValue, userId, newSession.LastAccessed, newSession.CreatedAt)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		var pgerr *pgconn.PgError
+		if errors.As(err, &pgerr) && pgerr.Code == pgerrcode.StringDataRightTruncationDataException {
+			return session.ErrCookieInvalidLength
+		}
+		return session.ErrUnknown
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "could not commit transaction", slog.Any("error", err))
+		return session.ErrUnknown
+	}
+
+	return nil
+}
+
+func (p *PostgresStore) DeleteExpiredSessions(ctx context.Context, idleBefore, createdBefore time.Time, limit int) (int64, error) {
+	// postgres has no DELETE ... LIMIT, so select the batch first. Uses
+	// idx_sessions_last_accessed for the idle sessions.
+	query := `
+		DELETE FROM sessions
+		WHERE cookie IN (
+			SELECT cookie
+			FROM sessions
+			WHERE last_accessed < $1 OR created_at < $2
+			LIMIT $3
+		);
+	`
+
+	result, err := p.conn().Exec(ctx, query, idleBefore, createdBefore, limit)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return 0, session.ErrUnknown
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (p *PostgresStore) CountActiveSessions(ctx context.Context, idleAfter, createdAfter time.Time) (int64, error) {
+	query := `
+		SELECT count(*)
+		FROM sessions
+		WHERE last_accessed >= $1 AND created_at >= $2;
+	`
+
+	var count int64
+	if err := p.conn().QueryRow(ctx, query, idleAfter, createdAfter).Scan(&count); err != nil {
+		p.logger.ErrorContext(ctx, "failed to execute query", slog.Any("error", err))
+		return 0, session.ErrUnknown
+	}
+	return count, nil
+}
@@ -32,6 +32,23 @@ func TestPostgresStoreTestSuite(t *testing.T) {
 	}
 }
 
+func newTestPostgresStore(logger *slog.Logger) (*PostgresStore, error) {
+	return NewPostgresStore(context.TODO(), config.PostgresConfig{
+		User:     "test",
+		DbName:   "test",
+		Password: config.NewSecret("test"),
+		Host:     "localhost",
+		Port:     5433,
+	}, logger)
+}
+
+// truncateTables removes all rows, the tables referencing individuals are
+// cleared by the cascades.
+func truncateTables(pg *PostgresStore) error {
+	_, err := pg.conn().Exec(context.Background(), "DELETE FROM hangout_individuals WHERE 1=1; DELETE FROM hangouts WHERE 1=1; DELETE FROM individuals WHERE 1=1;")
+	return err
+}
+
 func (suite *PostgresStoreTestSuite) SetupSuite() {
 	logopts := &slog.HandlerOptions{
 		Level: slog.LevelDebug,
@@ -40,13 +57,7 @@ func (suite *PostgresStoreTestSuite) SetupSuite() {
 	logger := slog.New(handler)
 
 	suite.logger = logger
-	pg, err := NewPostgresStore(context.TODO(), config.PostgresConfig{
-		User:     "test",
-		DbName:   "test",
-		Password: "test",
-		Host:     "localhost",
-		Port:     5433,
-	}, logger)
+	pg, err := newTestPostgresStore(logger)
 	if err != nil {
 		suite.FailNow("could not start suite", err.Error())
 	}
@@ -54,7 +65,7 @@ func (suite *PostgresStoreTestSuite) SetupSuite() {
 }
 
 func (suite *PostgresStoreTestSuite) SetupTest() {
-	_, err := suite.pgStore.conn.Exec(context.Background(), "DELETE FROM hangout_individuals WHERE 1=1; DELETE FROM hangouts WHERE 1=1; DELETE FROM individuals WHERE 1=1;")
+	err := truncateTables(suite.pgStore)
 	if err != nil {
 		suite.FailNow("could not truncate tables", err.Error())
 	}
@@ -431,3 +442,206 @@ func (suite *PostgresStoreTestSuite) TestStoreSession_ReturnsError_WhenUserIsDel
 	err = suite.pgStore.StoreSession(suite.T().Context(), sesh)
 	suite.Require().ErrorIs(err, session.ErrUserDeleted, "expected error when storing a session")
 }
+
+func (suite *PostgresStoreTestSuite) TestGetSession_ReturnsStoredSession_WhenSessionExists() {
+	username := suite.addUserForSession()
+	sesh, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	err = suite.pgStore.StoreSession(suite.T().Context(), sesh)
+	suite.Require().NoError(err, "expected no error when storing a session")
+
+	got, err := suite.pgStore.GetSession(suite.T().Context(), sesh.CookieValue)
+	suite.Require().NoError(err, "expected no error when retrieving a session")
+
+	assert.Equal(suite.T(), sesh.CookieValue, got.CookieValue, "expected cookie to match")
+	assert.Equal(suite.T(), username, got.UserID, "expected session user to match")
+	assert.WithinDuration(suite.T(), sesh.CreatedAt, got.CreatedAt, time.Millisecond, "expected creation time to match")
+	assert.WithinDuration(suite.T(), sesh.LastAccessed, got.LastAccessed, time.Millisecond, "expected last access time to match")
+}
+
+func (suite *PostgresStoreTestSuite) TestGetSession_ReturnsError_WhenSessionDoesntExist() {
+	_, err := suite.pgStore.GetSession(suite.T().Context(), "unknown")
+	suite.Require().ErrorIs(err, session.ErrNotFound, "expected error when session is not in the database")
+}
+
+func (suite *PostgresStoreTestSuite) TestGetSession_ReturnsError_WhenUserIsDeleted() {
+	username := suite.addUserForSession()
+	sesh, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	err = suite.pgStore.StoreSession(suite.T().Context(), sesh)
+	suite.Require().NoError(err, "expected no error when storing a session")
+
+	err = suite.pgStore.MarkIndividualAsDeleted(suite.T().Context(), username)
+	suite.Require().NoError(err, "expected no error when marking user as deleted")
+
+	_, err = suite.pgStore.GetSession(suite.T().Context(), sesh.CookieValue)
+	suite.Require().ErrorIs(err, session.ErrUserDeleted, "expected error when retrieving the session of a deleted user")
+}
+
+func (suite *PostgresStoreTestSuite) TestUpdateLastAccessed_UpdatesTheSession_WhenSessionExists() {
+	username := suite.addUserForSession()
+	sesh, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	err = suite.pgStore.StoreSession(suite.T().Context(), sesh)
+	suite.Require().NoError(err, "expected no error when storing a session")
+
+	lastAccessed := sesh.LastAccessed.Add(time.Hour)
+	err = suite.pgStore.UpdateLastAccessed(suite.T().Context(), sesh.CookieValue, lastAccessed)
+	suite.Require().NoError(err, "expected no error when updating last access time")
+
+	got, err := suite.pgStore.GetSession(suite.T().Context(), sesh.CookieValue)
+	suite.Require().NoError(err, "expected no error when retrieving a session")
+	assert.WithinDuration(suite.T(), lastAccessed, got.LastAccessed, time.Millisecond, "expected last access time to be updated")
+}
+
+func (suite *PostgresStoreTestSuite) TestUpdateLastAccessed_ReturnsError_WhenSessionDoesntExist() {
+	err := suite.pgStore.UpdateLastAccessed(suite.T().Context(), "unknown", time.Now())
+	suite.Require().ErrorIs(err, session.ErrNotFound, "expected error when session is not in the database")
+}
+
+func (suite *PostgresStoreTestSuite) TestUpdateLastAccessed_ReturnsError_WhenUserIsDeleted() {
+	username := suite.addUserForSession()
+	sesh, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	err = suite.pgStore.StoreSession(suite.T().Context(), sesh)
+	suite.Require().NoError(err, "expected no error when storing a session")
+
+	err = suite.pgStore.MarkIndividualAsDeleted(suite.T().Context(), username)
+	suite.Require().NoError(err, "expected no error when marking user as deleted")
+
+	err = suite.pgStore.UpdateLastAccessed(suite.T().Context(), sesh.CookieValue, time.Now())
+	suite.Require().ErrorIs(err, session.ErrUserDeleted, "expected error when updating the session of a deleted user")
+}
+
+func (suite *PostgresStoreTestSuite) storeSessionForUser(username model.IndividualId) session.Session {
+	suite.T().Helper()
+	sesh, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	err = suite.pgStore.StoreSession(suite.T().Context(), sesh)
+	suite.Require().NoError(err, "expected no error when storing a session")
+	return sesh
+}
+
+func (suite *PostgresStoreTestSuite) TestDeleteSession_RemovesTheSession() {
+	username := suite.addUserForSession()
+	sesh := suite.storeSessionForUser(username)
+
+	err := suite.pgStore.DeleteSession(suite.T().Context(), sesh.CookieValue)
+	suite.Require().NoError(err, "expected no error when deleting a session")
+
+	_, err = suite.pgStore.GetSession(suite.T().Context(), sesh.CookieValue)
+	suite.Require().ErrorIs(err, session.ErrNotFound, "expected session to be gone")
+}
+
+func (suite *PostgresStoreTestSuite) TestDeleteSession_ReturnsError_WhenSessionDoesntExist() {
+	err := suite.pgStore.DeleteSession(suite.T().Context(), "unknown")
+	suite.Require().ErrorIs(err, session.ErrNotFound, "expected error when deleting an unknown session")
+}
+
+func (suite *PostgresStoreTestSuite) TestDeleteSessionsOfUser_RemovesAllSessionsOfTheUser() {
+	username := suite.addUserForSession()
+	sessions := []session.Session{
+		suite.storeSessionForUser(username),
+		suite.storeSessionForUser(username),
+		suite.storeSessionForUser(username),
+	}
+
+	removed, err := suite.pgStore.DeleteSessionsOfUser(suite.T().Context(), username)
+	suite.Require().NoError(err, "expected no error when deleting the sessions of a user")
+	assert.Equal(suite.T(), int64(3), removed, "expected all sessions to be removed")
+
+	for _, s := range sessions {
+		_, err = suite.pgStore.GetSession(suite.T().Context(), s.CookieValue)
+		suite.Require().ErrorIs(err, session.ErrNotFound, "expected session to be gone")
+	}
+}
+
+func (suite *PostgresStoreTestSuite) TestDeleteSessionsOfUser_ReturnsError_WhenUserDoesntExist() {
+	_, err := suite.pgStore.DeleteSessionsOfUser(suite.T().Context(), "unknown")
+	suite.Require().ErrorIs(err, session.ErrUserNotFound, "expected error when user is not in the database")
+}
+
+func (suite *PostgresStoreTestSuite) TestRotateSession_ReplacesTheOldSession() {
+	username := suite.addUserForSession()
+	old := suite.storeSessionForUser(username)
+
+	rotated, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	rotated.CreatedAt = old.CreatedAt
+
+	err = suite.pgStore.RotateSession(suite.T().Context(), old.CookieValue, rotated)
+	suite.Require().NoError(err, "expected no error when rotating a session")
+
+	_, err = suite.pgStore.GetSession(suite.T().Context(), old.CookieValue)
+	suite.Require().ErrorIs(err, session.ErrNotFound, "expected old session to be gone")
+
+	got, err := suite.pgStore.GetSession(suite.T().Context(), rotated.CookieValue)
+	suite.Require().NoError(err, "expected rotated session to exist")
+	assert.Equal(suite.T(), username, got.UserID, "expected rotated session to belong to the same user")
+	assert.WithinDuration(suite.T(), old.CreatedAt, got.CreatedAt, time.Millisecond, "expected creation time to be kept")
+}
+
+func (suite *PostgresStoreTestSuite) TestRotateSession_ReturnsError_WhenOldSessionDoesntExist() {
+	username := suite.addUserForSession()
+	rotated, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+
+	err = suite.pgStore.RotateSession(suite.T().Context(), "unknown", rotated)
+	suite.Require().ErrorIs(err, session.ErrNotFound, "expected error when rotating an unknown session")
+}
+
+func (suite *PostgresStoreTestSuite) TestDeleteExpiredSessions_RemovesOnlyExpiredSessions() {
+	username := suite.addUserForSession()
+	now := time.Now()
+
+	idle, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	idle.LastAccessed = now.Add(-time.Hour)
+	suite.Require().NoError(suite.pgStore.StoreSession(suite.T().Context(), idle), "expected no error when storing a session")
+
+	old, err := session.NewSessionForUser(username)
+	suite.Require().NoError(err, "expected no error when defining session")
+	old.CreatedAt = now.Add(-48 * time.Hour)
+	suite.Require().NoError(suite.pgStore.StoreSession(suite.T().Context(), old), "expected no error when storing a session")
+
+	fresh := suite.storeSessionForUser(username)
+
+	removed, err := suite.pgStore.DeleteExpiredSessions(suite.T().Context(), now.Add(-30*time.Minute), now.Add(-24*time.Hour), 10)
+	suite.Require().NoError(err, "expected no error when deleting expired sessions")
+	assert.Equal(suite.T(), int64(2), removed, "expected the idle and the old session to be removed")
+
+	_, err = suite.pgStore.GetSession(suite.T().Context(), fresh.CookieValue)
+	suite.Require().NoError(err, "expected fresh session to be kept")
+}
+
+func (suite *PostgresStoreTestSuite) TestDeleteExpiredSessions_RemovesAtMostLimitSessions() {
+	username := suite.addUserForSession()
+	now := time.Now()
+	for range 3 {
+		sesh, err := session.NewSessionForUser(username)
+		suite.Require().NoError(err, "expected no error when defining session")
+		sesh.LastAccessed = now.Add(-time.Hour)
+		suite.Require().NoError(suite.pgStore.StoreSession(suite.T().Context(), sesh), "expected no error when storing a session")
+	}
+
+	removed, err := suite.pgStore.DeleteExpiredSessions(suite.T().Context(), now.Add(-30*time.Minute), now.Add(-24*time.Hour), 2)
+	suite.Require().NoError(err, "expected no error when deleting expired sessions")
+	assert.Equal(suite.T(), int64(2), removed, "expected the batch to be limited")
+}
+
+func (suite *PostgresStoreTestSuite) TestReloadPassword_ReplacesPool_IfPasswordWorks() {
+	before := suite.pgStore.conn()
+	err := suite.pgStore.ReloadPassword(suite.T().Context(), config.NewSecret("test"))
+	suite.Require().NoError(err, "expected no error when reloading a valid password")
+	assert.NotSame(suite.T(), before, suite.pgStore.conn(), "expected the pool to be replaced")
+
+	_, err = suite.pgStore.GetIndividual(suite.T().Context(), "username")
+	assert.ErrorIs(suite.T(), err, storage.ErrNotFound, "expected the new pool to serve queries")
+}
+
+func (suite *PostgresStoreTestSuite) TestReloadPassword_KeepsPool_IfPasswordIsWrong() {
+	before := suite.pgStore.conn()
+	err := suite.pgStore.ReloadPassword(suite.T().Context(), config.NewSecret("wrong"))
+	assert.Error(suite.T(), err, "expected an error when reloading a wrong password")
+	assert.Same(suite.T(), before, suite.pgStore.conn(), "expected the current pool to be kept")
+}
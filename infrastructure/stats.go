@@ -0,0 +1,198 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ stats.Storage = (*PostgresStore)(nil)
+
+// activeIndividualId returns the internal id of an individual that did not
+// delete their account.
+func (p *PostgresStore) activeIndividualId(ctx context.Context, username model.IndividualId) (int, error) {
+	queryIndividual := `
+		SELECT id, deleted_at
+		FROM individuals
+		WHERE username = $1;
+	`
+
+	row := p.conn().QueryRow(ctx, queryIndividual, username)
+	var id int
+	var deletedAt sql.NullTime
+	if err := row.Scan(&id, &deletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, storage.ErrNotFound
+		}
+		p.logger.ErrorContext(ctx, "unknown error when retrieving individual", slog.String("username", string(username)), slog.Any("error", err))
+		return 0, storage.ErrUnknown
+	}
+	if deletedAt.Valid {
+		return 0, storage.ErrDeleted
+	}
+	return id, nil
+}
+
+func (p *PostgresStore) PairStatsForIndividual(ctx context.Context, username model.IndividualId, from, to time.Time) ([]stats.PairStats, error) {
+	id, err := p.activeIndividualId(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var since any
+	if !from.IsZero() {
+		since = from
+	}
+
+	// the pairs are deduplicated first, an individual listed twice in the
+	// same hangout must not count it twice
+	query := `
+		SELECT i.username, i.deleted_at IS NOT NULL, COUNT(*), SUM(pairs.duration_minutes)
+		FROM (
+			SELECT DISTINCT h.id, h.duration_minutes, other.individual_id
+			FROM hangout_individuals me
+			JOIN hangouts h ON h.id = me.hangout_id
+			JOIN hangout_individuals other ON other.hangout_id = h.id
+			WHERE me.individual_id = $1
+			  AND me.deleted_at IS NULL
+			  AND other.deleted_at IS NULL
+			  AND other.individual_id <> me.individual_id
+			  AND h.deleted_at IS NULL
+			  AND ($2::timestamptz IS NULL OR h.date >= $2)
+			  AND h.date < $3
+		) pairs
+		JOIN individuals i ON i.id = pairs.individual_id
+		GROUP BY i.username, i.deleted_at
+		ORDER BY COUNT(*) DESC, SUM(pairs.duration_minutes) DESC, i.username COLLATE "C";
+	`
+
+	rows, err := p.conn().Query(ctx, query, id, since, to)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to compute pair statistics", slog.Any("error", err))
+		return nil, storage.ErrUnknown
+	}
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (stats.PairStats, error) {
+		var s stats.PairStats
+		var with string
+		var minutes int64
+		err := row.Scan(&with, &s.Deleted, &s.Hangouts, &minutes)
+		s.With = model.IndividualId(with)
+		s.Minutes = model.Minutes(minutes)
+		return s, err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to compute pair statistics", slog.Any("error", err))
+		return nil, storage.ErrUnknown
+	}
+
+	return result, nil
+}
+
+func (p *PostgresStore) PairHangoutDates(ctx context.Context, username model.IndividualId, before time.Time) ([]stats.PairDates, error) {
+	id, err := p.activeIndividualId(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT DISTINCT i.username COLLATE "C", i.deleted_at IS NOT NULL, h.id, h.date
+		FROM hangout_individuals me
+		JOIN hangouts h ON h.id = me.hangout_id
+		JOIN hangout_individuals other ON other.hangout_id = h.id
+		JOIN individuals i ON i.id = other.individual_id
+		WHERE me.individual_id = $1
+		  AND me.deleted_at IS NULL
+		  AND other.deleted_at IS NULL
+		  AND other.individual_id <> me.individual_id
+		  AND h.deleted_at IS NULL
+		  AND h.date < $2
+		ORDER BY i.username COLLATE "C", h.date, h.id;
+	`
+
+	rows, err := p.conn().Query(ctx, query, id, before)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to retrieve hangout dates", slog.Any("error", err))
+		return nil, storage.ErrUnknown
+	}
+	defer rows.Close()
+
+	var result []stats.PairDates
+	for rows.Next() {
+		var with string
+		var deleted bool
+		var hangoutId int64
+		var date time.Time
+		if err := rows.Scan(&with, &deleted, &hangoutId, &date); err != nil {
+			p.logger.ErrorContext(ctx, "failed to retrieve hangout dates", slog.Any("error", err))
+			return nil, storage.ErrUnknown
+		}
+		if len(result) == 0 || result[len(result)-1].With != model.IndividualId(with) {
+			result = append(result, stats.PairDates{
+				With:    model.IndividualId(with),
+				Deleted: deleted,
+			})
+		}
+		last := &result[len(result)-1]
+		last.Dates = append(last.Dates, date)
+	}
+	if err := rows.Err(); err != nil {
+		p.logger.ErrorContext(ctx, "failed to retrieve hangout dates", slog.Any("error", err))
+		return nil, storage.ErrUnknown
+	}
+
+	return result, nil
+}
+
+func (p *PostgresStore) IndividualsNotSeenSince(ctx context.Context, username model.IndividualId, since, before time.Time) ([]stats.Reminder, error) {
+	id, err := p.activeIndividualId(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT i.username, MAX(pairs.date), COUNT(*)
+		FROM (
+			SELECT DISTINCT h.id, h.date, other.individual_id
+			FROM hangout_individuals me
+			JOIN hangouts h ON h.id = me.hangout_id
+			JOIN hangout_individuals other ON other.hangout_id = h.id
+			WHERE me.individual_id = $1
+			  AND me.deleted_at IS NULL
+			  AND other.deleted_at IS NULL
+			  AND other.individual_id <> me.individual_id
+			  AND h.deleted_at IS NULL
+			  AND h.date < $3
+		) pairs
+		JOIN individuals i ON i.id = pairs.individual_id
+		WHERE i.deleted_at IS NULL
+		GROUP BY i.username
+		HAVING MAX(pairs.date) < $2
+		ORDER BY COUNT(*) DESC, MAX(pairs.date), i.username COLLATE "C";
+	`
+
+	rows, err := p.conn().Query(ctx, query, id, since, before)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to compute reminders", slog.Any("error", err))
+		return nil, storage.ErrUnknown
+	}
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (stats.Reminder, error) {
+		var r stats.Reminder
+		var with string
+		err := row.Scan(&with, &r.LastSeen, &r.Hangouts)
+		r.With = model.IndividualId(with)
+		return r, err
+	})
+	if err != nil {
+		p.logger.ErrorContext(ctx, "failed to compute reminders", slog.Any("error", err))
+		return nil, storage.ErrUnknown
+	}
+
+	return result, nil
+}
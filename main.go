@@ -2,50 +2,169 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/Ozoniuss/hangcounts/config"
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/retention"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
 	"github.com/Ozoniuss/hangcounts/infrastructure"
+	"github.com/Ozoniuss/hangcounts/metrics"
+	"github.com/Ozoniuss/hangcounts/migrations"
+	"github.com/Ozoniuss/hangcounts/web/api"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/health"
+	"github.com/Ozoniuss/hangcounts/web/mail"
+	"github.com/Ozoniuss/hangcounts/web/session"
 )
 
 func run() error {
-	config, err := config.NewAppConfig()
+	config, err := config.NewAppConfig(os.Args[1:])
 	if err != nil {
 		return fmt.Errorf("could not read config: %w", err)
 	}
-
-	logopts := &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}
-	if config.Env == "dev" {
-		logopts.Level = slog.LevelDebug
+	if config.PrintConfig {
+		return config.WriteSettings(os.Stdout)
 	}
-	handler := slog.NewJSONHandler(os.Stdout, logopts)
-	logger := slog.New(handler)
+
+	logger := config.Logging.NewLogger(os.Stdout)
 
 	logger.Info("read application mode", slog.String("env", config.Env))
 	if config.Database.ShowConfig {
 		logger.Debug("database config", slog.Any("config", config.Database))
 	}
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	pgStore, err := infrastructure.NewPostgresStore(ctx, config.Database, logger)
 	if err != nil {
 		return fmt.Errorf("could not create a postgres store: %w", err)
 	}
+	defer pgStore.Close()
 	logger.Info("connected to postgres database", slog.String("host", config.Database.Host), slog.Int("port", config.Database.Port))
 
+	// also used by the readiness probe to compare the versions
+	migrator, err := infrastructure.NewMigrator(pgStore, migrations.FS)
+	if err != nil {
+		return fmt.Errorf("could not load migrations: %w", err)
+	}
+	if config.Database.MigrateOnStartup {
+		if err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("could not migrate database: %w", err)
+		}
+	}
+
 	logger.Info("starting app")
 
-	fmt.Println(config, pgStore)
+	prom := metrics.NewPrometheus(logger)
+	// the services use the instrumented store, to measure every storage call
+	store := infrastructure.NewInstrumentedStore(pgStore, prom)
+
+	sessions := session.NewSessionManager(store, config.Session.IdleExpiration, config.Session.AbsoluteExpiration, session.SESSION_COOKIE_NAME, logger)
+	hasher, err := auth.NewHasher(config.Password)
+	if err != nil {
+		return fmt.Errorf("could not create password hasher: %w", err)
+	}
+	authService, err := auth.NewService(store, hasher, sessions, config.Retention.DeletedIndividuals, logger)
+	if err != nil {
+		return fmt.Errorf("could not create auth service: %w", err)
+	}
+	var magicLinks *auth.MagicLinkService
+	if config.MagicLink.Enabled() {
+		mailer := mail.NewSMTPMailer(config.MagicLink.SmtpHost, config.MagicLink.SmtpPort, config.MagicLink.SmtpUsername, config.MagicLink.SmtpPassword.Reveal(), config.MagicLink.From)
+		magicLinks, err = auth.NewMagicLinkService(store, mailer, sessions, config.MagicLink.TTL, config.MagicLink.URL, logger)
+		if err != nil {
+			return fmt.Errorf("could not create magic link service: %w", err)
+		}
+		// the links requested right before the shutdown are still sent
+		defer magicLinks.Wait()
+	} else {
+		logger.Info("magic links are disabled, no smtp host configured")
+	}
+	server := api.NewServer(store, sessions, authService, magicLinks, stats.NewService(store), export.NewService(store), logger)
+
+	checker := health.NewChecker(pgStore, migrator, logger)
+	// readiness fails as soon as the shutdown starts
+	context.AfterFunc(ctx, checker.MarkShuttingDown)
+
+	prom.RegisterPool(pgStore.PoolStats)
+	prom.RegisterActiveSessions(sessions.CountActiveSessions)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", server)
+	adminMux := http.NewServeMux()
+	checker.Register(adminMux)
+	adminMux.Handle("GET /metrics", prom.Handler())
+
+	// background workers must be done before the pool is closed
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		checker.RunWorker("session_reaper", func() {
+			sessions.ReapExpiredSessions(ctx, config.Session.ReapInterval, session.REAPER_BATCH_SIZE)
+		})
+	}()
+	purger := retention.NewPurger(store, config.Retention.DeletedHangouts, config.Retention.DeletedIndividuals, logger)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		checker.RunWorker("retention_purger", func() {
+			purger.Run(ctx, config.Retention.PurgeInterval, retention.PURGE_BATCH_SIZE)
+		})
+	}()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		pgStore.WatchPasswordFile(ctx)
+	}()
+
+	// the probes and the metrics are served until the app is drained
+	adminErr := make(chan error, 1)
+	go func() {
+		err := api.Serve(ctx, config.Server.AdminAddress, adminMux, config.Server.ShutdownDelay, logger)
+		if err != nil {
+			stop()
+		}
+		adminErr <- err
+	}()
+
+	err = api.Serve(ctx, config.Server.Address, metrics.InstrumentHandler(prom, mux), config.Server.ShutdownDelay, logger)
+	// make sure the admin server and the workers stop as well
+	stop()
+	if err := errors.Join(err, <-adminErr); err != nil {
+		return fmt.Errorf("could not serve http: %w", err)
+	}
+
+	logger.Info("app stopped")
 	return nil
 }
 
 func main() {
+	// admin commands share the configuration of the app
+	if ok, err := runAdminCommand(os.Args[1:]); ok {
+		if err != nil {
+			slog.Error("admin command failed", slog.String("command", os.Args[1]), slog.Any("error", err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
+		// the usage was already printed by -h
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
 		slog.Error("could not start app", slog.Any("error", err))
 		os.Exit(1)
 	}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+)
+
+// statusRecorder keeps the status written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// InstrumentHandler records the duration and status of the requests served by
+// mux. The route is the pattern matched by mux rather than the path, so that
+// ids do not end up in the labels.
+func InstrumentHandler(recorder Recorder, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		mux.ServeHTTP(rec, r)
+
+		// set by the mux on the request once matched
+		route := r.Pattern
+		if route == "" {
+			route = ROUTE_UNMATCHED
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		recorder.ObserveRequest(route, status, time.Since(start))
+	})
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/web/health"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	NAMESPACE = "hangcounts"
+
+	// bounds the queries run while scraping, a slow database should not make
+	// the scrape time out
+	COLLECT_TIMEOUT = time.Second
+
+	// the route of the requests not matching any pattern, to keep the
+	// cardinality bounded
+	ROUTE_UNMATCHED = "unmatched"
+)
+
+// Recorder receives the measurements of the requests and the storage calls.
+// Prometheus exports them in production, tests can record them instead.
+type Recorder interface {
+	ObserveRequest(route string, status int, duration time.Duration)
+	// ObserveStorageCall is given an empty errorLabel if the call succeeded.
+	ObserveStorageCall(method string, errorLabel string, duration time.Duration)
+}
+
+// Nop discards every measurement.
+type Nop struct{}
+
+func (Nop) ObserveRequest(string, int, time.Duration)        {}
+func (Nop) ObserveStorageCall(string, string, time.Duration) {}
+
+var _ Recorder = Nop{}
+var _ Recorder = (*Prometheus)(nil)
+
+// Prometheus exports the metrics of the app on its own registry, to not
+// depend on the global one.
+type Prometheus struct {
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+	storageDuration *prometheus.HistogramVec
+	storageErrors   *prometheus.CounterVec
+	logger          *slog.Logger
+}
+
+func NewPrometheus(logger *slog.Logger) *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the HTTP requests by route and status, the count is the number of requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "storage",
+			Name:      "call_duration_seconds",
+			Help:      "Duration of the storage calls by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Subsystem: "storage",
+			Name:      "errors_total",
+			Help:      "Storage calls which returned an error, by method and storage error.",
+		}, []string{"method", "error"}),
+		logger: logger,
+	}
+	p.registry.MustRegister(
+		p.requestDuration,
+		p.storageDuration,
+		p.storageErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return p
+}
+
+func (p *Prometheus) ObserveRequest(route string, status int, duration time.Duration) {
+	p.requestDuration.WithLabelValues(route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+func (p *Prometheus) ObserveStorageCall(method string, errorLabel string, duration time.Duration) {
+	p.storageDuration.WithLabelValues(method).Observe(duration.Seconds())
+	if errorLabel != "" {
+		p.storageErrors.WithLabelValues(method, errorLabel).Inc()
+	}
+}
+
+// RegisterPool exports the statistics of the connection pool, read at every
+// scrape.
+func (p *Prometheus) RegisterPool(stats func() health.PoolStats) {
+	p.registry.MustRegister(&poolCollector{stats: stats})
+}
+
+// RegisterActiveSessions exports the number of active sessions, counted at
+// every scrape.
+func (p *Prometheus) RegisterActiveSessions(count func(context.Context) (int64, error)) {
+	p.registry.MustRegister(&sessionsCollector{count: count, logger: p.logger})
+}
+
+// Handler serves the metrics in the Prometheus format. They describe the
+// internals of the app, serve them on the admin listener.
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(p.logger.Handler(), slog.LevelError),
+	})
+}
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "db_pool", name), help, nil, nil)
+}
+
+var (
+	poolTotalConns      = poolDesc("total_conns", "Connections currently open.")
+	poolAcquiredConns   = poolDesc("acquired_conns", "Connections currently in use.")
+	poolIdleConns       = poolDesc("idle_conns", "Connections currently idle.")
+	poolMaxConns        = poolDesc("max_conns", "Maximum size of the pool.")
+	poolAcquires        = poolDesc("acquires_total", "Connections acquired from the pool.")
+	poolEmptyAcquires   = poolDesc("empty_acquires_total", "Acquires which waited for a connection because the pool was empty.")
+	poolCanceledAcquire = poolDesc("canceled_acquires_total", "Acquires cancelled before getting a connection.")
+	poolAcquireDuration = poolDesc("acquire_duration_seconds_total", "Time spent waiting for connections.")
+)
+
+type poolCollector struct {
+	stats func() health.PoolStats
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(s.MaxConns))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(s.AcquireCount))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquire, prometheus.CounterValue, float64(s.CanceledAcquireCount))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDuration, prometheus.CounterValue, s.AcquireDuration.Seconds())
+}
+
+var activeSessions = prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "", "active_sessions"), "Sessions which are not expired.", nil, nil)
+
+type sessionsCollector struct {
+	count  func(context.Context) (int64, error)
+	logger *slog.Logger
+}
+
+func (c *sessionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSessions
+}
+
+func (c *sessionsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), COLLECT_TIMEOUT)
+	defer cancel()
+
+	count, err := c.count(ctx)
+	if err != nil {
+		// the metric is missing from this scrape rather than wrong
+		c.logger.ErrorContext(ctx, "could not count active sessions", slog.Any("error", err))
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(activeSessions, prometheus.GaugeValue, float64(count))
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/web/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	route  string
+	status int
+}
+
+// fakeRecorder keeps the measurements instead of exporting them.
+type fakeRecorder struct {
+	mu       sync.Mutex
+	requests []request
+}
+
+func (f *fakeRecorder) ObserveRequest(route string, status int, _ time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request{route: route, status: status})
+}
+
+func (f *fakeRecorder) ObserveStorageCall(string, string, time.Duration) {}
+
+func Test_InstrumentHandler_NestedMux_RecordsMatchedPattern(t *testing.T) {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /hangouts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	api.HandleFunc("GET /individuals/{username}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/", api)
+
+	recorder := &fakeRecorder{}
+	handler := InstrumentHandler(recorder, mux)
+	for _, path := range []string{"/hangouts/0195a5f2", "/individuals/alice", "/healthz", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, []request{
+		{route: "GET /hangouts/{id}", status: http.StatusNotFound},
+		{route: "GET /individuals/{username}", status: http.StatusOK},
+		{route: "GET /healthz", status: http.StatusOK},
+		{route: ROUTE_UNMATCHED, status: http.StatusNotFound},
+	}, recorder.requests, "expected the patterns instead of the paths")
+}
+
+func scrape(t *testing.T, p *Prometheus) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code, "expected the metrics to be served")
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err, "expected the body to be readable")
+	return string(body)
+}
+
+func Test_Prometheus_Handler_ExportsObservations(t *testing.T) {
+	p := NewPrometheus(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	p.ObserveRequest("GET /hangouts/{id}", http.StatusOK, 10*time.Millisecond)
+	p.ObserveStorageCall("GetHangout", "", time.Millisecond)
+	p.ObserveStorageCall("GetHangout", "storage.ErrNotFound", time.Millisecond)
+	p.RegisterPool(func() health.PoolStats {
+		return health.PoolStats{TotalConns: 4, AcquiredConns: 1, IdleConns: 3, MaxConns: 10}
+	})
+	p.RegisterActiveSessions(func(context.Context) (int64, error) { return 7, nil })
+
+	body := scrape(t, p)
+	assert.Contains(t, body, `hangcounts_http_request_duration_seconds_count{route="GET /hangouts/{id}",status="200"} 1`)
+	assert.Contains(t, body, `hangcounts_storage_call_duration_seconds_count{method="GetHangout"} 2`)
+	assert.Contains(t, body, `hangcounts_storage_errors_total{error="storage.ErrNotFound",method="GetHangout"} 1`)
+	assert.Contains(t, body, "hangcounts_db_pool_acquired_conns 1")
+	assert.Contains(t, body, "hangcounts_db_pool_idle_conns 3")
+	assert.Contains(t, body, "hangcounts_active_sessions 7")
+}
+
+func Test_Prometheus_Handler_SessionCountFails_ServesOtherMetrics(t *testing.T) {
+	p := NewPrometheus(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	p.RegisterPool(func() health.PoolStats { return health.PoolStats{MaxConns: 10} })
+	p.RegisterActiveSessions(func(context.Context) (int64, error) { return 0, errors.New("database down") })
+
+	body := scrape(t, p)
+	assert.Contains(t, body, "hangcounts_db_pool_max_conns 10", "expected the pool metrics")
+	assert.NotContains(t, body, "hangcounts_active_sessions ", "expected the session count to be skipped")
+}
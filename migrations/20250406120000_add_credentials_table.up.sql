@@ -0,0 +1,10 @@
+CREATE TABLE credentials (
+    user_id        INT PRIMARY KEY,
+    -- self-describing hash, e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+    password_hash  TEXT NOT NULL,
+
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_credentials_user FOREIGN KEY (user_id) REFERENCES individuals (id) ON DELETE CASCADE
+);
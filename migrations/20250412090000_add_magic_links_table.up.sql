@@ -0,0 +1,14 @@
+CREATE TABLE magic_links (
+    -- sha256 of the token, the token itself is only ever sent by email
+    token_hash  CHAR(64) PRIMARY KEY,
+    user_id     INT NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    used_at     TIMESTAMPTZ,
+
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_magic_link_user FOREIGN KEY (user_id) REFERENCES individuals (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_magic_links_user_id ON magic_links(user_id);
+CREATE INDEX idx_magic_links_expires_at ON magic_links(expires_at);
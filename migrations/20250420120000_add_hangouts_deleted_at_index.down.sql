@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_hangouts_deleted_at;
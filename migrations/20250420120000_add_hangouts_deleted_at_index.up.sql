@@ -0,0 +1,3 @@
+-- used to find the soft-deleted hangouts to purge, most hangouts are never
+-- deleted so only index the deleted ones
+CREATE INDEX idx_hangouts_deleted_at ON hangouts(deleted_at) WHERE deleted_at IS NOT NULL;
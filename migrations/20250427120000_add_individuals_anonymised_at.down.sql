@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_individuals_deleted_at;
+
+ALTER TABLE individuals DROP COLUMN IF EXISTS anonymised_at;
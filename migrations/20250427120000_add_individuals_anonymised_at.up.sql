@@ -0,0 +1,6 @@
+-- individuals are anonymised once they cannot restore their account anymore,
+-- the row is kept for the hangouts of the other participants
+ALTER TABLE individuals ADD COLUMN anonymised_at TIMESTAMPTZ;
+
+-- used to find the individuals to anonymise
+CREATE INDEX idx_individuals_deleted_at ON individuals(deleted_at) WHERE deleted_at IS NOT NULL AND anonymised_at IS NULL;
@@ -0,0 +1,2 @@
+-- fails if a creator erased their account since the migration
+ALTER TABLE hangouts ALTER COLUMN created_by SET NOT NULL;
@@ -0,0 +1,5 @@
+-- individuals that erase their account keep a tombstone row, because their
+-- participations are restricted by fk_individual and still count for the
+-- other participants. The hangouts they created lose their creator, as
+-- fk_hangout_creator intends.
+ALTER TABLE hangouts ALTER COLUMN created_by DROP NOT NULL;
@@ -0,0 +1,10 @@
+// Package migrations embeds the migrations of the database, so that the app
+// can apply them without the migration files being shipped next to it.
+package migrations
+
+import "embed"
+
+// FS contains the migrations in the format of golang-migrate.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/google/uuid"
+)
+
+// hangoutCursor is the JSON representation of a storage.HangoutCursor. It is
+// base64 encoded so that clients treat it as opaque and do not build their
+// own.
+type hangoutCursor struct {
+	Date time.Time `json:"d"`
+	Id   uuid.UUID `json:"id"`
+}
+
+func encodeHangoutCursor(cursor *storage.HangoutCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	// cannot fail, the struct only holds a time and a uuid
+	raw, _ := json.Marshal(hangoutCursor{
+		Date: cursor.Date,
+		Id:   uuid.UUID(cursor.PublicId),
+	})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeHangoutCursor(encoded string) (*storage.HangoutCursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor: %w", ErrInvalidRequest, err)
+	}
+	var cursor hangoutCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor: %w", ErrInvalidRequest, err)
+	}
+	return &storage.HangoutCursor{
+		Date:     cursor.Date,
+		PublicId: model.HangoutId(cursor.Id),
+	}, nil
+}
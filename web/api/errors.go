@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Ozoniuss/hangcounts/domain/aggregate"
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+var ErrInvalidRequest = errors.New("invalid request")
+var ErrNotAccountOwner = errors.New("only the owner of the account can do this")
+
+// errorMapping ties an error to the HTTP status and the code returned to
+// clients. Codes are part of the public API, do not rename them.
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings is ordered, the first entry matching with errors.Is wins. The
+// specific errors must therefore come before the generic ones.
+var errorMappings = []errorMapping{
+	// request errors
+	{err: ErrInvalidRequest, status: http.StatusBadRequest, code: "invalid_request"},
+	{err: ErrNotAccountOwner, status: http.StatusForbidden, code: "not_account_owner"},
+
+	// aggregate validation errors
+	{err: aggregate.ErrEmptyName, status: http.StatusBadRequest, code: "empty_name"},
+	{err: aggregate.ErrEmptyUsername, status: http.StatusBadRequest, code: "empty_username"},
+	{err: aggregate.ErrInvalidEmail, status: http.StatusBadRequest, code: "invalid_email"},
+	{err: aggregate.ErrNegativeMinutes, status: http.StatusBadRequest, code: "negative_minutes"},
+	{err: aggregate.ErrEmptyLocation, status: http.StatusBadRequest, code: "empty_location"},
+	{err: aggregate.ErrMissingDate, status: http.StatusBadRequest, code: "missing_date"},
+	{err: aggregate.ErrEmptyCreator, status: http.StatusBadRequest, code: "empty_creator"},
+	{err: aggregate.ErrEmptyParticipant, status: http.StatusBadRequest, code: "empty_participant"},
+	{err: aggregate.ErrTooManyParticipants, status: http.StatusBadRequest, code: "too_many_participants"},
+	{err: aggregate.ErrNotHangoutCreator, status: http.StatusForbidden, code: "not_hangout_creator"},
+	{err: aggregate.ErrNotHangoutParticipant, status: http.StatusForbidden, code: "not_hangout_participant"},
+	{err: aggregate.ErrCreatorNotRemovable, status: http.StatusConflict, code: "creator_not_removable"},
+	{err: aggregate.ErrDuplicateUser, status: http.StatusConflict, code: "duplicate_user"},
+	{err: aggregate.ErrPasswordTooShort, status: http.StatusBadRequest, code: "password_too_short"},
+	{err: aggregate.ErrPasswordTooLong, status: http.StatusBadRequest, code: "password_too_long"},
+	{err: aggregate.ErrPasswordContainsUsername, status: http.StatusBadRequest, code: "password_contains_username"},
+
+	// statistics errors
+	{err: stats.ErrUnknownWindow, status: http.StatusBadRequest, code: "unknown_window"},
+	{err: stats.ErrInvalidReminderDays, status: http.StatusBadRequest, code: "invalid_reminder_days"},
+
+	// export errors
+	{err: export.ErrUnknownFormat, status: http.StatusBadRequest, code: "unknown_export_format"},
+
+	// authentication errors
+	{err: auth.ErrInvalidCredentials, status: http.StatusUnauthorized, code: "invalid_credentials"},
+	{err: auth.ErrPasswordNotSet, status: http.StatusConflict, code: "password_not_set"},
+	{err: auth.ErrMagicLinkInvalid, status: http.StatusUnauthorized, code: "invalid_magic_link"},
+	{err: auth.ErrRestorePeriodOver, status: http.StatusGone, code: "restore_period_over"},
+
+	// individual errors
+	{err: storage.ErrIndividualEmailAlreadyExists, status: http.StatusConflict, code: "email_already_exists"},
+	{err: storage.ErrIndividualUsernameAlreadyExists, status: http.StatusConflict, code: "username_already_exists"},
+
+	// hangout errors
+	{err: storage.ErrHangoutCreatorNotFound, status: http.StatusUnprocessableEntity, code: "hangout_creator_not_found"},
+	{err: storage.ErrHangoutCreatorDeleted, status: http.StatusUnprocessableEntity, code: "hangout_creator_deleted"},
+	{err: storage.ErrHangoutParticipantNotFound, status: http.StatusUnprocessableEntity, code: "hangout_participant_not_found"},
+	{err: storage.ErrHangoutParticipantDeleted, status: http.StatusUnprocessableEntity, code: "hangout_participant_deleted"},
+	{err: storage.ErrHangoutParticipantsChanged, status: http.StatusConflict, code: "hangout_participants_changed"},
+	{err: storage.ErrParticipantHangoutNotFound, status: http.StatusConflict, code: "participant_hangout_not_found"},
+	{err: storage.ErrParticipantIndividualNotFound, status: http.StatusUnprocessableEntity, code: "participant_individual_not_found"},
+	{err: storage.ErrNotParticipant, status: http.StatusNotFound, code: "not_participant"},
+
+	// session errors
+	{err: session.ErrNotFound, status: http.StatusUnauthorized, code: "session_not_found"},
+	{err: session.ErrUserNotFound, status: http.StatusNotFound, code: "session_user_not_found"},
+	{err: session.ErrUserDeleted, status: http.StatusGone, code: "session_user_deleted"},
+	{err: session.ErrCookieInvalidLength, status: http.StatusBadRequest, code: "session_cookie_invalid_length"},
+	{err: session.ErrUnknown, status: http.StatusInternalServerError, code: "session_storage_error"},
+
+	// generic
+	{err: storage.ErrAlreadyExists, status: http.StatusConflict, code: "already_exists"},
+	{err: storage.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+	{err: storage.ErrDeleted, status: http.StatusGone, code: "deleted"},
+	{err: storage.ErrNotDeleted, status: http.StatusConflict, code: "not_deleted"},
+	{err: storage.ErrUnknown, status: http.StatusInternalServerError, code: "storage_error"},
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// mapError returns the status code and body for err. Errors that are not
+// explicitly mapped are treated as internal errors and their message is not
+// sent to the client.
+func mapError(err error) (int, errorResponse) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, errorResponse{
+				Error: errorBody{
+					Code:    m.code,
+					Message: err.Error(),
+				},
+			}
+		}
+	}
+	return http.StatusInternalServerError, errorResponse{
+		Error: errorBody{
+			Code:    "internal_error",
+			Message: "internal server error",
+		},
+	}
+}
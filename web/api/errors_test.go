@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Ozoniuss/hangcounts/domain/aggregate"
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_mapError_EverySentinelHasAMapping(t *testing.T) {
+	sentinels := []error{
+		ErrInvalidRequest,
+		ErrNotAccountOwner,
+		storage.ErrAlreadyExists,
+		storage.ErrNotFound,
+		storage.ErrDeleted,
+		storage.ErrNotDeleted,
+		storage.ErrUnknown,
+		storage.ErrIndividualEmailAlreadyExists,
+		storage.ErrIndividualUsernameAlreadyExists,
+		storage.ErrHangoutCreatorNotFound,
+		storage.ErrHangoutCreatorDeleted,
+		storage.ErrHangoutParticipantNotFound,
+		storage.ErrHangoutParticipantDeleted,
+		storage.ErrHangoutParticipantsChanged,
+		storage.ErrParticipantHangoutNotFound,
+		storage.ErrParticipantIndividualNotFound,
+		storage.ErrNotParticipant,
+		aggregate.ErrInvalidEmail,
+		aggregate.ErrEmptyName,
+		aggregate.ErrEmptyUsername,
+		aggregate.ErrDuplicateUser,
+		aggregate.ErrNegativeMinutes,
+		aggregate.ErrEmptyLocation,
+		aggregate.ErrMissingDate,
+		aggregate.ErrEmptyCreator,
+		aggregate.ErrEmptyParticipant,
+		aggregate.ErrTooManyParticipants,
+		aggregate.ErrNotHangoutCreator,
+		aggregate.ErrNotHangoutParticipant,
+		aggregate.ErrCreatorNotRemovable,
+		aggregate.ErrPasswordTooShort,
+		aggregate.ErrPasswordTooLong,
+		aggregate.ErrPasswordContainsUsername,
+		stats.ErrUnknownWindow,
+		stats.ErrInvalidReminderDays,
+		export.ErrUnknownFormat,
+		auth.ErrInvalidCredentials,
+		auth.ErrPasswordNotSet,
+		auth.ErrMagicLinkInvalid,
+		auth.ErrRestorePeriodOver,
+		session.ErrNotFound,
+		session.ErrUserNotFound,
+		session.ErrUserDeleted,
+		session.ErrCookieInvalidLength,
+		session.ErrUnknown,
+	}
+
+	codes := make(map[string]struct{})
+	for _, sentinel := range sentinels {
+		t.Run(sentinel.Error(), func(t *testing.T) {
+			_, body := mapError(sentinel)
+			assert.NotEqual(t, "internal_error", body.Error.Code, "expected sentinel to be mapped explicitly")
+			assert.NotContains(t, codes, body.Error.Code, "expected every sentinel to have a distinct code")
+			codes[body.Error.Code] = struct{}{}
+		})
+	}
+}
+
+func Test_mapError_WrappedErrorsAreMapped(t *testing.T) {
+	status, body := mapError(fmt.Errorf("wrapped: %w", storage.ErrDeleted))
+	assert.Equal(t, http.StatusGone, status)
+	assert.Equal(t, "deleted", body.Error.Code)
+}
+
+func Test_mapError_UnknownErrorIsHidden(t *testing.T) {
+	status, body := mapError(errors.New("secret internals"))
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.NotContains(t, body.Error.Message, "secret", "expected unmapped errors to not leak")
+}
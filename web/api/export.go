@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+var exportContentTypes = map[export.Format]string{
+	export.FORMAT_JSON: "application/json",
+	export.FORMAT_ZIP:  "application/zip",
+}
+
+// handleExportAccount downloads everything stored about the logged in
+// individual.
+func (s *Server) handleExportAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format := export.FORMAT_JSON
+	if raw := r.URL.Query().Get("format"); raw != "" {
+		parsed, err := export.ParseFormat(raw)
+		if err != nil {
+			s.writeError(ctx, w, err)
+			return
+		}
+		format = parsed
+	}
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	data, err := s.export.Export(ctx, userId)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	// written to a buffer first, so that an error can still be sent to the
+	// client with the right status
+	var buf bytes.Buffer
+	if err := data.Write(&buf, format); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("hangcounts-%s-%s.%s", userId, data.ExportedAt.Format("20060102"), format)
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		s.logger.ErrorContext(ctx, "could not write export", slog.Any("error", err))
+	}
+}
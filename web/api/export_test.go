@@ -0,0 +1,70 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ExportAccount_ReturnsTheDataOfTheLoggedInIndividual(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	store.individuals["alice"] = model.Individual{Name: "Alice", Email: "alice@example.com", Username: "alice"}
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "alice"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["bob"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "alice"), http.MethodGet, "/account/export", "")
+
+	require.Equal(t, http.StatusOK, rec.Code, "expected the export to be returned")
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment", "expected the export to be downloaded")
+	var resp struct {
+		Individual struct {
+			Username string `json:"username"`
+		} `json:"individual"`
+		Hangouts []struct {
+			Created        bool     `json:"created"`
+			CoParticipants []string `json:"co_participants"`
+		} `json:"hangouts"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected the export in the body")
+	assert.Equal(t, "alice", resp.Individual.Username)
+	require.Len(t, resp.Hangouts, 1, "expected the hangout of the individual")
+	assert.True(t, resp.Hangouts[0].Created, "expected the hangout to be created by the individual")
+	assert.Equal(t, []string{"bob"}, resp.Hangouts[0].CoParticipants)
+}
+
+func TestServer_ExportAccount_ReturnsZIP(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	store.individuals["alice"] = model.Individual{Name: "Alice", Email: "alice@example.com", Username: "alice"}
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "alice"), http.MethodGet, "/account/export?format=zip", "")
+
+	require.Equal(t, http.StatusOK, rec.Code, "expected the export to be returned")
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	_, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	assert.NoError(t, err, "expected a valid zip archive")
+}
+
+func TestServer_ExportAccount_ReturnsBadRequest_WhenFormatIsUnknown(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	store.individuals["alice"] = model.Individual{Username: "alice"}
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "alice"), http.MethodGet, "/account/export?format=xml", "")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected unknown format to be rejected")
+	assert.Equal(t, "unknown_export_format", decodeErrorCode(t, rec))
+}
+
+func TestServer_ExportAccount_ReturnsUnauthorized_WithoutSession(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodGet, "/account/export", "")
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected the export to require a session")
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/aggregate"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/google/uuid"
+)
+
+type hangoutDetailsRequest struct {
+	Location        string    `json:"location"`
+	Description     *string   `json:"description"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Date            time.Time `json:"date"`
+}
+
+// toModel does not validate the details, the aggregate reports all the
+// problems at once.
+func (req hangoutDetailsRequest) toModel() model.HangoutDetails {
+	return model.HangoutDetails{
+		Location:    req.Location,
+		Description: req.Description,
+		Duration:    model.Minutes(req.DurationMinutes),
+		Date:        req.Date,
+	}
+}
+
+// the creator of the hangout is the authenticated individual
+type createHangoutRequest struct {
+	hangoutDetailsRequest
+	Participants []string `json:"participants"`
+}
+
+type updateHangoutParticipantsRequest struct {
+	Participants []string `json:"participants"`
+}
+
+type hangoutResponse struct {
+	Id              string    `json:"id"`
+	Location        string    `json:"location"`
+	Description     *string   `json:"description,omitempty"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Date            time.Time `json:"date"`
+	CreatedBy       string    `json:"created_by"`
+	Participants    []string  `json:"participants"`
+	// participants that deleted their account
+	DeletedParticipants []string `json:"deleted_participants,omitempty"`
+}
+
+type hangoutPageResponse struct {
+	Hangouts   []hangoutResponse `json:"hangouts"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+func newHangoutResponse(hangout model.Hangout) hangoutResponse {
+	resp := hangoutResponse{
+		Id:              uuid.UUID(hangout.PublicId).String(),
+		Location:        hangout.Location,
+		Description:     hangout.Description,
+		DurationMinutes: int(hangout.Duration),
+		Date:            hangout.Date,
+		CreatedBy:       string(hangout.CreatedBy),
+		Participants:    toUsernames(hangout.Individuals),
+	}
+	if len(hangout.DeletedIndividuals) > 0 {
+		resp.DeletedParticipants = toUsernames(hangout.DeletedIndividuals)
+	}
+	return resp
+}
+
+func toIndividualIds(usernames []string) []model.IndividualId {
+	ids := make([]model.IndividualId, 0, len(usernames))
+	for _, u := range usernames {
+		ids = append(ids, model.IndividualId(u))
+	}
+	return ids
+}
+
+func toUsernames(ids []model.IndividualId) []string {
+	usernames := make([]string, 0, len(ids))
+	for _, id := range ids {
+		usernames = append(usernames, string(id))
+	}
+	return usernames
+}
+
+func hangoutIdFromPath(r *http.Request) (model.HangoutId, error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return model.HangoutId{}, fmt.Errorf("%w: invalid hangout id: %w", ErrInvalidRequest, err)
+	}
+	return model.HangoutId(id), nil
+}
+
+func (s *Server) handleCreateHangout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req createHangoutRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	agg := aggregate.NewHangoutAgg(s.storage)
+	if err := agg.CreateHangout(ctx, req.toModel(), userId, toIndividualIds(req.Participants)); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	s.writeJSON(ctx, w, http.StatusCreated, newHangoutResponse(agg.Hangout))
+}
+
+func (s *Server) handleGetHangout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := hangoutIdFromPath(r)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	hangout, err := s.storage.GetHangout(ctx, id)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	s.writeJSON(ctx, w, http.StatusOK, newHangoutResponse(hangout))
+}
+
+func (s *Server) handleUpdateHangoutDetails(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := hangoutIdFromPath(r)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+	var req hangoutDetailsRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if err := aggregate.NewHangoutAgg(s.storage).UpdateDetails(ctx, id, userId, req.toModel()); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUpdateHangoutParticipants replaces the participants of the hangout,
+// the participants left out follow the rules of handleRemoveHangoutParticipant.
+func (s *Server) handleUpdateHangoutParticipants(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := hangoutIdFromPath(r)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+	var req updateHangoutParticipantsRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if err := aggregate.NewHangoutAgg(s.storage).UpdateParticipants(ctx, id, userId, toIndividualIds(req.Participants)); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// manageHangout runs action on the hangout for the authenticated individual.
+// The aggregate decides whether they are allowed to.
+func (s *Server) manageHangout(w http.ResponseWriter, r *http.Request, action func(context.Context, model.HangoutId, model.IndividualId) error) {
+	ctx := r.Context()
+
+	id, err := hangoutIdFromPath(r)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if err := action(ctx, id, userId); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteHangout(w http.ResponseWriter, r *http.Request) {
+	s.manageHangout(w, r, aggregate.NewHangoutAgg(s.storage).DeleteHangout)
+}
+
+func (s *Server) handleRestoreHangout(w http.ResponseWriter, r *http.Request) {
+	s.manageHangout(w, r, aggregate.NewHangoutAgg(s.storage).RestoreHangout)
+}
+
+// handleRemoveHangoutParticipant lets participants leave the hangout, and the
+// creator remove any of them.
+func (s *Server) handleRemoveHangoutParticipant(w http.ResponseWriter, r *http.Request) {
+	participant := model.IndividualId(r.PathValue("username"))
+	s.manageHangout(w, r, func(ctx context.Context, id model.HangoutId, userId model.IndividualId) error {
+		return aggregate.NewHangoutAgg(s.storage).RemoveParticipant(ctx, id, userId, participant)
+	})
+}
+
+// hangoutListQuery reads the pagination and the filters of a hangout list
+// from the query string.
+func hangoutListQuery(r *http.Request) (storage.HangoutFilter, *storage.HangoutCursor, int, error) {
+	query := r.URL.Query()
+	var filter storage.HangoutFilter
+
+	limit := DEFAULT_PAGE_SIZE
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > MAX_PAGE_SIZE {
+			return filter, nil, 0, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRequest, MAX_PAGE_SIZE)
+		}
+		limit = parsed
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{name: "from", dst: &filter.From},
+		{name: "to", dst: &filter.To},
+	} {
+		raw := query.Get(bound.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, nil, 0, fmt.Errorf("%w: invalid %s date: %w", ErrInvalidRequest, bound.name, err)
+		}
+		*bound.dst = parsed
+	}
+	filter.With = model.IndividualId(query.Get("with"))
+
+	after, err := decodeHangoutCursor(query.Get("cursor"))
+	if err != nil {
+		return filter, nil, 0, err
+	}
+
+	return filter, after, limit, nil
+}
+
+func (s *Server) handleListHangoutsOfIndividual(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	username := model.IndividualId(r.PathValue("username"))
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if username != userId {
+		s.writeError(ctx, w, ErrNotAccountOwner)
+		return
+	}
+
+	filter, after, limit, err := hangoutListQuery(r)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	page, err := s.storage.ListHangoutsForIndividual(ctx, username, filter, after, limit)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	resp := hangoutPageResponse{
+		Hangouts:   make([]hangoutResponse, 0, len(page.Hangouts)),
+		NextCursor: encodeHangoutCursor(page.Next),
+	}
+	for _, hangout := range page.Hangouts {
+		resp.Hangouts = append(resp.Hangouts, newHangoutResponse(hangout))
+	}
+	s.writeJSON(ctx, w, http.StatusOK, resp)
+}
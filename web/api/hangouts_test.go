@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/infrastructure"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTimelineServer stores alice, bob and carol, and a hangout of alice on
+// each of the given days of March 2025.
+func newTimelineServer(t *testing.T, days ...int) (*Server, *infrastructure.InMemoryStore, *fakeSessionStorage) {
+	t.Helper()
+	store := infrastructure.NewInMemoryStore()
+	for _, username := range []string{"alice", "bob", "carol"} {
+		err := store.StoreIndividual(t.Context(), model.Individual{
+			Username: model.IndividualId(username),
+			Name:     username,
+			Email:    model.Email(username + "@example.com"),
+		})
+		require.NoError(t, err, "expected no error when storing individual")
+	}
+	for i, day := range days {
+		participants := []model.IndividualId{"alice", "bob"}
+		if i%2 == 1 {
+			participants = []model.IndividualId{"alice", "carol"}
+		}
+		err := store.StoreHangoutOfIndividuals(t.Context(), model.Hangout{
+			PublicId: model.HangoutId(uuid.New()),
+			HangoutDetails: model.HangoutDetails{
+				Location: fmt.Sprintf("day %d", day),
+				Duration: 60,
+				Date:     time.Date(2025, 3, day, 18, 0, 0, 0, time.UTC),
+			},
+			CreatedBy:   "alice",
+			Individuals: participants,
+		})
+		require.NoError(t, err, "expected no error when storing hangout")
+	}
+	sessions := &fakeSessionStorage{sessions: make(map[string]session.Session)}
+	return newTestServerWithStorage(store, sessions), store, sessions
+}
+
+func decodeHangoutPage(t *testing.T, rec *httptest.ResponseRecorder) hangoutPageResponse {
+	t.Helper()
+	require.Equal(t, http.StatusOK, rec.Code, "expected hangouts to be listed")
+	var page hangoutPageResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&page), "expected a page in the body")
+	return page
+}
+
+func locations(page hangoutPageResponse) []string {
+	var locations []string
+	for _, h := range page.Hangouts {
+		locations = append(locations, h.Location)
+	}
+	return locations
+}
+
+func TestServer_ListHangouts_ReturnsEveryPage_MostRecentFirst(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t, 1, 2, 3, 4, 5)
+	cookie := storeSession(t, sessions, "alice")
+
+	page := decodeHangoutPage(t, doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/hangouts?limit=2", ""))
+	assert.Equal(t, []string{"day 5", "day 4"}, locations(page))
+	require.NotEmpty(t, page.NextCursor, "expected a cursor to the next page")
+
+	page = decodeHangoutPage(t, doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/hangouts?limit=2&cursor="+page.NextCursor, ""))
+	assert.Equal(t, []string{"day 3", "day 2"}, locations(page))
+	require.NotEmpty(t, page.NextCursor, "expected a cursor to the next page")
+
+	page = decodeHangoutPage(t, doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/hangouts?limit=2&cursor="+page.NextCursor, ""))
+	assert.Equal(t, []string{"day 1"}, locations(page))
+	assert.Empty(t, page.NextCursor, "expected no cursor on the last page")
+}
+
+func TestServer_ListHangouts_AppliesTheFilters(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t, 1, 2, 3, 4, 5)
+	alice := storeSession(t, sessions, "alice")
+	carol := storeSession(t, sessions, "carol")
+
+	page := decodeHangoutPage(t, doAuthenticatedRequest(t, srv, alice, http.MethodGet, "/individuals/alice/hangouts?with=carol", ""))
+	assert.Equal(t, []string{"day 4", "day 2"}, locations(page), "expected only the hangouts with carol")
+
+	page = decodeHangoutPage(t, doAuthenticatedRequest(t, srv, alice, http.MethodGet, "/individuals/alice/hangouts?from=2025-03-02T00:00:00Z&to=2025-03-04T18:00:00Z", ""))
+	assert.Equal(t, []string{"day 3", "day 2"}, locations(page), "expected from to be inclusive and to exclusive")
+
+	page = decodeHangoutPage(t, doAuthenticatedRequest(t, srv, carol, http.MethodGet, "/individuals/carol/hangouts", ""))
+	assert.Equal(t, []string{"day 4", "day 2"}, locations(page), "expected only the hangouts of carol")
+}
+
+func TestServer_ListHangouts_ReturnsForbidden_WhenListingSomeoneElse(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t, 1, 2)
+	cookie := storeSession(t, sessions, "bob")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/hangouts", "")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected the hangouts of someone else to be hidden")
+	assert.Equal(t, "not_account_owner", decodeErrorCode(t, rec))
+}
+
+func TestServer_ListHangouts_ReturnsUnauthorized_WhenNotLoggedIn(t *testing.T) {
+	srv, _, _ := newTimelineServer(t, 1)
+
+	rec := doRequest(t, srv, http.MethodGet, "/individuals/alice/hangouts", "")
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected a session to be required")
+}
+
+func TestServer_ListHangouts_ReturnsNotFound_WhenIndividualDoesntExist(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t)
+	// a session can outlive its individual
+	cookie := storeSession(t, sessions, "dave")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/dave/hangouts", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code, "expected unknown individual to be reported")
+}
+
+func TestServer_ListHangouts_ReturnsBadRequest_WhenQueryIsInvalid(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t)
+	cookie := storeSession(t, sessions, "alice")
+
+	for _, query := range []string{"limit=0", "limit=1000", "limit=abc", "from=yesterday", "cursor=!!!", "cursor=bm90LWpzb24"} {
+		t.Run(query, func(t *testing.T) {
+			rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/hangouts?"+query, "")
+			assert.Equal(t, http.StatusBadRequest, rec.Code, "expected invalid query to be rejected")
+			assert.Equal(t, "invalid_request", decodeErrorCode(t, rec))
+		})
+	}
+}
+
+func Test_decodeHangoutCursor_ReturnsEncodedCursor(t *testing.T) {
+	cursor := &storage.HangoutCursor{
+		Date:     time.Date(2025, 3, 16, 10, 0, 0, 123456000, time.UTC),
+		PublicId: model.HangoutId(uuid.New()),
+	}
+
+	decoded, err := decodeHangoutCursor(encodeHangoutCursor(cursor))
+	require.NoError(t, err, "expected encoded cursor to be valid")
+	assert.True(t, cursor.Date.Equal(decoded.Date), "expected the same date")
+	assert.Equal(t, cursor.PublicId, decoded.PublicId, "expected the same public id")
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Ozoniuss/hangcounts/domain/aggregate"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+type createIndividualRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// individualResponse is what anyone can read about an individual, the email
+// is only returned to the individual themselves.
+type individualResponse struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+func newIndividualResponse(individual model.Individual) individualResponse {
+	return individualResponse{
+		Name:     individual.Name,
+		Username: string(individual.Username),
+	}
+}
+
+type accountResponse struct {
+	individualResponse
+	Email string `json:"email"`
+}
+
+func newAccountResponse(individual model.Individual) accountResponse {
+	return accountResponse{
+		individualResponse: newIndividualResponse(individual),
+		Email:              string(individual.Email),
+	}
+}
+
+func (s *Server) handleCreateIndividual(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req createIndividualRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	individual, err := aggregate.NewIndividual(req.Name, req.Email, req.Username)
+	if err != nil {
+		// report the password violations as well, so that the client can fix
+		// everything in one go
+		s.writeError(ctx, w, errors.Join(err, aggregate.ValidatePassword(req.Password, model.IndividualId(req.Username))))
+		return
+	}
+
+	if err := s.auth.Register(ctx, individual, req.Password); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	s.writeJSON(ctx, w, http.StatusCreated, newAccountResponse(individual))
+}
+
+func (s *Server) handleGetIndividual(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	username := model.IndividualId(r.PathValue("username"))
+
+	individual, err := s.storage.GetIndividual(ctx, username)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	s.writeJSON(ctx, w, http.StatusOK, newIndividualResponse(individual))
+}
+
+// handleGetAccount returns the individual that is logged in, with their
+// email.
+func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	individual, err := s.storage.GetIndividual(ctx, userId)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	s.writeJSON(ctx, w, http.StatusOK, newAccountResponse(individual))
+}
+
+// handleDeleteIndividual lets individuals delete their own account, which
+// they can restore during the restore period.
+func (s *Server) handleDeleteIndividual(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	username := model.IndividualId(r.PathValue("username"))
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if username != userId {
+		s.writeError(ctx, w, ErrNotAccountOwner)
+		return
+	}
+
+	if err := s.storage.MarkIndividualAsDeleted(ctx, username); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
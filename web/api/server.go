@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/aggregate"
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+const (
+	// upper bound for request bodies, none of the endpoints should receive
+	// anything close to this
+	MAX_REQUEST_BODY_BYTES = 1 << 20
+
+	SHUTDOWN_TIMEOUT = 10 * time.Second
+
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+
+	DEFAULT_REMINDER_DAYS = 30
+)
+
+// Storage contains the storage operations the HTTP handlers rely on.
+type Storage interface {
+	GetIndividual(context.Context, model.IndividualId) (model.Individual, error)
+	MarkIndividualAsDeleted(context.Context, model.IndividualId) error
+	ListHangoutsForIndividual(context.Context, model.IndividualId, storage.HangoutFilter, *storage.HangoutCursor, int) (storage.HangoutPage, error)
+	// the hangouts are changed through their aggregate
+	aggregate.HangoutStorage
+}
+
+type Server struct {
+	storage    Storage
+	sessions   *session.SessionManager
+	auth       *auth.Service
+	magicLinks *auth.MagicLinkService
+	stats      *stats.Service
+	export     *export.Service
+	logger     *slog.Logger
+	mux        *http.ServeMux
+}
+
+// NewServer does not expose the magic link endpoints if magicLinks is nil.
+func NewServer(store Storage, sessions *session.SessionManager, authService *auth.Service, magicLinks *auth.MagicLinkService, statsService *stats.Service, exportService *export.Service, logger *slog.Logger) *Server {
+	s := &Server{
+		storage:    store,
+		sessions:   sessions,
+		auth:       authService,
+		magicLinks: magicLinks,
+		stats:      statsService,
+		export:     exportService,
+		logger:     logger,
+		mux:        http.NewServeMux(),
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("POST /individuals", s.handleCreateIndividual)
+	s.mux.HandleFunc("GET /individuals/{username}", s.handleGetIndividual)
+	s.mux.Handle("DELETE /individuals/{username}", s.sessions.Authenticate(http.HandlerFunc(s.handleDeleteIndividual)))
+	s.mux.Handle("GET /individuals/{username}/hangouts", s.sessions.Authenticate(http.HandlerFunc(s.handleListHangoutsOfIndividual)))
+	s.mux.Handle("GET /individuals/{username}/stats", s.sessions.Authenticate(http.HandlerFunc(s.handleGetPairStats)))
+	s.mux.Handle("GET /individuals/{username}/streaks", s.sessions.Authenticate(http.HandlerFunc(s.handleGetStreaks)))
+	s.mux.Handle("GET /individuals/{username}/reminders", s.sessions.Authenticate(http.HandlerFunc(s.handleGetReminders)))
+
+	s.mux.Handle("POST /hangouts", s.sessions.Authenticate(http.HandlerFunc(s.handleCreateHangout)))
+	s.mux.HandleFunc("GET /hangouts/{id}", s.handleGetHangout)
+	s.mux.Handle("PUT /hangouts/{id}/details", s.sessions.Authenticate(http.HandlerFunc(s.handleUpdateHangoutDetails)))
+	s.mux.Handle("PUT /hangouts/{id}/participants", s.sessions.Authenticate(http.HandlerFunc(s.handleUpdateHangoutParticipants)))
+	s.mux.Handle("DELETE /hangouts/{id}", s.sessions.Authenticate(http.HandlerFunc(s.handleDeleteHangout)))
+	s.mux.Handle("POST /hangouts/{id}/restore", s.sessions.Authenticate(http.HandlerFunc(s.handleRestoreHangout)))
+	s.mux.Handle("DELETE /hangouts/{id}/participants/{username}", s.sessions.Authenticate(http.HandlerFunc(s.handleRemoveHangoutParticipant)))
+
+	s.mux.HandleFunc("POST /sessions", s.handleLogin)
+	s.mux.Handle("DELETE /sessions/current", s.sessions.Authenticate(http.HandlerFunc(s.handleLogout)))
+	s.mux.Handle("DELETE /sessions", s.sessions.Authenticate(http.HandlerFunc(s.handleLogoutEverywhere)))
+	s.mux.Handle("GET /account", s.sessions.Authenticate(http.HandlerFunc(s.handleGetAccount)))
+	s.mux.Handle("PUT /account/password", s.sessions.Authenticate(http.HandlerFunc(s.handleChangePassword)))
+	s.mux.HandleFunc("POST /account/restore", s.handleRestoreAccount)
+	s.mux.Handle("GET /account/export", s.sessions.Authenticate(http.HandlerFunc(s.handleExportAccount)))
+
+	if s.magicLinks != nil {
+		s.mux.HandleFunc("POST /sessions/magic-link", s.handleRequestMagicLink)
+		s.mux.HandleFunc("POST /sessions/magic-link/exchange", s.handleExchangeMagicLink)
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve listens on addr until ctx is cancelled, after which it waits for the
+// in-flight requests to finish before returning. It keeps serving for
+// drainDelay after ctx is cancelled, which gives the load balancer the time
+// to notice the failing readiness probe and stop routing requests here.
+func Serve(ctx context.Context, addr string, handler http.Handler, drainDelay time.Duration, logger *slog.Logger) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("http server listening", slog.String("address", addr))
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("http server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	if drainDelay > 0 {
+		logger.Info("draining http server", slog.Duration("delay", drainDelay))
+		time.Sleep(drainDelay)
+	}
+	logger.Info("shutting down http server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("could not shut down http server gracefully: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("http server stopped: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) writeJSON(ctx context.Context, w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.ErrorContext(ctx, "could not encode response", slog.Any("error", err))
+	}
+}
+
+func (s *Server) writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	status, body := mapError(err)
+	if status >= http.StatusInternalServerError {
+		s.logger.ErrorContext(ctx, "request failed", slog.Int("status", status), slog.Any("error", err))
+	} else {
+		s.logger.DebugContext(ctx, "request rejected", slog.Int("status", status), slog.Any("error", err))
+	}
+	s.writeJSON(ctx, w, status, body)
+}
+
+// readJSON decodes the request body into dst, rejecting unknown fields and
+// trailing data.
+func readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_REQUEST_BODY_BYTES)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("%w: could not decode body: %w", ErrInvalidRequest, err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: body must contain a single json object", ErrInvalidRequest)
+	}
+	return nil
+}
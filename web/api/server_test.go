@@ -0,0 +1,873 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/export"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/auth"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStorage struct {
+	individuals map[model.IndividualId]model.Individual
+	deleted     map[model.IndividualId]bool
+	hashes      map[model.IndividualId]string
+	hangouts    []model.Hangout
+	// soft-deleted hangouts
+	removed map[model.HangoutId]bool
+	err     error
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		individuals: make(map[model.IndividualId]model.Individual),
+		deleted:     make(map[model.IndividualId]bool),
+		hashes:      make(map[model.IndividualId]string),
+		removed:     make(map[model.HangoutId]bool),
+	}
+}
+
+func (f *fakeStorage) StoreIndividualWithPassword(_ context.Context, individual model.Individual, passwordHash string) error {
+	if f.err != nil {
+		return f.err
+	}
+	if _, ok := f.individuals[individual.Username]; ok {
+		return storage.ErrIndividualUsernameAlreadyExists
+	}
+	f.individuals[individual.Username] = individual
+	f.hashes[individual.Username] = passwordHash
+	return nil
+}
+
+func (f *fakeStorage) GetPasswordHash(_ context.Context, username model.IndividualId) (string, error) {
+	if _, ok := f.individuals[username]; !ok {
+		return "", storage.ErrNotFound
+	}
+	if f.deleted[username] {
+		return "", storage.ErrDeleted
+	}
+	hash, ok := f.hashes[username]
+	if !ok {
+		return "", auth.ErrPasswordNotSet
+	}
+	return hash, nil
+}
+
+// GetDeletedPasswordHash pretends every individual was deleted just now, the
+// restore period is tested by the authentication service.
+func (f *fakeStorage) GetDeletedPasswordHash(_ context.Context, username model.IndividualId) (string, time.Time, error) {
+	if _, ok := f.individuals[username]; !ok {
+		return "", time.Time{}, storage.ErrNotFound
+	}
+	if !f.deleted[username] {
+		return "", time.Time{}, storage.ErrNotDeleted
+	}
+	hash, ok := f.hashes[username]
+	if !ok {
+		return "", time.Time{}, auth.ErrPasswordNotSet
+	}
+	return hash, time.Now(), nil
+}
+
+func (f *fakeStorage) RestoreIndividual(_ context.Context, username model.IndividualId) error {
+	if _, ok := f.individuals[username]; !ok {
+		return storage.ErrNotFound
+	}
+	if !f.deleted[username] {
+		return storage.ErrNotDeleted
+	}
+	delete(f.deleted, username)
+	return nil
+}
+
+func (f *fakeStorage) UpdatePasswordHash(_ context.Context, username model.IndividualId, passwordHash string) error {
+	f.hashes[username] = passwordHash
+	return nil
+}
+
+func (f *fakeStorage) GetIndividual(_ context.Context, id model.IndividualId) (model.Individual, error) {
+	individual, ok := f.individuals[id]
+	if !ok {
+		return model.Individual{}, storage.ErrNotFound
+	}
+	if f.deleted[id] {
+		return model.Individual{}, storage.ErrDeleted
+	}
+	return individual, nil
+}
+
+func (f *fakeStorage) MarkIndividualAsDeleted(_ context.Context, id model.IndividualId) error {
+	if _, ok := f.individuals[id]; !ok {
+		return storage.ErrNotFound
+	}
+	if f.deleted[id] {
+		return storage.ErrDeleted
+	}
+	f.deleted[id] = true
+	return nil
+}
+
+func (f *fakeStorage) StoreHangoutOfIndividuals(_ context.Context, hangout model.Hangout) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.hangouts = append(f.hangouts, hangout)
+	return nil
+}
+
+func (f *fakeStorage) hangoutIndex(id model.HangoutId) (int, error) {
+	for i, h := range f.hangouts {
+		if h.PublicId == id {
+			return i, nil
+		}
+	}
+	return 0, storage.ErrNotFound
+}
+
+func (f *fakeStorage) GetHangout(_ context.Context, id model.HangoutId) (model.Hangout, error) {
+	i, err := f.hangoutIndex(id)
+	if err != nil {
+		return model.Hangout{}, err
+	}
+	if f.removed[id] {
+		return model.Hangout{}, storage.ErrDeleted
+	}
+	return f.hangouts[i], nil
+}
+
+func (f *fakeStorage) GetHangoutCreator(_ context.Context, id model.HangoutId) (model.IndividualId, error) {
+	i, err := f.hangoutIndex(id)
+	if err != nil {
+		return "", err
+	}
+	if f.hangouts[i].CreatedBy == "" {
+		return "", storage.ErrHangoutCreatorNotFound
+	}
+	return f.hangouts[i].CreatedBy, nil
+}
+
+func (f *fakeStorage) IsHangoutParticipant(_ context.Context, id model.HangoutId, individual model.IndividualId) (bool, error) {
+	i, err := f.hangoutIndex(id)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(f.hangouts[i].Individuals, individual), nil
+}
+
+func (f *fakeStorage) MarkHangoutAsDeleted(_ context.Context, id model.HangoutId) error {
+	if _, err := f.hangoutIndex(id); err != nil {
+		return err
+	}
+	if f.removed[id] {
+		return storage.ErrDeleted
+	}
+	f.removed[id] = true
+	return nil
+}
+
+func (f *fakeStorage) RemoveHangoutParticipant(_ context.Context, id model.HangoutId, participant model.IndividualId) error {
+	i, err := f.hangoutIndex(id)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(f.hangouts[i].Individuals, participant) {
+		return storage.ErrNotParticipant
+	}
+	f.hangouts[i].Individuals = slices.DeleteFunc(slices.Clone(f.hangouts[i].Individuals), func(p model.IndividualId) bool {
+		return p == participant
+	})
+	return nil
+}
+
+func (f *fakeStorage) RestoreHangout(_ context.Context, id model.HangoutId) error {
+	if _, err := f.hangoutIndex(id); err != nil {
+		return err
+	}
+	if !f.removed[id] {
+		return storage.ErrNotDeleted
+	}
+	delete(f.removed, id)
+	return nil
+}
+
+// ListHangoutsForIndividual ignores the filter and the pagination, the
+// hangout list tests run against the in-memory store.
+func (f *fakeStorage) ListHangoutsForIndividual(_ context.Context, username model.IndividualId, _ storage.HangoutFilter, _ *storage.HangoutCursor, _ int) (storage.HangoutPage, error) {
+	if f.err != nil {
+		return storage.HangoutPage{}, f.err
+	}
+	var page storage.HangoutPage
+	for _, h := range f.hangouts {
+		if slices.Contains(h.Individuals, username) {
+			page.Hangouts = append(page.Hangouts, h)
+		}
+	}
+	return page, nil
+}
+
+// the statistics tests run against the in-memory store
+func (f *fakeStorage) PairStatsForIndividual(_ context.Context, _ model.IndividualId, _, _ time.Time) ([]stats.PairStats, error) {
+	return nil, f.err
+}
+
+func (f *fakeStorage) PairHangoutDates(_ context.Context, _ model.IndividualId, _ time.Time) ([]stats.PairDates, error) {
+	return nil, f.err
+}
+
+func (f *fakeStorage) IndividualsNotSeenSince(_ context.Context, _ model.IndividualId, _, _ time.Time) ([]stats.Reminder, error) {
+	return nil, f.err
+}
+
+// GetIndividualForExport does not remember when individuals were deleted.
+func (f *fakeStorage) GetIndividualForExport(_ context.Context, id model.IndividualId) (model.Individual, *time.Time, error) {
+	if f.err != nil {
+		return model.Individual{}, nil, f.err
+	}
+	individual, ok := f.individuals[id]
+	if !ok {
+		return model.Individual{}, nil, storage.ErrNotFound
+	}
+	return individual, nil, nil
+}
+
+// ListHangoutsForExport ignores the pagination and only knows the current
+// participants.
+func (f *fakeStorage) ListHangoutsForExport(_ context.Context, username model.IndividualId, _ *storage.HangoutCursor, _ int) (export.HangoutPage, error) {
+	if f.err != nil {
+		return export.HangoutPage{}, f.err
+	}
+	var page export.HangoutPage
+	for _, h := range f.hangouts {
+		if slices.Contains(h.Individuals, username) {
+			page.Hangouts = append(page.Hangouts, export.Hangout{Hangout: h})
+		}
+	}
+	return page, nil
+}
+
+func (f *fakeStorage) ListSessionsOfIndividual(_ context.Context, _ model.IndividualId) ([]export.Session, error) {
+	return nil, f.err
+}
+
+func (f *fakeStorage) UpdateHangoutDetails(_ context.Context, id model.HangoutId, details model.HangoutDetails) error {
+	if f.err != nil {
+		return f.err
+	}
+	i, err := f.hangoutIndex(id)
+	if err != nil {
+		return err
+	}
+	f.hangouts[i].HangoutDetails = details
+	return nil
+}
+
+func (f *fakeStorage) UpdateHangoutParticipants(_ context.Context, id model.HangoutId, expected, participants []model.IndividualId) error {
+	if f.err != nil {
+		return f.err
+	}
+	i, err := f.hangoutIndex(id)
+	if err != nil {
+		return err
+	}
+	if !slices.Equal(expected, f.hangouts[i].Individuals) {
+		return storage.ErrHangoutParticipantsChanged
+	}
+	f.hangouts[i].Individuals = participants
+	return nil
+}
+
+type fakeSessionStorage struct {
+	sessions map[string]session.Session
+}
+
+func (f *fakeSessionStorage) StoreSession(_ context.Context, s session.Session) error {
+	f.sessions[s.CookieValue] = s
+	return nil
+}
+
+func (f *fakeSessionStorage) GetSession(_ context.Context, cookie string) (session.Session, error) {
+	s, ok := f.sessions[cookie]
+	if !ok {
+		return session.Session{}, session.ErrNotFound
+	}
+	return s, nil
+}
+
+func (f *fakeSessionStorage) UpdateLastAccessed(_ context.Context, cookie string, lastAccessed time.Time) error {
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteSession(_ context.Context, cookie string) error {
+	delete(f.sessions, cookie)
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteSessionsOfUser(_ context.Context, userId model.IndividualId) (int64, error) {
+	var removed int64
+	for cookie, s := range f.sessions {
+		if s.UserID == userId {
+			delete(f.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (f *fakeSessionStorage) DeleteOtherSessionsOfUser(_ context.Context, userId model.IndividualId, keepCookie string) (int64, error) {
+	var removed int64
+	for cookie, s := range f.sessions {
+		if s.UserID == userId && cookie != keepCookie {
+			delete(f.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (f *fakeSessionStorage) RotateSession(_ context.Context, oldCookie string, newSession session.Session) error {
+	delete(f.sessions, oldCookie)
+	f.sessions[newSession.CookieValue] = newSession
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteExpiredSessions(_ context.Context, idleBefore, createdBefore time.Time, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeSessionStorage) CountActiveSessions(_ context.Context, idleAfter, createdAfter time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newTestServer() (*Server, *fakeStorage) {
+	srv, store, _ := newTestServerWithSessions()
+	return srv, store
+}
+
+func newTestServerWithSessions() (*Server, *fakeStorage, *fakeSessionStorage) {
+	store := newFakeStorage()
+	sessions := &fakeSessionStorage{sessions: make(map[string]session.Session)}
+	return newTestServerWithStorage(store, sessions), store, sessions
+}
+
+// testStorage is what both the server and the authentication service need.
+type testStorage interface {
+	Storage
+	auth.CredentialStorage
+	stats.Storage
+	export.Storage
+}
+
+func newTestServerWithStorage(store testStorage, sessions session.SessionStorage) *Server {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager := session.NewSessionManager(sessions, 30*time.Minute, 24*time.Hour, session.SESSION_COOKIE_NAME, logger)
+	// cheap parameters, the tests don't care about the strength of the hash
+	authService, err := auth.NewService(store, &auth.Argon2idHasher{Time: 1, MemoryKiB: 64, Threads: 1}, manager, 24*time.Hour, logger)
+	if err != nil {
+		panic(err)
+	}
+	return NewServer(store, manager, authService, nil, stats.NewService(store), export.NewService(store), logger)
+}
+
+// storeSession adds a session for userId and returns its cookie.
+func storeSession(t *testing.T, sessions *fakeSessionStorage, userId model.IndividualId) *http.Cookie {
+	t.Helper()
+	s, err := session.NewSessionForUser(userId)
+	require.NoError(t, err, "expected no error when creating session")
+	sessions.sessions[s.CookieValue] = s
+	return &http.Cookie{Name: session.SESSION_COOKIE_NAME, Value: s.CookieValue}
+}
+
+func doAuthenticatedRequest(t *testing.T, h http.Handler, cookie *http.Cookie, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func doRequest(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeErrorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp errorResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected error body to be valid json")
+	return resp.Error.Code
+}
+
+func TestServer_CreateIndividual_ReturnsCreated_WhenIndividualIsValid(t *testing.T) {
+	srv, store := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", `{"name":"name","email":"test@example.com","username":"username","password":"correct horse battery staple"}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code, "expected individual to be created")
+	assert.Contains(t, store.individuals, model.IndividualId("username"), "expected individual to be stored")
+}
+
+func TestServer_CreateIndividual_ReturnsBadRequest_WhenFieldsAreInvalid(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", `{"name":"","email":"test@example.com","username":"username","password":"correct horse battery staple"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected validation to fail")
+	assert.Equal(t, "empty_name", decodeErrorCode(t, rec))
+}
+
+func TestServer_CreateIndividual_ReturnsBadRequest_WhenPasswordViolatesPolicy(t *testing.T) {
+	srv, store := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", `{"name":"name","email":"test@example.com","username":"username","password":"short"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected validation to fail")
+	assert.Equal(t, "password_too_short", decodeErrorCode(t, rec))
+	assert.Empty(t, store.individuals, "expected no individual to be stored")
+}
+
+func TestServer_CreateIndividual_ReturnsBadRequest_WhenBodyHasUnknownFields(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", `{"unknown":"field"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected body to be rejected")
+	assert.Equal(t, "invalid_request", decodeErrorCode(t, rec))
+}
+
+func TestServer_CreateIndividual_ReturnsConflict_WhenUsernameIsTaken(t *testing.T) {
+	srv, _ := newTestServer()
+	body := `{"name":"name","email":"test@example.com","username":"username","password":"correct horse battery staple"}`
+
+	doRequest(t, srv, http.MethodPost, "/individuals", body)
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", body)
+
+	assert.Equal(t, http.StatusConflict, rec.Code, "expected conflict for duplicate username")
+	assert.Equal(t, "username_already_exists", decodeErrorCode(t, rec))
+}
+
+func TestServer_GetIndividual_DoesNotReturnTheEmail(t *testing.T) {
+	srv, store := newTestServer()
+	store.individuals["username"] = model.Individual{Name: "name", Email: "test@example.com", Username: "username"}
+
+	rec := doRequest(t, srv, http.MethodGet, "/individuals/username", "")
+
+	require.Equal(t, http.StatusOK, rec.Code, "expected the individual to be returned")
+	var resp map[string]any
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected the individual in the body")
+	assert.Equal(t, "username", resp["username"])
+	assert.NotContains(t, resp, "email", "expected the email to not be public")
+}
+
+func TestServer_GetAccount_ReturnsTheEmailOfTheLoggedInIndividual(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	store.individuals["username"] = model.Individual{Name: "name", Email: "test@example.com", Username: "username"}
+
+	rec := doRequest(t, srv, http.MethodGet, "/account", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected the account to require a session")
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "username"), http.MethodGet, "/account", "")
+	require.Equal(t, http.StatusOK, rec.Code, "expected the account to be returned")
+	var resp accountResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected the account in the body")
+	assert.Equal(t, "test@example.com", resp.Email)
+	assert.Equal(t, "username", resp.Username)
+}
+
+func TestServer_GetIndividual_ReturnsNotFound_WhenIndividualDoesntExist(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodGet, "/individuals/username", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code, "expected not found")
+	assert.Equal(t, "not_found", decodeErrorCode(t, rec))
+}
+
+func TestServer_GetIndividual_ReturnsGone_WhenIndividualIsDeleted(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	store.individuals["username"] = model.Individual{Username: "username"}
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "username"), http.MethodDelete, "/individuals/username", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected individual to be deleted")
+
+	rec = doRequest(t, srv, http.MethodGet, "/individuals/username", "")
+	assert.Equal(t, http.StatusGone, rec.Code, "expected deleted individual to be gone")
+}
+
+func TestServer_DeleteIndividual_ReturnsUnauthorized_WithoutSession(t *testing.T) {
+	srv, store := newTestServer()
+	store.individuals["username"] = model.Individual{Username: "username"}
+
+	rec := doRequest(t, srv, http.MethodDelete, "/individuals/username", "")
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected anonymous deletion to be rejected")
+	assert.NotContains(t, store.deleted, model.IndividualId("username"), "expected individual to not be deleted")
+}
+
+func TestServer_DeleteIndividual_ReturnsForbidden_WhenAccountIsNotOwned(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	store.individuals["username"] = model.Individual{Username: "username"}
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "other"), http.MethodDelete, "/individuals/username", "")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected deletion of another account to be rejected")
+	assert.Equal(t, "not_account_owner", decodeErrorCode(t, rec))
+	assert.NotContains(t, store.deleted, model.IndividualId("username"), "expected individual to not be deleted")
+}
+
+func TestServer_CreateHangout_ReturnsBadRequest_WhenMinutesAreNegative(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":-1,"date":"2025-03-16T10:00:00Z"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected negative minutes to be rejected")
+	assert.Equal(t, "negative_minutes", decodeErrorCode(t, rec))
+	assert.Empty(t, store.hangouts, "expected no hangout to be stored")
+}
+
+func TestServer_CreateHangout_ReturnsCreated_WhenHangoutIsValid(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["creator","other"]}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	require.Len(t, store.hangouts, 1, "expected hangout to be stored")
+	assert.Equal(t, []model.IndividualId{"creator", "other"}, store.hangouts[0].Individuals)
+}
+
+func TestServer_CreateHangout_AddsCreatorToParticipants(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other","other"]}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	require.Len(t, store.hangouts, 1, "expected hangout to be stored")
+	assert.Equal(t, []model.IndividualId{"creator", "other"}, store.hangouts[0].Individuals)
+}
+
+func TestServer_CreateHangout_ReturnsBadRequest_WhenLocationIsEmpty(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"","duration_minutes":10,"date":"2025-03-16T10:00:00Z"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected empty location to be rejected")
+	assert.Equal(t, "empty_location", decodeErrorCode(t, rec))
+	assert.Empty(t, store.hangouts, "expected no hangout to be stored")
+}
+
+func TestServer_CreateHangout_ReturnsUnprocessable_WhenCreatorIsDeleted(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	store.err = storage.ErrHangoutCreatorDeleted
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "expected storage error to be mapped")
+	assert.Equal(t, "hangout_creator_deleted", decodeErrorCode(t, rec))
+}
+
+func TestServer_GetHangout_ReturnsBadRequest_WhenIdIsNotAUuid(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodGet, "/hangouts/not-a-uuid", "")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected invalid id to be rejected")
+}
+
+func TestServer_CreateHangout_ReturnsUnauthorized_WithoutSession(t *testing.T) {
+	srv, store := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z"}`)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected creation to require a session")
+	assert.Empty(t, store.hangouts, "expected no hangout to be stored")
+}
+
+func TestServer_CreateHangout_ReturnsBadRequest_WhenCreatorIsInTheBody(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","created_by":"someone"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected the creator to come from the session only")
+	assert.Empty(t, store.hangouts, "expected no hangout to be stored")
+}
+
+func TestServer_UpdateHangoutDetails_ReturnsNoContent_WhenHangoutExists(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	cookie := storeSession(t, sessions, "creator")
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["creator"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/hangouts/"+id+"/details", `{"location":"there","duration_minutes":20,"date":"2025-03-16T10:00:00Z"}`)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected hangout details to be updated")
+	assert.Equal(t, "there", store.hangouts[0].Location)
+	assert.Equal(t, model.Minutes(20), store.hangouts[0].Duration)
+}
+
+func TestServer_UpdateHangoutDetails_ReturnsError_WhenNotTheCreator(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+	body := `{"location":"there","duration_minutes":20,"date":"2025-03-16T10:00:00Z"}`
+
+	rec = doRequest(t, srv, http.MethodPut, "/hangouts/"+id+"/details", body)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected the update to require a session")
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "other"), http.MethodPut, "/hangouts/"+id+"/details", body)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected only the creator to update the details")
+	assert.Equal(t, "not_hangout_creator", decodeErrorCode(t, rec))
+
+	assert.Equal(t, "here", store.hangouts[0].Location, "expected the details to be kept")
+}
+
+func TestServer_UpdateHangoutDetails_ReturnsNotFound_WhenHangoutDoesntExist(t *testing.T) {
+	srv, _, sessions := newTestServerWithSessions()
+
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPut, "/hangouts/"+uuid.NewString()+"/details", `{"location":"there","duration_minutes":20,"date":"2025-03-16T10:00:00Z"}`)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code, "expected unknown hangout to be reported")
+	assert.Equal(t, "not_found", decodeErrorCode(t, rec))
+}
+
+func TestServer_UpdateHangoutParticipants_ReturnsUnprocessable_WhenParticipantIsDeleted(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	cookie := storeSession(t, sessions, "creator")
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+	store.err = storage.ErrHangoutParticipantDeleted
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/hangouts/"+id+"/participants", `{"participants":["creator","deleted"]}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, "expected storage error to be mapped")
+	assert.Equal(t, "hangout_participant_deleted", decodeErrorCode(t, rec))
+}
+
+func TestServer_UpdateHangoutParticipants_ReturnsConflict_WhenParticipantsChanged(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	cookie := storeSession(t, sessions, "creator")
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+	store.err = storage.ErrHangoutParticipantsChanged
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/hangouts/"+id+"/participants", `{"participants":["creator"]}`)
+
+	assert.Equal(t, http.StatusConflict, rec.Code, "expected a concurrent change to be reported")
+	assert.Equal(t, "hangout_participants_changed", decodeErrorCode(t, rec))
+}
+
+func TestServer_UpdateHangoutParticipants_KeepsTheCreator(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	cookie := storeSession(t, sessions, "creator")
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/hangouts/"+id+"/participants", `{"participants":["another"]}`)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected participants to be updated")
+	assert.Equal(t, []model.IndividualId{"creator", "another"}, store.hangouts[0].Individuals)
+}
+
+func TestServer_UpdateHangoutParticipants_ReturnsError_WhenRemovalIsNotAllowed(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other","another"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+
+	rec = doRequest(t, srv, http.MethodPut, "/hangouts/"+id+"/participants", `{"participants":[]}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected the update to require a session")
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "other"), http.MethodPut, "/hangouts/"+id+"/participants", `{"participants":["other"]}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected participants to not remove each other")
+	assert.Equal(t, "not_hangout_creator", decodeErrorCode(t, rec))
+	assert.Equal(t, []model.IndividualId{"creator", "other", "another"}, store.hangouts[0].Individuals)
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "other"), http.MethodPut, "/hangouts/"+id+"/participants", `{"participants":["another"]}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected participants to be able to leave")
+	assert.Equal(t, []model.IndividualId{"creator", "another"}, store.hangouts[0].Individuals)
+}
+
+func TestServer_GetHangout_ReturnsHangout_WithDeletedParticipants(t *testing.T) {
+	srv, store := newTestServer()
+	hangout := model.Hangout{
+		PublicId: model.HangoutId(uuid.New()),
+		HangoutDetails: model.HangoutDetails{
+			Location: "here",
+			Duration: 10,
+			Date:     time.Date(2025, 3, 16, 10, 0, 0, 0, time.UTC),
+		},
+		CreatedBy:          "creator",
+		Individuals:        []model.IndividualId{"creator", "other"},
+		DeletedIndividuals: []model.IndividualId{"other"},
+	}
+	store.hangouts = append(store.hangouts, hangout)
+
+	rec := doRequest(t, srv, http.MethodGet, "/hangouts/"+uuid.UUID(hangout.PublicId).String(), "")
+
+	require.Equal(t, http.StatusOK, rec.Code, "expected hangout to be returned")
+	var resp hangoutResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected a hangout in the body")
+	assert.Equal(t, newHangoutResponse(hangout), resp)
+	assert.Equal(t, []string{"other"}, resp.DeletedParticipants)
+}
+
+func TestServer_GetHangout_ReturnsNotFound_WhenHangoutDoesntExist(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodGet, "/hangouts/"+uuid.NewString(), "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code, "expected unknown hangout to be reported")
+}
+
+func TestServer_DeleteHangout_ByCreator_CanBeRestored(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+	cookie := storeSession(t, sessions, "creator")
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodDelete, "/hangouts/"+id, "")
+	require.Equal(t, http.StatusNoContent, rec.Code, "expected hangout to be deleted")
+	rec = doRequest(t, srv, http.MethodGet, "/hangouts/"+id, "")
+	assert.Equal(t, http.StatusGone, rec.Code, "expected deleted hangout to not be returned")
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPost, "/hangouts/"+id+"/restore", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, "expected hangout to be restored")
+	rec = doRequest(t, srv, http.MethodGet, "/hangouts/"+id, "")
+	assert.Equal(t, http.StatusOK, rec.Code, "expected restored hangout to be returned")
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPost, "/hangouts/"+id+"/restore", "")
+	assert.Equal(t, http.StatusConflict, rec.Code, "expected hangout that is not deleted to not be restored")
+	assert.Equal(t, "not_deleted", decodeErrorCode(t, rec))
+}
+
+func TestServer_DeleteHangout_ByParticipant_ReturnsForbidden(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "other"), http.MethodDelete, "/hangouts/"+id, "")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected only the creator to delete the hangout")
+	assert.Equal(t, "not_hangout_creator", decodeErrorCode(t, rec))
+	assert.Empty(t, store.removed, "expected hangout to be kept")
+}
+
+func TestServer_DeleteHangout_ReturnsUnauthorized_WithoutSession(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodDelete, "/hangouts/"+uuid.NewString(), "")
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected deletion to require a session")
+}
+
+func TestServer_RemoveHangoutParticipant_ParticipantLeaves(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other","another"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "other"), http.MethodDelete, "/hangouts/"+id+"/participants/other", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, "expected participant to leave")
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodDelete, "/hangouts/"+id+"/participants/another", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, "expected creator to remove participant")
+
+	assert.Equal(t, []model.IndividualId{"creator"}, store.hangouts[0].Individuals)
+}
+
+func TestServer_RemoveHangoutParticipant_ReturnsError_WhenNotAllowed(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	rec := doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodPost, "/hangouts", `{"location":"here","duration_minutes":10,"date":"2025-03-16T10:00:00Z","participants":["other","another"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected hangout to be created")
+	id := uuid.UUID(store.hangouts[0].PublicId).String()
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "other"), http.MethodDelete, "/hangouts/"+id+"/participants/another", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected participants to not remove each other")
+	assert.Equal(t, "not_hangout_creator", decodeErrorCode(t, rec))
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodDelete, "/hangouts/"+id+"/participants/creator", "")
+	assert.Equal(t, http.StatusConflict, rec.Code, "expected the creator to stay")
+	assert.Equal(t, "creator_not_removable", decodeErrorCode(t, rec))
+
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "creator"), http.MethodDelete, "/hangouts/"+id+"/participants/stranger", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "expected unknown participant to be reported")
+	assert.Equal(t, "not_participant", decodeErrorCode(t, rec))
+
+	assert.Equal(t, []model.IndividualId{"creator", "other", "another"}, store.hangouts[0].Individuals)
+}
+
+// addOrphanedHangout stores a hangout whose creator erased their account.
+func addOrphanedHangout(store *fakeStorage, participants ...model.IndividualId) string {
+	id := model.HangoutId(uuid.New())
+	store.hangouts = append(store.hangouts, model.Hangout{
+		PublicId:       id,
+		HangoutDetails: model.HangoutDetails{Location: "here", Duration: 10, Date: time.Date(2025, 3, 16, 10, 0, 0, 0, time.UTC)},
+		Individuals:    participants,
+	})
+	return uuid.UUID(id).String()
+}
+
+func TestServer_OrphanedHangout_IsManagedByItsParticipants(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	id := addOrphanedHangout(store, "other", "another", "third")
+	cookie := storeSession(t, sessions, "other")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/hangouts/"+id+"/details", `{"location":"there","duration_minutes":20,"date":"2025-03-16T10:00:00Z"}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected a participant to update the details")
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/hangouts/"+id+"/participants", `{"participants":["other","another","fourth"]}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected a participant to update the participants")
+	assert.Equal(t, []model.IndividualId{"other", "another", "fourth"}, store.hangouts[0].Individuals, "expected no creator to be added")
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "another"), http.MethodDelete, "/hangouts/"+id+"/participants/another", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected a participant to leave")
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodDelete, "/hangouts/"+id+"/participants/fourth", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected a participant to remove another")
+	assert.Equal(t, []model.IndividualId{"other"}, store.hangouts[0].Individuals)
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodDelete, "/hangouts/"+id, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected a participant to delete the hangout")
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPost, "/hangouts/"+id+"/restore", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected a participant to restore the hangout")
+}
+
+func TestServer_OrphanedHangout_ReturnsForbidden_ForStrangers(t *testing.T) {
+	srv, store, sessions := newTestServerWithSessions()
+	id := addOrphanedHangout(store, "other", "another")
+	cookie := storeSession(t, sessions, "stranger")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/hangouts/"+id+"/details", `{"location":"there","duration_minutes":20,"date":"2025-03-16T10:00:00Z"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected strangers to not update the details")
+	assert.Equal(t, "not_hangout_participant", decodeErrorCode(t, rec))
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/hangouts/"+id+"/participants", `{"participants":["other"]}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected strangers to not remove participants")
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodDelete, "/hangouts/"+id+"/participants/other", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected strangers to not remove participants")
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodDelete, "/hangouts/"+id, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected strangers to not delete the hangout")
+
+	assert.Equal(t, []model.IndividualId{"other", "another"}, store.hangouts[0].Individuals)
+	assert.Equal(t, "here", store.hangouts[0].Location)
+	assert.Empty(t, store.removed, "expected the hangout to not be deleted")
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/aggregate"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type sessionResponse struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req loginRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	sesh, err := s.auth.Login(ctx, w, model.IndividualId(req.Username), req.Password)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	s.writeJSON(ctx, w, http.StatusCreated, sessionResponse{
+		Username:  string(sesh.UserID),
+		CreatedAt: sesh.CreatedAt,
+	})
+}
+
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req changePasswordRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if err := s.auth.ChangePassword(w, r, userId, req.CurrentPassword, req.NewPassword); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRestoreAccount logs in an individual that deleted their account
+// during the restore period, restoring the account.
+func (s *Server) handleRestoreAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req loginRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	sesh, err := s.auth.RestoreAccount(ctx, w, model.IndividualId(req.Username), req.Password)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	s.writeJSON(ctx, w, http.StatusCreated, sessionResponse{
+		Username:  string(sesh.UserID),
+		CreatedAt: sesh.CreatedAt,
+	})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := s.sessions.Logout(w, r); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleLogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if err := s.sessions.LogoutEverywhere(ctx, w, userId); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type magicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+type exchangeMagicLinkRequest struct {
+	Token string `json:"token"`
+}
+
+func (s *Server) handleRequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req magicLinkRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+	email, err := model.NewEmail(req.Email)
+	if err != nil {
+		s.writeError(ctx, w, fmt.Errorf("%w: %w", aggregate.ErrInvalidEmail, err))
+		return
+	}
+
+	if err := s.magicLinks.RequestMagicLink(ctx, email); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	// the same response whether the email has an account or not
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// The link in the email points to a frontend page which posts the token here.
+// Exchanging on a GET would let link previews and scanners consume the token.
+func (s *Server) handleExchangeMagicLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req exchangeMagicLinkRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	sesh, err := s.magicLinks.ExchangeMagicLink(ctx, w, req.Token)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	s.writeJSON(ctx, w, http.StatusCreated, sessionResponse{
+		Username:  string(sesh.UserID),
+		CreatedAt: sesh.CreatedAt,
+	})
+}
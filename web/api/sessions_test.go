@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Logout_ReturnsUnauthorized_WithoutSession(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rec := doRequest(t, srv, http.MethodDelete, "/sessions/current", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected logout to require a session")
+}
+
+func TestServer_Logout_RemovesCurrentSession(t *testing.T) {
+	srv, _, sessions := newTestServerWithSessions()
+	cookie := storeSession(t, sessions, "username")
+	other := storeSession(t, sessions, "username")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodDelete, "/sessions/current", "")
+
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected logout to succeed")
+	assert.NotContains(t, sessions.sessions, cookie.Value, "expected current session to be removed")
+	assert.Contains(t, sessions.sessions, other.Value, "expected other sessions to be kept")
+}
+
+func TestServer_LogoutEverywhere_RemovesAllSessionsOfTheUser(t *testing.T) {
+	srv, _, sessions := newTestServerWithSessions()
+	cookie := storeSession(t, sessions, "username")
+	storeSession(t, sessions, "username")
+	other := storeSession(t, sessions, "other")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodDelete, "/sessions", "")
+
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected logout everywhere to succeed")
+	assert.Len(t, sessions.sessions, 1, "expected only the other user's session to remain")
+	assert.Contains(t, sessions.sessions, other.Value)
+}
+
+func TestServer_Login_SetsSessionCookie_WhenCredentialsAreValid(t *testing.T) {
+	srv, _, sessions := newTestServerWithSessions()
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", `{"name":"name","email":"test@example.com","username":"username","password":"correct horse battery staple"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected individual to be created")
+
+	rec = doRequest(t, srv, http.MethodPost, "/sessions", `{"username":"username","password":"correct horse battery staple"}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code, "expected login to succeed")
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1, "expected a session cookie")
+	assert.Contains(t, sessions.sessions, cookies[0].Value, "expected session to be stored")
+}
+
+func TestServer_Login_ReturnsUnauthorized_WhenPasswordIsWrong(t *testing.T) {
+	srv, _ := newTestServer()
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", `{"name":"name","email":"test@example.com","username":"username","password":"correct horse battery staple"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected individual to be created")
+
+	rec = doRequest(t, srv, http.MethodPost, "/sessions", `{"username":"username","password":"wrong horse battery staple"}`)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected login to fail")
+	assert.Equal(t, "invalid_credentials", decodeErrorCode(t, rec))
+}
+
+func TestServer_ChangePassword_RequiresCurrentPassword(t *testing.T) {
+	srv, _, sessions := newTestServerWithSessions()
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", `{"name":"name","email":"test@example.com","username":"username","password":"correct horse battery staple"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected individual to be created")
+	cookie := storeSession(t, sessions, "username")
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/account/password", `{"current_password":"wrong horse battery staple","new_password":"another long passphrase"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected wrong current password to be rejected")
+
+	rec = doAuthenticatedRequest(t, srv, cookie, http.MethodPut, "/account/password", `{"current_password":"correct horse battery staple","new_password":"another long passphrase"}`)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "expected password to be changed")
+	assert.NotContains(t, sessions.sessions, cookie.Value, "expected the session to be rotated")
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1, "expected a session cookie")
+	assert.Contains(t, sessions.sessions, cookies[0].Value, "expected the rotated session to be stored")
+}
+
+func TestServer_RestoreAccount_ReenablesDeletedAccount(t *testing.T) {
+	srv, _, sessions := newTestServerWithSessions()
+	rec := doRequest(t, srv, http.MethodPost, "/individuals", `{"name":"name","email":"test@example.com","username":"username","password":"correct horse battery staple"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, "expected individual to be created")
+	rec = doAuthenticatedRequest(t, srv, storeSession(t, sessions, "username"), http.MethodDelete, "/individuals/username", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, "expected individual to be deleted")
+
+	rec = doRequest(t, srv, http.MethodPost, "/sessions", `{"username":"username","password":"correct horse battery staple"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected deleted individual to not log in")
+
+	rec = doRequest(t, srv, http.MethodPost, "/account/restore", `{"username":"username","password":"wrong horse battery staple"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected wrong password to be rejected")
+
+	rec = doRequest(t, srv, http.MethodPost, "/account/restore", `{"username":"username","password":"correct horse battery staple"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, "expected account to be restored")
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1, "expected a session cookie")
+	assert.Contains(t, sessions.sessions, cookies[0].Value, "expected session to be stored")
+
+	rec = doRequest(t, srv, http.MethodGet, "/individuals/username", "")
+	assert.Equal(t, http.StatusOK, rec.Code, "expected restored individual to be returned")
+
+	rec = doRequest(t, srv, http.MethodPost, "/account/restore", `{"username":"username","password":"correct horse battery staple"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code, "expected active account to not be restored")
+	assert.Equal(t, "not_deleted", decodeErrorCode(t, rec))
+
+	rec = doRequest(t, srv, http.MethodPost, "/account/restore", `{"username":"username","password":"wrong horse battery staple"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected active account to not be told apart without the password")
+	assert.Equal(t, "invalid_credentials", decodeErrorCode(t, rec))
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/stats"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+type pairStatsResponse struct {
+	Username string `json:"username"`
+	Deleted  bool   `json:"deleted,omitempty"`
+	Hangouts int    `json:"hangouts"`
+	Minutes  int    `json:"minutes"`
+}
+
+type statsResponse struct {
+	Window      string              `json:"window"`
+	Individuals []pairStatsResponse `json:"individuals"`
+}
+
+func (s *Server) handleGetPairStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	username := model.IndividualId(r.PathValue("username"))
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if username != userId {
+		s.writeError(ctx, w, ErrNotAccountOwner)
+		return
+	}
+
+	window := stats.WINDOW_ALL_TIME
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		parsed, err := stats.ParseWindow(raw)
+		if err != nil {
+			s.writeError(ctx, w, err)
+			return
+		}
+		window = parsed
+	}
+
+	pairs, err := s.stats.PairStats(ctx, username, window)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	resp := statsResponse{
+		Window:      string(window),
+		Individuals: make([]pairStatsResponse, 0, len(pairs)),
+	}
+	for _, pair := range pairs {
+		resp.Individuals = append(resp.Individuals, pairStatsResponse{
+			Username: string(pair.With),
+			Deleted:  pair.Deleted,
+			Hangouts: pair.Hangouts,
+			Minutes:  int(pair.Minutes),
+		})
+	}
+	s.writeJSON(ctx, w, http.StatusOK, resp)
+}
+
+type pairStreakResponse struct {
+	Username      string `json:"username"`
+	Deleted       bool   `json:"deleted,omitempty"`
+	CurrentStreak int    `json:"current_streak_weeks"`
+	LongestStreak int    `json:"longest_streak_weeks"`
+	DaysSinceLast int    `json:"days_since_last"`
+}
+
+type streaksResponse struct {
+	Individuals []pairStreakResponse `json:"individuals"`
+}
+
+type reminderResponse struct {
+	Username string    `json:"username"`
+	LastSeen time.Time `json:"last_seen"`
+	Hangouts int       `json:"hangouts"`
+}
+
+type remindersResponse struct {
+	Days        int                `json:"days"`
+	Individuals []reminderResponse `json:"individuals"`
+}
+
+func (s *Server) handleGetStreaks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	username := model.IndividualId(r.PathValue("username"))
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if username != userId {
+		s.writeError(ctx, w, ErrNotAccountOwner)
+		return
+	}
+
+	streaks, err := s.stats.Streaks(ctx, username)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	resp := streaksResponse{
+		Individuals: make([]pairStreakResponse, 0, len(streaks)),
+	}
+	for _, streak := range streaks {
+		resp.Individuals = append(resp.Individuals, pairStreakResponse{
+			Username:      string(streak.With),
+			Deleted:       streak.Deleted,
+			CurrentStreak: streak.CurrentStreak,
+			LongestStreak: streak.LongestStreak,
+			DaysSinceLast: streak.DaysSinceLast,
+		})
+	}
+	s.writeJSON(ctx, w, http.StatusOK, resp)
+}
+
+func (s *Server) handleGetReminders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	username := model.IndividualId(r.PathValue("username"))
+
+	// always set by the authentication middleware
+	userId, _ := session.UserFromContext(ctx)
+	if username != userId {
+		s.writeError(ctx, w, ErrNotAccountOwner)
+		return
+	}
+
+	days := DEFAULT_REMINDER_DAYS
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			s.writeError(ctx, w, fmt.Errorf("%w: days must be a number", ErrInvalidRequest))
+			return
+		}
+		days = parsed
+	}
+
+	reminders, err := s.stats.Reminders(ctx, username, days)
+	if err != nil {
+		s.writeError(ctx, w, err)
+		return
+	}
+
+	resp := remindersResponse{
+		Days:        days,
+		Individuals: make([]reminderResponse, 0, len(reminders)),
+	}
+	for _, reminder := range reminders {
+		resp.Individuals = append(resp.Individuals, reminderResponse{
+			Username: string(reminder.With),
+			LastSeen: reminder.LastSeen,
+			Hangouts: reminder.Hangouts,
+		})
+	}
+	s.writeJSON(ctx, w, http.StatusOK, resp)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_GetPairStats_ReturnsTimeSpentWithEachIndividual(t *testing.T) {
+	// alice sees bob on odd hangouts and carol on even ones
+	srv, _, sessions := newTimelineServer(t, 1, 2, 3)
+	cookie := storeSession(t, sessions, "alice")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/stats", "")
+
+	require.Equal(t, http.StatusOK, rec.Code, "expected statistics to be returned")
+	var resp statsResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected statistics in the body")
+	assert.Equal(t, statsResponse{
+		Window: "all",
+		Individuals: []pairStatsResponse{
+			{Username: "bob", Hangouts: 2, Minutes: 120},
+			{Username: "carol", Hangouts: 1, Minutes: 60},
+		},
+	}, resp)
+}
+
+func TestServer_GetPairStats_DoesNotCountPlannedHangouts(t *testing.T) {
+	srv, store, sessions := newTimelineServer(t)
+	cookie := storeSession(t, sessions, "alice")
+	err := store.StoreHangoutOfIndividuals(t.Context(), model.Hangout{
+		PublicId: model.HangoutId(uuid.New()),
+		HangoutDetails: model.HangoutDetails{
+			Location: "later",
+			Duration: 60,
+			Date:     time.Now().Add(24 * time.Hour),
+		},
+		CreatedBy:   "alice",
+		Individuals: []model.IndividualId{"alice", "bob"},
+	})
+	require.NoError(t, err, "expected no error when storing hangout")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/stats?window=month", "")
+
+	require.Equal(t, http.StatusOK, rec.Code, "expected statistics to be returned")
+	var resp statsResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected statistics in the body")
+	assert.Equal(t, "month", resp.Window)
+	assert.Empty(t, resp.Individuals, "expected future hangouts to not be counted")
+}
+
+func TestServer_GetPairStats_ReturnsBadRequest_WhenWindowIsUnknown(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t)
+	cookie := storeSession(t, sessions, "alice")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/stats?window=week", "")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "expected unknown window to be rejected")
+	assert.Equal(t, "unknown_window", decodeErrorCode(t, rec))
+}
+
+func TestServer_GetPairStats_ReturnsForbidden_WhenAskingForSomeoneElse(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t, 1, 2, 3)
+	cookie := storeSession(t, sessions, "bob")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/stats", "")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected the statistics of someone else to be hidden")
+	assert.Equal(t, "not_account_owner", decodeErrorCode(t, rec))
+}
+
+func TestServer_GetStreaks_ReturnsStreakWithEachIndividual(t *testing.T) {
+	// bob is seen on the 1st, 3rd and 17th, carol on the 2nd and 10th
+	srv, _, sessions := newTimelineServer(t, 1, 2, 3, 10, 17)
+	cookie := storeSession(t, sessions, "alice")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/streaks", "")
+
+	require.Equal(t, http.StatusOK, rec.Code, "expected streaks to be returned")
+	var resp streaksResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected streaks in the body")
+	require.Len(t, resp.Individuals, 2, "expected a streak per individual")
+	assert.Equal(t, "bob", resp.Individuals[0].Username)
+	assert.Equal(t, 2, resp.Individuals[0].LongestStreak, "expected the 1st and 3rd to be consecutive weeks")
+	assert.Equal(t, "carol", resp.Individuals[1].Username)
+	assert.Equal(t, 1, resp.Individuals[1].LongestStreak, "expected a week to be missed between the 2nd and 10th")
+}
+
+func TestServer_GetStreaks_ReturnsNotFound_WhenIndividualDoesntExist(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t)
+	// a session can outlive its individual
+	cookie := storeSession(t, sessions, "dave")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/dave/streaks", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code, "expected unknown individual to be reported")
+}
+
+func TestServer_GetStreaks_ReturnsForbidden_WhenAskingForSomeoneElse(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t, 1, 2, 3)
+	cookie := storeSession(t, sessions, "bob")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/streaks", "")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected the streaks of someone else to be hidden")
+	assert.Equal(t, "not_account_owner", decodeErrorCode(t, rec))
+}
+
+func TestServer_GetReminders_RanksByFrequency(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t, 1, 2, 3)
+	cookie := storeSession(t, sessions, "alice")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/reminders?days=60", "")
+
+	require.Equal(t, http.StatusOK, rec.Code, "expected reminders to be returned")
+	var resp remindersResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp), "expected reminders in the body")
+	assert.Equal(t, remindersResponse{
+		Days: 60,
+		Individuals: []reminderResponse{
+			{Username: "bob", LastSeen: time.Date(2025, 3, 3, 18, 0, 0, 0, time.UTC), Hangouts: 2},
+			{Username: "carol", LastSeen: time.Date(2025, 3, 2, 18, 0, 0, 0, time.UTC), Hangouts: 1},
+		},
+	}, resp)
+}
+
+func TestServer_GetReminders_ReturnsBadRequest_WhenDaysAreInvalid(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t)
+	cookie := storeSession(t, sessions, "alice")
+
+	for _, days := range []string{"0", "-1", "soon"} {
+		rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/reminders?days="+days, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "expected days %q to be rejected", days)
+	}
+}
+
+func TestServer_GetReminders_ReturnsForbidden_WhenAskingForSomeoneElse(t *testing.T) {
+	srv, _, sessions := newTimelineServer(t, 1, 2, 3)
+	cookie := storeSession(t, sessions, "bob")
+
+	rec := doAuthenticatedRequest(t, srv, cookie, http.MethodGet, "/individuals/alice/reminders", "")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code, "expected the reminders of someone else to be hidden")
+	assert.Equal(t, "not_account_owner", decodeErrorCode(t, rec))
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/aggregate"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+type CredentialStorage interface {
+	// StoreIndividualWithPassword creates the individual and its credentials
+	// atomically, so that there are no accounts nobody can log into.
+	StoreIndividualWithPassword(ctx context.Context, individual model.Individual, passwordHash string) error
+	GetPasswordHash(ctx context.Context, username model.IndividualId) (string, error)
+	UpdatePasswordHash(ctx context.Context, username model.IndividualId, passwordHash string) error
+	// GetDeletedPasswordHash is GetPasswordHash for individuals that deleted
+	// their account, it also returns when they did.
+	GetDeletedPasswordHash(ctx context.Context, username model.IndividualId) (string, time.Time, error)
+	// RestoreIndividual clears the deletion of an individual that was not
+	// anonymised yet.
+	RestoreIndividual(ctx context.Context, username model.IndividualId) error
+}
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+var ErrPasswordNotSet = errors.New("individual has no password")
+var ErrRestorePeriodOver = errors.New("account was deleted too long ago to be restored")
+
+type Service struct {
+	storage  CredentialStorage
+	hasher   Hasher
+	sessions *session.SessionManager
+	logger   *slog.Logger
+	// how long after the deletion an account can be restored
+	restorePeriod time.Duration
+
+	// verified when the individual doesn't exist, so that a failed login
+	// takes the same time whether the username exists or not
+	dummyHash string
+}
+
+func NewService(store CredentialStorage, hasher Hasher, sessions *session.SessionManager, restorePeriod time.Duration, logger *slog.Logger) (*Service, error) {
+	dummyHash, err := hasher.Hash("dummy password")
+	if err != nil {
+		return nil, fmt.Errorf("could not create dummy hash: %w", err)
+	}
+	return &Service{
+		storage:       store,
+		hasher:        hasher,
+		sessions:      sessions,
+		logger:        logger,
+		restorePeriod: restorePeriod,
+		dummyHash:     dummyHash,
+	}, nil
+}
+
+// Register creates an account for an already validated individual.
+func (s *Service) Register(ctx context.Context, individual model.Individual, password string) error {
+	if err := aggregate.ValidatePassword(password, individual.Username); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return s.storage.StoreIndividualWithPassword(ctx, individual, hash)
+}
+
+// verify returns the current hash of the individual if password matches it.
+func (s *Service) verify(ctx context.Context, username model.IndividualId, password string) (string, error) {
+	hash, err := s.storage.GetPasswordHash(ctx, username)
+	if err := s.checkPassword(ctx, username, password, hash, err); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// checkPassword verifies password against the hash found by the storage, or
+// against the dummy hash if there was none.
+func (s *Service) checkPassword(ctx context.Context, username model.IndividualId, password, hash string, lookupErr error) error {
+	if errors.Is(lookupErr, storage.ErrNotFound) || errors.Is(lookupErr, storage.ErrDeleted) || errors.Is(lookupErr, ErrPasswordNotSet) {
+		_ = VerifyPassword(password, s.dummyHash)
+		s.logger.DebugContext(ctx, "no password to verify", slog.String("username", string(username)), slog.Any("error", lookupErr))
+		return ErrInvalidCredentials
+	} else if lookupErr != nil {
+		return lookupErr
+	}
+
+	err := VerifyPassword(password, hash)
+	if errors.Is(err, ErrPasswordMismatch) {
+		return ErrInvalidCredentials
+	} else if err != nil {
+		s.logger.ErrorContext(ctx, "could not verify password", slog.String("username", string(username)), slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// Login verifies the password of the individual and starts a new session.
+func (s *Service) Login(ctx context.Context, w http.ResponseWriter, username model.IndividualId, password string) (session.Session, error) {
+	hash, err := s.verify(ctx, username, password)
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	// this is the only time the plaintext password is available, so upgrade
+	// hashes created with older parameters
+	if s.hasher.NeedsRehash(hash) {
+		if err := s.updatePassword(ctx, username, password); err != nil {
+			s.logger.WarnContext(ctx, "could not rehash password", slog.String("username", string(username)), slog.Any("error", err))
+		}
+	}
+
+	return s.sessions.Login(ctx, w, username)
+}
+
+func (s *Service) updatePassword(ctx context.Context, username model.IndividualId, password string) error {
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	return s.storage.UpdatePasswordHash(ctx, username, hash)
+}
+
+// ChangePassword replaces the password of the authenticated individual and
+// revokes all the other sessions, in case the old password was compromised.
+// The session of the current client is rotated.
+func (s *Service) ChangePassword(w http.ResponseWriter, r *http.Request, username model.IndividualId, current, new string) error {
+	ctx := r.Context()
+	if _, err := s.verify(ctx, username, current); err != nil {
+		return err
+	}
+	if err := aggregate.ValidatePassword(new, username); err != nil {
+		return err
+	}
+
+	if err := s.updatePassword(ctx, username, new); err != nil {
+		return err
+	}
+
+	if _, err := s.sessions.RevokeOtherSessions(w, r, username); err != nil {
+		return fmt.Errorf("password changed but could not revoke sessions: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "password changed", slog.String("username", string(username)))
+	return nil
+}
+
+// RestoreAccount verifies the password of an individual that deleted their
+// account and restores it, if the restore period is not over. The sessions
+// open at the time of the deletion work again, and the client gets a new one.
+//
+// The password is verified before anything else is reported, so that the
+// response does not tell active accounts apart from unknown ones.
+func (s *Service) RestoreAccount(ctx context.Context, w http.ResponseWriter, username model.IndividualId, password string) (session.Session, error) {
+	hash, deletedAt, err := s.storage.GetDeletedPasswordHash(ctx, username)
+	if errors.Is(err, storage.ErrNotDeleted) {
+		if _, err := s.verify(ctx, username, password); err != nil {
+			return session.Session{}, err
+		}
+		return session.Session{}, storage.ErrNotDeleted
+	}
+	if err := s.checkPassword(ctx, username, password, hash, err); err != nil {
+		return session.Session{}, err
+	}
+
+	if time.Since(deletedAt) > s.restorePeriod {
+		return session.Session{}, ErrRestorePeriodOver
+	}
+	if err := s.storage.RestoreIndividual(ctx, username); err != nil {
+		return session.Session{}, err
+	}
+
+	s.logger.InfoContext(ctx, "account restored", slog.String("username", string(username)))
+	return s.sessions.Login(ctx, w, username)
+}
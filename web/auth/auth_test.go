@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/aggregate"
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCredentialStorage struct {
+	hashes map[model.IndividualId]string
+	// when the individuals deleted their account
+	deleted map[model.IndividualId]time.Time
+}
+
+func (f *fakeCredentialStorage) StoreIndividualWithPassword(_ context.Context, individual model.Individual, passwordHash string) error {
+	if _, ok := f.hashes[individual.Username]; ok {
+		return storage.ErrIndividualUsernameAlreadyExists
+	}
+	f.hashes[individual.Username] = passwordHash
+	return nil
+}
+
+func (f *fakeCredentialStorage) GetPasswordHash(_ context.Context, username model.IndividualId) (string, error) {
+	hash, ok := f.hashes[username]
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	if _, ok := f.deleted[username]; ok {
+		return "", storage.ErrDeleted
+	}
+	return hash, nil
+}
+
+func (f *fakeCredentialStorage) GetDeletedPasswordHash(_ context.Context, username model.IndividualId) (string, time.Time, error) {
+	hash, ok := f.hashes[username]
+	if !ok {
+		return "", time.Time{}, storage.ErrNotFound
+	}
+	deletedAt, ok := f.deleted[username]
+	if !ok {
+		return "", time.Time{}, storage.ErrNotDeleted
+	}
+	return hash, deletedAt, nil
+}
+
+func (f *fakeCredentialStorage) RestoreIndividual(_ context.Context, username model.IndividualId) error {
+	delete(f.deleted, username)
+	return nil
+}
+
+func (f *fakeCredentialStorage) UpdatePasswordHash(_ context.Context, username model.IndividualId, passwordHash string) error {
+	f.hashes[username] = passwordHash
+	return nil
+}
+
+type fakeSessionStorage struct {
+	sessions map[string]session.Session
+}
+
+func (f *fakeSessionStorage) StoreSession(_ context.Context, s session.Session) error {
+	f.sessions[s.CookieValue] = s
+	return nil
+}
+
+func (f *fakeSessionStorage) GetSession(_ context.Context, cookie string) (session.Session, error) {
+	s, ok := f.sessions[cookie]
+	if !ok {
+		return session.Session{}, session.ErrNotFound
+	}
+	return s, nil
+}
+
+func (f *fakeSessionStorage) UpdateLastAccessed(_ context.Context, cookie string, lastAccessed time.Time) error {
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteSession(_ context.Context, cookie string) error {
+	delete(f.sessions, cookie)
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteSessionsOfUser(_ context.Context, userId model.IndividualId) (int64, error) {
+	var removed int64
+	for cookie, s := range f.sessions {
+		if s.UserID == userId {
+			delete(f.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (f *fakeSessionStorage) DeleteOtherSessionsOfUser(_ context.Context, userId model.IndividualId, keepCookie string) (int64, error) {
+	var removed int64
+	for cookie, s := range f.sessions {
+		if s.UserID == userId && cookie != keepCookie {
+			delete(f.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (f *fakeSessionStorage) RotateSession(_ context.Context, oldCookie string, newSession session.Session) error {
+	delete(f.sessions, oldCookie)
+	f.sessions[newSession.CookieValue] = newSession
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteExpiredSessions(_ context.Context, idleBefore, createdBefore time.Time, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeSessionStorage) CountActiveSessions(_ context.Context, idleAfter, createdAfter time.Time) (int64, error) {
+	return 0, nil
+}
+
+const TEST_RESTORE_PERIOD = 30 * 24 * time.Hour
+
+func newTestService(t *testing.T, hasher Hasher) (*Service, *fakeCredentialStorage, *fakeSessionStorage) {
+	t.Helper()
+	credentials := &fakeCredentialStorage{
+		hashes:  make(map[model.IndividualId]string),
+		deleted: make(map[model.IndividualId]time.Time),
+	}
+	sessions := &fakeSessionStorage{sessions: make(map[string]session.Session)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager := session.NewSessionManager(sessions, 30*time.Minute, 24*time.Hour, session.SESSION_COOKIE_NAME, logger)
+
+	s, err := NewService(credentials, hasher, manager, TEST_RESTORE_PERIOD, logger)
+	require.NoError(t, err, "expected no error when creating the service")
+	return s, credentials, sessions
+}
+
+func requestWithSession(sesh session.Session) *http.Request {
+	req := httptest.NewRequest(http.MethodPut, "/", nil)
+	req.AddCookie(&http.Cookie{Name: session.SESSION_COOKIE_NAME, Value: sesh.CookieValue})
+	return req
+}
+
+func cheapHasher() Hasher {
+	return &Argon2idHasher{Time: 1, MemoryKiB: 64, Threads: 1}
+}
+
+func TestRegister_ReturnsError_WhenPasswordViolatesPolicy(t *testing.T) {
+	s, credentials, _ := newTestService(t, cheapHasher())
+
+	err := s.Register(t.Context(), model.Individual{Username: "username"}, "short")
+	assert.ErrorIs(t, err, aggregate.ErrPasswordTooShort, "expected policy violation")
+	assert.Empty(t, credentials.hashes, "expected nothing to be stored")
+}
+
+func TestLogin_CreatesSession_WhenPasswordMatches(t *testing.T) {
+	s, _, sessions := newTestService(t, cheapHasher())
+	err := s.Register(t.Context(), model.Individual{Username: "username"}, "correct horse battery staple")
+	require.NoError(t, err, "expected no error when registering")
+
+	sesh, err := s.Login(t.Context(), httptest.NewRecorder(), "username", "correct horse battery staple")
+	require.NoError(t, err, "expected no error when logging in")
+	assert.Contains(t, sessions.sessions, sesh.CookieValue, "expected a session to be stored")
+}
+
+func TestLogin_ReturnsSameError_ForWrongPasswordAndUnknownUser(t *testing.T) {
+	s, _, sessions := newTestService(t, cheapHasher())
+	err := s.Register(t.Context(), model.Individual{Username: "username"}, "correct horse battery staple")
+	require.NoError(t, err, "expected no error when registering")
+
+	_, err = s.Login(t.Context(), httptest.NewRecorder(), "username", "wrong horse battery staple")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "expected wrong password to be rejected")
+
+	_, err = s.Login(t.Context(), httptest.NewRecorder(), "unknown", "correct horse battery staple")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "expected unknown user to be rejected the same way")
+
+	assert.Empty(t, sessions.sessions, "expected no session to be created")
+}
+
+func TestLogin_RehashesPassword_WhenParametersChanged(t *testing.T) {
+	old, credentials, _ := newTestService(t, cheapHasher())
+	err := old.Register(t.Context(), model.Individual{Username: "username"}, "correct horse battery staple")
+	require.NoError(t, err, "expected no error when registering")
+	oldHash := credentials.hashes["username"]
+
+	upgraded := &Argon2idHasher{Time: 2, MemoryKiB: 64, Threads: 1}
+	s, err := NewService(credentials, upgraded, old.sessions, old.restorePeriod, old.logger)
+	require.NoError(t, err, "expected no error when creating the service")
+
+	_, err = s.Login(t.Context(), httptest.NewRecorder(), "username", "correct horse battery staple")
+	require.NoError(t, err, "expected no error when logging in")
+
+	assert.NotEqual(t, oldHash, credentials.hashes["username"], "expected hash to be replaced")
+	assert.False(t, upgraded.NeedsRehash(credentials.hashes["username"]), "expected hash to use the new parameters")
+}
+
+func TestChangePassword_ReplacesPasswordAndRevokesOtherSessions(t *testing.T) {
+	s, _, sessions := newTestService(t, cheapHasher())
+	err := s.Register(t.Context(), model.Individual{Username: "username"}, "correct horse battery staple")
+	require.NoError(t, err, "expected no error when registering")
+	current, err := s.Login(t.Context(), httptest.NewRecorder(), "username", "correct horse battery staple")
+	require.NoError(t, err, "expected no error when logging in")
+	for range 2 {
+		_, err := s.Login(t.Context(), httptest.NewRecorder(), "username", "correct horse battery staple")
+		require.NoError(t, err, "expected no error when logging in")
+	}
+
+	err = s.ChangePassword(httptest.NewRecorder(), requestWithSession(current), "username", "correct horse battery staple", "another long passphrase")
+	require.NoError(t, err, "expected no error when changing password")
+
+	assert.Len(t, sessions.sessions, 1, "expected only the rotated session of the current client to remain")
+	assert.NotContains(t, sessions.sessions, current.CookieValue, "expected the current session to be rotated")
+	_, err = s.Login(t.Context(), httptest.NewRecorder(), "username", "correct horse battery staple")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "expected old password to be rejected")
+	_, err = s.Login(t.Context(), httptest.NewRecorder(), "username", "another long passphrase")
+	assert.NoError(t, err, "expected new password to be accepted")
+}
+
+func TestChangePassword_ReturnsError_WhenCurrentPasswordIsWrong(t *testing.T) {
+	s, _, _ := newTestService(t, cheapHasher())
+	err := s.Register(t.Context(), model.Individual{Username: "username"}, "correct horse battery staple")
+	require.NoError(t, err, "expected no error when registering")
+
+	err = s.ChangePassword(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/", nil), "username", "wrong horse battery staple", "another long passphrase")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "expected wrong current password to be rejected")
+}
+
+func TestRestoreAccount_RestoresAccount_WithinRestorePeriod(t *testing.T) {
+	s, credentials, sessions := newTestService(t, cheapHasher())
+	err := s.Register(t.Context(), model.Individual{Username: "username"}, "correct horse battery staple")
+	require.NoError(t, err, "expected no error when registering")
+	credentials.deleted["username"] = time.Now().Add(-TEST_RESTORE_PERIOD + time.Hour)
+
+	_, err = s.Login(t.Context(), httptest.NewRecorder(), "username", "correct horse battery staple")
+	require.ErrorIs(t, err, ErrInvalidCredentials, "expected deleted account to not log in")
+
+	sesh, err := s.RestoreAccount(t.Context(), httptest.NewRecorder(), "username", "correct horse battery staple")
+	require.NoError(t, err, "expected no error when restoring the account")
+	assert.NotContains(t, credentials.deleted, model.IndividualId("username"), "expected the account to be restored")
+	assert.Contains(t, sessions.sessions, sesh.CookieValue, "expected a session to be stored")
+}
+
+func TestRestoreAccount_ReturnsError_WhenRestoreIsNotAllowed(t *testing.T) {
+	s, credentials, sessions := newTestService(t, cheapHasher())
+	for _, username := range []model.IndividualId{"active", "expired", "deleted"} {
+		err := s.Register(t.Context(), model.Individual{Username: username}, "correct horse battery staple")
+		require.NoError(t, err, "expected no error when registering")
+	}
+	credentials.deleted["expired"] = time.Now().Add(-TEST_RESTORE_PERIOD - time.Hour)
+	credentials.deleted["deleted"] = time.Now()
+
+	_, err := s.RestoreAccount(t.Context(), httptest.NewRecorder(), "active", "correct horse battery staple")
+	assert.ErrorIs(t, err, storage.ErrNotDeleted, "expected active account to not be restored")
+	_, err = s.RestoreAccount(t.Context(), httptest.NewRecorder(), "active", "wrong horse battery staple")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "expected active account to be reported like unknown ones without the password")
+	_, err = s.RestoreAccount(t.Context(), httptest.NewRecorder(), "expired", "correct horse battery staple")
+	assert.ErrorIs(t, err, ErrRestorePeriodOver, "expected account deleted too long ago to not be restored")
+	_, err = s.RestoreAccount(t.Context(), httptest.NewRecorder(), "deleted", "wrong horse battery staple")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "expected wrong password to be rejected")
+	_, err = s.RestoreAccount(t.Context(), httptest.NewRecorder(), "unknown", "correct horse battery staple")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "expected unknown user to be rejected")
+
+	assert.Len(t, credentials.deleted, 2, "expected no account to be restored")
+	assert.Empty(t, sessions.sessions, "expected no session to be created")
+}
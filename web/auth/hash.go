@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Ozoniuss/hangcounts/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ARGON2ID_PREFIX = "$argon2id$"
+	ARGON2_SALT_LEN = 16
+	ARGON2_KEY_LEN  = 32
+)
+
+var ErrPasswordMismatch = errors.New("password does not match hash")
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Hasher produces self-describing password hashes, so that hashes created
+// with other parameters or algorithms can still be verified.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether encoded was produced with parameters other
+	// than the current ones, in which case it should be replaced on the next
+	// successful login.
+	NeedsRehash(encoded string) bool
+}
+
+// NewHasher returns the hasher configured by cfg.
+func NewHasher(cfg config.PasswordConfig) (Hasher, error) {
+	switch cfg.Algorithm {
+	case config.PASSWORD_ALGORITHM_ARGON2ID:
+		return &Argon2idHasher{
+			Time:      cfg.Argon2Time,
+			MemoryKiB: cfg.Argon2MemoryKiB,
+			Threads:   cfg.Argon2Threads,
+		}, nil
+	case config.PASSWORD_ALGORITHM_BCRYPT:
+		return &BcryptHasher{
+			Cost: cfg.BcryptCost,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown password hashing algorithm %q", cfg.Algorithm)
+}
+
+// VerifyPassword checks password against any hash produced by one of the
+// hashers, returning ErrPasswordMismatch if it doesn't match.
+func VerifyPassword(password, encoded string) error {
+	if strings.HasPrefix(encoded, ARGON2ID_PREFIX) {
+		return verifyArgon2id(password, encoded)
+	}
+	if _, err := bcrypt.Cost([]byte(encoded)); err == nil {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	}
+	return ErrUnknownHashFormat
+}
+
+type Argon2idHasher struct {
+	Time      uint32
+	MemoryKiB uint32
+	Threads   uint8
+}
+
+// Hash encodes the password in the PHC string format used by the reference
+// implementation, $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, ARGON2_SALT_LEN)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("could not generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.MemoryKiB, h.Threads, ARGON2_KEY_LEN)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		ARGON2ID_PREFIX,
+		argon2.Version,
+		h.MemoryKiB,
+		h.Time,
+		h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params != *h
+}
+
+func decodeArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2idHasher{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	var params Argon2idHasher
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Time, &params.Threads); err != nil {
+		return Argon2idHasher{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	return params, salt, key, nil
+}
+
+func verifyArgon2id(password, encoded string) error {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.MemoryKiB, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", fmt.Errorf("could not hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters, the tests only care about the encoding
+func testHashers() map[string]Hasher {
+	return map[string]Hasher{
+		"argon2id": &Argon2idHasher{Time: 1, MemoryKiB: 64, Threads: 1},
+		"bcrypt":   &BcryptHasher{Cost: bcrypt.MinCost},
+	}
+}
+
+func TestHasher_VerifiesItsOwnHashes(t *testing.T) {
+	for name, h := range testHashers() {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := h.Hash("correct horse battery staple")
+			require.NoError(t, err, "expected no error when hashing")
+
+			assert.NoError(t, VerifyPassword("correct horse battery staple", encoded), "expected password to match")
+			assert.ErrorIs(t, VerifyPassword("wrong horse battery staple", encoded), ErrPasswordMismatch, "expected wrong password to not match")
+			assert.False(t, h.NeedsRehash(encoded), "expected hash with current parameters to not need a rehash")
+		})
+	}
+}
+
+func TestHasher_ProducesDifferentHashesForTheSamePassword(t *testing.T) {
+	for name, h := range testHashers() {
+		t.Run(name, func(t *testing.T) {
+			first, err := h.Hash("password")
+			require.NoError(t, err, "expected no error when hashing")
+			second, err := h.Hash("password")
+			require.NoError(t, err, "expected no error when hashing")
+
+			assert.NotEqual(t, first, second, "expected hashes to be salted")
+		})
+	}
+}
+
+func TestArgon2idHasher_NeedsRehash_WhenParametersChange(t *testing.T) {
+	old := &Argon2idHasher{Time: 1, MemoryKiB: 64, Threads: 1}
+	encoded, err := old.Hash("password")
+	require.NoError(t, err, "expected no error when hashing")
+
+	current := &Argon2idHasher{Time: 2, MemoryKiB: 64, Threads: 1}
+	assert.True(t, current.NeedsRehash(encoded), "expected hash with old parameters to need a rehash")
+	assert.NoError(t, VerifyPassword("password", encoded), "expected old hash to still verify")
+}
+
+func TestArgon2idHasher_NeedsRehash_WhenAlgorithmChanges(t *testing.T) {
+	encoded, err := (&BcryptHasher{Cost: bcrypt.MinCost}).Hash("password")
+	require.NoError(t, err, "expected no error when hashing")
+
+	current := &Argon2idHasher{Time: 1, MemoryKiB: 64, Threads: 1}
+	assert.True(t, current.NeedsRehash(encoded), "expected bcrypt hash to need a rehash")
+}
+
+func TestVerifyPassword_ReturnsError_WhenHashFormatIsUnknown(t *testing.T) {
+	assert.ErrorIs(t, VerifyPassword("password", "plaintext"), ErrUnknownHashFormat)
+	assert.ErrorIs(t, VerifyPassword("password", "$argon2id$v=19$broken"), ErrUnknownHashFormat)
+}
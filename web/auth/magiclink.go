@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/mail"
+	"github.com/Ozoniuss/hangcounts/web/session"
+)
+
+type MagicLinkStorage interface {
+	// StoreMagicLink ties the token hash to the individual owning email, and
+	// returns that individual.
+	StoreMagicLink(ctx context.Context, email model.Email, tokenHash string, expiresAt time.Time) (model.IndividualId, error)
+	// ConsumeMagicLink marks the link as used and returns its individual. It
+	// must fail with ErrMagicLinkInvalid if the link is unknown, expired or
+	// was already used, even under concurrent calls.
+	ConsumeMagicLink(ctx context.Context, tokenHash string, now time.Time) (model.IndividualId, error)
+}
+
+var ErrMagicLinkInvalid = errors.New("magic link is invalid, expired or already used")
+
+// MAIL_SEND_TIMEOUT bounds the sending of a magic link, which is not tied to
+// the request anymore.
+const MAIL_SEND_TIMEOUT = 30 * time.Second
+
+type MagicLinkService struct {
+	storage  MagicLinkStorage
+	mailer   mail.Mailer
+	sessions *session.SessionManager
+	logger   *slog.Logger
+
+	ttl time.Duration
+	// the page the link points to, which exchanges the token for a session
+	linkURL *url.URL
+
+	// the links being sent in the background
+	sending sync.WaitGroup
+}
+
+func NewMagicLinkService(
+	store MagicLinkStorage,
+	mailer mail.Mailer,
+	sessions *session.SessionManager,
+	ttl time.Duration,
+	linkURL string,
+	logger *slog.Logger,
+) (*MagicLinkService, error) {
+	u, err := url.Parse(linkURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid magic link url: %w", err)
+	}
+	return &MagicLinkService{
+		storage:  store,
+		mailer:   mailer,
+		sessions: sessions,
+		logger:   logger,
+		ttl:      ttl,
+		linkURL:  u,
+	}, nil
+}
+
+// tokens are random and long enough that a fast unsalted hash is fine, this
+// only protects against leaks of the table
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestMagicLink emails a single use login link to the individual owning
+// email. Unknown emails are not reported, to not reveal who has an account.
+// For the same reason the email is sent in the background, so that neither
+// the response time nor a failing mailer tell known emails apart.
+func (s *MagicLinkService) RequestMagicLink(ctx context.Context, email model.Email) error {
+	// same generator as the session ids
+	token, err := session.GenerateSecureSessionId()
+	if err != nil {
+		return fmt.Errorf("could not generate magic link token: %w", err)
+	}
+
+	userId, err := s.storage.StoreMagicLink(ctx, email, hashToken(token), time.Now().Add(s.ttl))
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrDeleted) {
+		s.logger.DebugContext(ctx, "magic link requested for unknown email", slog.Any("error", err))
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	link := *s.linkURL
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	msg := mail.Message{
+		To:      string(email),
+		Subject: "Your hangcounts login link",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to log in. It expires in %s and works only once.\n\n%s\n\nIf you did not request it, you can ignore this email.\n",
+			userId, s.ttl, link.String()),
+	}
+	s.sending.Add(1)
+	go func() {
+		defer s.sending.Done()
+		s.send(context.WithoutCancel(ctx), userId, msg)
+	}()
+	return nil
+}
+
+// send only logs failures, the client was already answered.
+func (s *MagicLinkService) send(ctx context.Context, userId model.IndividualId, msg mail.Message) {
+	ctx, cancel := context.WithTimeout(ctx, MAIL_SEND_TIMEOUT)
+	defer cancel()
+
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.logger.ErrorContext(ctx, "could not send magic link", slog.String("username", string(userId)), slog.Any("error", err))
+		return
+	}
+	s.logger.InfoContext(ctx, "sent magic link", slog.String("username", string(userId)))
+}
+
+// Wait blocks until the links being sent are sent, or failed to.
+func (s *MagicLinkService) Wait() {
+	s.sending.Wait()
+}
+
+// ExchangeMagicLink consumes the token and starts a session for its
+// individual.
+func (s *MagicLinkService) ExchangeMagicLink(ctx context.Context, w http.ResponseWriter, token string) (session.Session, error) {
+	userId, err := s.storage.ConsumeMagicLink(ctx, hashToken(token), time.Now())
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	return s.sessions.Login(ctx, w, userId)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/Ozoniuss/hangcounts/domain/storage"
+	"github.com/Ozoniuss/hangcounts/web/mail"
+	"github.com/Ozoniuss/hangcounts/web/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMagicLink struct {
+	userId    model.IndividualId
+	expiresAt time.Time
+	used      bool
+}
+
+type fakeMagicLinkStorage struct {
+	users map[model.Email]model.IndividualId
+	links map[string]*fakeMagicLink
+}
+
+func (f *fakeMagicLinkStorage) StoreMagicLink(_ context.Context, email model.Email, tokenHash string, expiresAt time.Time) (model.IndividualId, error) {
+	userId, ok := f.users[email]
+	if !ok {
+		return "", storage.ErrNotFound
+	}
+	f.links[tokenHash] = &fakeMagicLink{userId: userId, expiresAt: expiresAt}
+	return userId, nil
+}
+
+func (f *fakeMagicLinkStorage) ConsumeMagicLink(_ context.Context, tokenHash string, now time.Time) (model.IndividualId, error) {
+	link, ok := f.links[tokenHash]
+	if !ok || link.used || !now.Before(link.expiresAt) {
+		return "", ErrMagicLinkInvalid
+	}
+	link.used = true
+	return link.userId, nil
+}
+
+func newTestMagicLinkService(t *testing.T) (*MagicLinkService, *mail.InMemoryMailer, *fakeSessionStorage) {
+	t.Helper()
+	store := &fakeMagicLinkStorage{
+		users: map[model.Email]model.IndividualId{"test@example.com": "username"},
+		links: make(map[string]*fakeMagicLink),
+	}
+	mailer := mail.NewInMemoryMailer()
+	sessions := &fakeSessionStorage{sessions: make(map[string]session.Session)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager := session.NewSessionManager(sessions, 30*time.Minute, 24*time.Hour, session.SESSION_COOKIE_NAME, logger)
+
+	s, err := NewMagicLinkService(store, mailer, manager, 15*time.Minute, "https://hangcounts.test/login/magic", logger)
+	require.NoError(t, err, "expected no error when creating the service")
+	return s, mailer, sessions
+}
+
+// tokenFromMessage extracts the token from the link sent by email.
+func tokenFromMessage(t *testing.T, msg mail.Message) string {
+	t.Helper()
+	for _, word := range strings.Fields(msg.Body) {
+		u, err := url.Parse(word)
+		if err == nil && u.Query().Has("token") {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatal("expected message to contain a magic link")
+	return ""
+}
+
+func TestRequestMagicLink_SendsLinkThatCanBeExchangedOnce(t *testing.T) {
+	s, mailer, sessions := newTestMagicLinkService(t)
+
+	err := s.RequestMagicLink(t.Context(), "test@example.com")
+	require.NoError(t, err, "expected no error when requesting a magic link")
+	s.Wait()
+	messages := mailer.Messages()
+	require.Len(t, messages, 1, "expected a single email to be sent")
+	assert.Equal(t, "test@example.com", messages[0].To)
+	token := tokenFromMessage(t, messages[0])
+
+	sesh, err := s.ExchangeMagicLink(t.Context(), httptest.NewRecorder(), token)
+	require.NoError(t, err, "expected no error when exchanging the magic link")
+	assert.Equal(t, model.IndividualId("username"), sesh.UserID, "expected session of the email owner")
+	assert.Contains(t, sessions.sessions, sesh.CookieValue, "expected session to be stored")
+
+	_, err = s.ExchangeMagicLink(t.Context(), httptest.NewRecorder(), token)
+	assert.ErrorIs(t, err, ErrMagicLinkInvalid, "expected magic link to be single use")
+}
+
+func TestRequestMagicLink_SendsNothing_WhenEmailIsUnknown(t *testing.T) {
+	s, mailer, _ := newTestMagicLinkService(t)
+
+	err := s.RequestMagicLink(t.Context(), "unknown@example.com")
+	assert.NoError(t, err, "expected unknown emails to not be reported")
+	s.Wait()
+	assert.Empty(t, mailer.Messages(), "expected no email to be sent")
+}
+
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, mail.Message) error {
+	return errors.New("smtp relay unreachable")
+}
+
+func TestRequestMagicLink_ReturnsNoError_WhenSendingFails(t *testing.T) {
+	s, _, _ := newTestMagicLinkService(t)
+	s.mailer = failingMailer{}
+
+	err := s.RequestMagicLink(t.Context(), "test@example.com")
+	assert.NoError(t, err, "expected the failure to not be reported to the client")
+	s.Wait()
+}
+
+func TestExchangeMagicLink_ReturnsError_WhenTokenIsUnknown(t *testing.T) {
+	s, _, _ := newTestMagicLinkService(t)
+
+	_, err := s.ExchangeMagicLink(t.Context(), httptest.NewRecorder(), "unknown")
+	assert.ErrorIs(t, err, ErrMagicLinkInvalid, "expected unknown token to be rejected")
+}
@@ -0,0 +1,285 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// READY_CHECK_TIMEOUT bounds the checks of a readiness probe, which should
+// fail rather than hang when the database does not answer.
+const READY_CHECK_TIMEOUT = 2 * time.Second
+
+const (
+	CHECK_DATABASE   = "database"
+	CHECK_MIGRATIONS = "migrations"
+	CHECK_WORKERS    = "workers"
+	CHECK_SHUTDOWN   = "shutdown"
+)
+
+var ErrShuttingDown = errors.New("shutting down")
+
+type PoolStats struct {
+	TotalConns           int32         `json:"total_conns"`
+	AcquiredConns        int32         `json:"acquired_conns"`
+	IdleConns            int32         `json:"idle_conns"`
+	MaxConns             int32         `json:"max_conns"`
+	AcquireCount         int64         `json:"acquire_count"`
+	EmptyAcquireCount    int64         `json:"empty_acquire_count"`
+	CanceledAcquireCount int64         `json:"canceled_acquire_count"`
+	AcquireDuration      time.Duration `json:"acquire_duration_ns"`
+}
+
+type Database interface {
+	Ping(context.Context) error
+	PoolStats() PoolStats
+}
+
+type Migrations interface {
+	// Status returns the version of the database without waiting for running
+	// migrations.
+	Status(context.Context) (int64, bool, error)
+	// Latest is the version of the last embedded migration.
+	Latest() int64
+}
+
+// Checker reports whether the app can serve requests. Readiness depends on
+// the database, its migrations and the background workers, and fails once
+// the app is shutting down so that no new traffic is routed to it.
+type Checker struct {
+	db         Database
+	migrations Migrations
+	startedAt  time.Time
+	now        func() time.Time
+	logger     *slog.Logger
+
+	shuttingDown atomic.Bool
+
+	mu sync.Mutex
+	// the registered workers, and whether they are running
+	workers map[string]bool
+}
+
+func NewChecker(db Database, migrations Migrations, logger *slog.Logger) *Checker {
+	return &Checker{
+		db:         db,
+		migrations: migrations,
+		startedAt:  time.Now(),
+		now:        time.Now,
+		logger:     logger,
+		workers:    make(map[string]bool),
+	}
+}
+
+// MarkShuttingDown makes the readiness probe fail from now on.
+func (c *Checker) MarkShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// RunWorker runs a background worker, which is expected to run until the app
+// stops. The app is not ready anymore if it returns early.
+func (c *Checker) RunWorker(name string, run func()) {
+	c.setWorker(name, true)
+	defer c.setWorker(name, false)
+	run()
+}
+
+func (c *Checker) setWorker(name string, running bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers[name] = running
+}
+
+func (c *Checker) workerStates() map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	states := make(map[string]bool, len(c.workers))
+	for name, running := range c.workers {
+		states[name] = running
+	}
+	return states
+}
+
+type Check struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func newCheck(name string, err error) Check {
+	if err != nil {
+		return Check{Name: name, Error: err.Error()}
+	}
+	return Check{Name: name, OK: true}
+}
+
+func (c *Checker) checkMigrations(ctx context.Context) error {
+	version, dirty, err := c.migrations.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("version %d is dirty", version)
+	}
+	if latest := c.migrations.Latest(); version != latest {
+		return fmt.Errorf("version %d, expected %d", version, latest)
+	}
+	return nil
+}
+
+func (c *Checker) checkWorkers() error {
+	var stopped []string
+	for name, running := range c.workerStates() {
+		if !running {
+			stopped = append(stopped, name)
+		}
+	}
+	if len(stopped) > 0 {
+		slices.Sort(stopped)
+		return fmt.Errorf("stopped: %v", stopped)
+	}
+	return nil
+}
+
+// Ready runs every check, even after one failed, to report them all.
+func (c *Checker) Ready(ctx context.Context) ([]Check, bool) {
+	ctx, cancel := context.WithTimeout(ctx, READY_CHECK_TIMEOUT)
+	defer cancel()
+
+	var shutdownErr error
+	if c.shuttingDown.Load() {
+		shutdownErr = ErrShuttingDown
+	}
+	checks := []Check{
+		newCheck(CHECK_SHUTDOWN, shutdownErr),
+		newCheck(CHECK_DATABASE, c.db.Ping(ctx)),
+		newCheck(CHECK_MIGRATIONS, c.checkMigrations(ctx)),
+		newCheck(CHECK_WORKERS, c.checkWorkers()),
+	}
+	ready := !slices.ContainsFunc(checks, func(check Check) bool {
+		return !check.OK
+	})
+	return checks, ready
+}
+
+// Register adds the probes and the status page to mux. They are not
+// authenticated, serve mux on the admin listener, which only the orchestrator
+// reaches.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", c.handleHealthz)
+	mux.HandleFunc("GET /readyz", c.handleReadyz)
+	mux.HandleFunc("GET /debug/status", c.handleStatus)
+}
+
+func (c *Checker) writeJSON(ctx context.Context, w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		c.logger.ErrorContext(ctx, "could not encode response", slog.Any("error", err))
+	}
+}
+
+type statusResponse struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks,omitempty"`
+}
+
+// handleHealthz only tells that the process serves requests, the orchestrator
+// restarts it otherwise.
+func (c *Checker) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	c.writeJSON(r.Context(), w, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func (c *Checker) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	checks, ready := c.Ready(ctx)
+	if !ready {
+		c.logger.WarnContext(ctx, "not ready", slog.Any("checks", checks))
+		c.writeJSON(ctx, w, http.StatusServiceUnavailable, statusResponse{Status: "not_ready", Checks: checks})
+		return
+	}
+	c.writeJSON(ctx, w, http.StatusOK, statusResponse{Status: "ready", Checks: checks})
+}
+
+type buildInfo struct {
+	GoVersion string `json:"go_version"`
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified"`
+}
+
+func readBuildInfo() buildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return buildInfo{}
+	}
+	b := buildInfo{
+		GoVersion: info.GoVersion,
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.time":
+			b.Time = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+type migrationsStatus struct {
+	Version  int64  `json:"version"`
+	Expected int64  `json:"expected"`
+	Dirty    bool   `json:"dirty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type debugStatus struct {
+	Build         buildInfo        `json:"build"`
+	StartedAt     time.Time        `json:"started_at"`
+	UptimeSeconds int64            `json:"uptime_seconds"`
+	Ready         bool             `json:"ready"`
+	Checks        []Check          `json:"checks"`
+	Workers       map[string]bool  `json:"workers"`
+	Migrations    migrationsStatus `json:"migrations"`
+	Pool          PoolStats        `json:"pool"`
+}
+
+func (c *Checker) handleStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	checks, ready := c.Ready(ctx)
+
+	migrations := migrationsStatus{Expected: c.migrations.Latest()}
+	version, dirty, err := c.migrations.Status(ctx)
+	if err != nil {
+		migrations.Error = err.Error()
+	} else {
+		migrations.Version, migrations.Dirty = version, dirty
+	}
+
+	c.writeJSON(ctx, w, http.StatusOK, debugStatus{
+		Build:         readBuildInfo(),
+		StartedAt:     c.startedAt,
+		UptimeSeconds: int64(c.now().Sub(c.startedAt).Seconds()),
+		Ready:         ready,
+		Checks:        checks,
+		Workers:       c.workerStates(),
+		Migrations:    migrations,
+		Pool:          c.db.PoolStats(),
+	})
+}
@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDatabase struct {
+	err error
+}
+
+func (f *fakeDatabase) Ping(context.Context) error {
+	return f.err
+}
+
+func (f *fakeDatabase) PoolStats() PoolStats {
+	return PoolStats{TotalConns: 3, MaxConns: 10}
+}
+
+type fakeMigrations struct {
+	version int64
+	dirty   bool
+	latest  int64
+}
+
+func (f *fakeMigrations) Status(context.Context) (int64, bool, error) {
+	return f.version, f.dirty, nil
+}
+
+func (f *fakeMigrations) Latest() int64 {
+	return f.latest
+}
+
+func newTestChecker(db *fakeDatabase, migrations *fakeMigrations) (*Checker, http.Handler) {
+	c := NewChecker(db, migrations, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mux := http.NewServeMux()
+	c.Register(mux)
+	return c, mux
+}
+
+func get(t *testing.T, handler http.Handler, path string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), "expected a json body")
+	return rec, body
+}
+
+func Test_Healthz_AlwaysReturnsOk(t *testing.T) {
+	c, handler := newTestChecker(&fakeDatabase{err: errors.New("down")}, &fakeMigrations{})
+	c.MarkShuttingDown()
+
+	rec, _ := get(t, handler, "/healthz")
+	assert.Equal(t, http.StatusOK, rec.Code, "expected the process to be alive")
+}
+
+func Test_Readyz_EverythingUp_ReturnsOk(t *testing.T) {
+	c, handler := newTestChecker(&fakeDatabase{}, &fakeMigrations{version: 3, latest: 3})
+	c.setWorker("reaper", true)
+
+	rec, body := get(t, handler, "/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code, "expected the app to be ready")
+	assert.Equal(t, "ready", body["status"])
+}
+
+func Test_Readyz_CheckFails_ReturnsServiceUnavailable(t *testing.T) {
+	tc := []struct {
+		name       string
+		db         *fakeDatabase
+		migrations *fakeMigrations
+		setup      func(*Checker)
+		failing    string
+	}{
+		{
+			name:       "database unreachable",
+			db:         &fakeDatabase{err: errors.New("connection refused")},
+			migrations: &fakeMigrations{version: 3, latest: 3},
+			failing:    CHECK_DATABASE,
+		},
+		{
+			name:       "migrations behind",
+			db:         &fakeDatabase{},
+			migrations: &fakeMigrations{version: 2, latest: 3},
+			failing:    CHECK_MIGRATIONS,
+		},
+		{
+			name:       "dirty database",
+			db:         &fakeDatabase{},
+			migrations: &fakeMigrations{version: 3, dirty: true, latest: 3},
+			failing:    CHECK_MIGRATIONS,
+		},
+		{
+			name:       "worker stopped",
+			db:         &fakeDatabase{},
+			migrations: &fakeMigrations{version: 3, latest: 3},
+			setup:      func(c *Checker) { c.RunWorker("reaper", func() {}) },
+			failing:    CHECK_WORKERS,
+		},
+		{
+			name:       "shutting down",
+			db:         &fakeDatabase{},
+			migrations: &fakeMigrations{version: 3, latest: 3},
+			setup:      func(c *Checker) { c.MarkShuttingDown() },
+			failing:    CHECK_SHUTDOWN,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			c, handler := newTestChecker(tt.db, tt.migrations)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+
+			checks, ready := c.Ready(t.Context())
+			assert.False(t, ready, "expected the app to not be ready")
+			for _, check := range checks {
+				assert.Equal(t, check.Name != tt.failing, check.OK, "expected only %s to fail, got %+v", tt.failing, check)
+			}
+
+			rec, body := get(t, handler, "/readyz")
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "expected the probe to fail")
+			assert.Equal(t, "not_ready", body["status"])
+		})
+	}
+}
+
+func Test_DebugStatus_ReturnsPoolStatsAndUptime(t *testing.T) {
+	c, handler := newTestChecker(&fakeDatabase{}, &fakeMigrations{version: 3, latest: 3})
+	c.now = func() time.Time { return c.startedAt.Add(90 * time.Second) }
+
+	rec, body := get(t, handler, "/debug/status")
+	assert.Equal(t, http.StatusOK, rec.Code, "expected the status page")
+	assert.Equal(t, float64(90), body["uptime_seconds"], "expected the uptime")
+	assert.Equal(t, true, body["ready"], "expected the readiness")
+	assert.Contains(t, body, "build", "expected the build info")
+	assert.Equal(t, map[string]any{
+		"total_conns":            float64(3),
+		"acquired_conns":         float64(0),
+		"idle_conns":             float64(0),
+		"max_conns":              float64(10),
+		"acquire_count":          float64(0),
+		"empty_acquire_count":    float64(0),
+		"canceled_acquire_count": float64(0),
+		"acquire_duration_ns":    float64(0),
+	}, body["pool"], "expected the pool stats")
+}
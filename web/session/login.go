@@ -0,0 +1,141 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+)
+
+func (m *SessionManager) setCookie(w http.ResponseWriter, sesh Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cookieName,
+		Value:    sesh.CookieValue,
+		Path:     "/",
+		Expires:  sesh.CreatedAt.Add(m.absoluteExpiration),
+		MaxAge:   int(time.Until(sesh.CreatedAt.Add(m.absoluteExpiration)).Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (m *SessionManager) clearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.cookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Login creates a new session for an already authenticated individual and
+// sets the session cookie on the response.
+func (m *SessionManager) Login(ctx context.Context, w http.ResponseWriter, userId model.IndividualId) (Session, error) {
+	sesh, err := NewSessionForUser(userId)
+	if err != nil {
+		return Session{}, err
+	}
+	if err := m.storage.StoreSession(ctx, sesh); err != nil {
+		return Session{}, fmt.Errorf("could not store session: %w", err)
+	}
+
+	m.setCookie(w, sesh)
+	m.logger.InfoContext(ctx, "user logged in", slog.String("username", string(userId)))
+	return sesh, nil
+}
+
+// Logout removes the session of the request and clears the cookie. A missing
+// or unknown session is not an error, the client ends up logged out either way.
+func (m *SessionManager) Logout(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	m.clearCookie(w)
+
+	cookie, err := r.Cookie(m.cookieName)
+	if err != nil {
+		return nil
+	}
+
+	err = m.storage.DeleteSession(ctx, cookie.Value)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("could not delete session: %w", err)
+	}
+	return nil
+}
+
+// LogoutEverywhere removes every session of the individual, including the one
+// of the current request.
+func (m *SessionManager) LogoutEverywhere(ctx context.Context, w http.ResponseWriter, userId model.IndividualId) error {
+	m.clearCookie(w)
+
+	removed, err := m.storage.DeleteSessionsOfUser(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("could not delete sessions of user: %w", err)
+	}
+
+	m.logger.InfoContext(ctx, "user logged out everywhere", slog.String("username", string(userId)), slog.Int64("sessions", removed))
+	return nil
+}
+
+// RotateSession gives the session of the request a new id. It must be called
+// whenever the privileges of the session change, so that a session id planted
+// before that (session fixation) becomes useless.
+//
+// The creation time is kept to not extend the absolute expiration.
+func (m *SessionManager) RotateSession(w http.ResponseWriter, r *http.Request) (Session, error) {
+	ctx := r.Context()
+
+	cookie, err := r.Cookie(m.cookieName)
+	if err != nil {
+		return Session{}, ErrNotFound
+	}
+
+	old, err := m.storage.GetSession(ctx, cookie.Value)
+	if err != nil {
+		return Session{}, fmt.Errorf("could not retrieve session: %w", err)
+	}
+
+	id, err := GenerateSecureSessionId()
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to generate a session id: %w", err)
+	}
+	rotated := Session{
+		CreatedAt:    old.CreatedAt,
+		LastAccessed: time.Now(),
+		CookieValue:  id,
+		UserID:       old.UserID,
+	}
+	if err := m.storage.RotateSession(ctx, old.CookieValue, rotated); err != nil {
+		return Session{}, fmt.Errorf("could not rotate session: %w", err)
+	}
+
+	m.setCookie(w, rotated)
+	m.logger.InfoContext(ctx, "rotated session", slog.String("username", string(rotated.UserID)))
+	return rotated, nil
+}
+
+// RevokeOtherSessions logs the individual out everywhere except on the
+// current client, whose session is rotated, e.g. after a password change.
+func (m *SessionManager) RevokeOtherSessions(w http.ResponseWriter, r *http.Request, userId model.IndividualId) (Session, error) {
+	ctx := r.Context()
+
+	rotated, err := m.RotateSession(w, r)
+	if err != nil {
+		return Session{}, err
+	}
+
+	removed, err := m.storage.DeleteOtherSessionsOfUser(ctx, userId, rotated.CookieValue)
+	if err != nil {
+		return Session{}, fmt.Errorf("could not delete sessions of user: %w", err)
+	}
+	m.logger.InfoContext(ctx, "revoked sessions", slog.String("username", string(userId)), slog.Int64("sessions", removed))
+
+	return rotated, nil
+}
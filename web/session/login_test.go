@@ -0,0 +1,126 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func responseCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1, "expected exactly one cookie to be set")
+	return cookies[0]
+}
+
+func requestWithCookie(value string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE_NAME, Value: value})
+	return req
+}
+
+func TestLogin_StoresSessionAndSetsSecureCookie(t *testing.T) {
+	store := newFakeSessionStorage()
+	m := newTestManager(store)
+	rec := httptest.NewRecorder()
+
+	sesh, err := m.Login(t.Context(), rec, "username")
+	require.NoError(t, err, "expected no error when logging in")
+
+	assert.Contains(t, store.sessions, sesh.CookieValue, "expected session to be stored")
+	cookie := responseCookie(t, rec)
+	assert.Equal(t, SESSION_COOKIE_NAME, cookie.Name)
+	assert.Equal(t, sesh.CookieValue, cookie.Value)
+	assert.True(t, cookie.Secure, "expected cookie to be secure")
+	assert.True(t, cookie.HttpOnly, "expected cookie to be http only")
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+}
+
+func TestLogout_DeletesSessionAndClearsCookie(t *testing.T) {
+	store := newFakeSessionStorage()
+	m := newTestManager(store)
+	sesh, err := m.Login(t.Context(), httptest.NewRecorder(), "username")
+	require.NoError(t, err, "expected no error when logging in")
+
+	rec := httptest.NewRecorder()
+	err = m.Logout(rec, requestWithCookie(sesh.CookieValue))
+	require.NoError(t, err, "expected no error when logging out")
+
+	assert.NotContains(t, store.sessions, sesh.CookieValue, "expected session to be deleted")
+	assert.Less(t, responseCookie(t, rec).MaxAge, 0, "expected cookie to be cleared")
+}
+
+func TestLogout_ReturnsNoError_WhenSessionDoesntExist(t *testing.T) {
+	m := newTestManager(newFakeSessionStorage())
+
+	err := m.Logout(httptest.NewRecorder(), requestWithCookie("unknown"))
+	assert.NoError(t, err, "expected logging out of an unknown session to succeed")
+}
+
+func TestLogoutEverywhere_DeletesOnlyTheSessionsOfTheUser(t *testing.T) {
+	store := newFakeSessionStorage()
+	m := newTestManager(store)
+	for range 3 {
+		_, err := m.Login(t.Context(), httptest.NewRecorder(), "username")
+		require.NoError(t, err, "expected no error when logging in")
+	}
+	other, err := m.Login(t.Context(), httptest.NewRecorder(), "other")
+	require.NoError(t, err, "expected no error when logging in")
+
+	err = m.LogoutEverywhere(t.Context(), httptest.NewRecorder(), "username")
+	require.NoError(t, err, "expected no error when logging out everywhere")
+
+	assert.Len(t, store.sessions, 1, "expected only the other user's session to remain")
+	assert.Contains(t, store.sessions, other.CookieValue)
+}
+
+func TestRotateSession_ReplacesSessionId_AndKeepsCreationTime(t *testing.T) {
+	store := newFakeSessionStorage()
+	m := newTestManager(store)
+	sesh, err := m.Login(t.Context(), httptest.NewRecorder(), "username")
+	require.NoError(t, err, "expected no error when logging in")
+
+	rec := httptest.NewRecorder()
+	rotated, err := m.RotateSession(rec, requestWithCookie(sesh.CookieValue))
+	require.NoError(t, err, "expected no error when rotating session")
+
+	assert.NotEqual(t, sesh.CookieValue, rotated.CookieValue, "expected a new session id")
+	assert.Equal(t, sesh.CreatedAt, rotated.CreatedAt, "expected creation time to be kept")
+	assert.Equal(t, sesh.UserID, rotated.UserID, "expected user to be kept")
+	assert.NotContains(t, store.sessions, sesh.CookieValue, "expected old session to be removed")
+	assert.Equal(t, rotated.CookieValue, responseCookie(t, rec).Value, "expected cookie to carry the new id")
+}
+
+func TestRotateSession_ReturnsError_WhenSessionDoesntExist(t *testing.T) {
+	m := newTestManager(newFakeSessionStorage())
+
+	_, err := m.RotateSession(httptest.NewRecorder(), requestWithCookie("unknown"))
+	assert.ErrorIs(t, err, ErrNotFound, "expected error when rotating an unknown session")
+}
+
+func TestRevokeOtherSessions_RotatesTheCurrentSession(t *testing.T) {
+	store := newFakeSessionStorage()
+	m := newTestManager(store)
+	current, err := m.Login(t.Context(), httptest.NewRecorder(), "username")
+	require.NoError(t, err, "expected no error when logging in")
+	for range 2 {
+		_, err := m.Login(t.Context(), httptest.NewRecorder(), "username")
+		require.NoError(t, err, "expected no error when logging in")
+	}
+	other, err := m.Login(t.Context(), httptest.NewRecorder(), "other")
+	require.NoError(t, err, "expected no error when logging in")
+
+	rec := httptest.NewRecorder()
+	rotated, err := m.RevokeOtherSessions(rec, requestWithCookie(current.CookieValue), "username")
+	require.NoError(t, err, "expected no error when revoking sessions")
+
+	assert.Len(t, store.sessions, 2, "expected only the rotated session and the other user's session to remain")
+	assert.Contains(t, store.sessions, rotated.CookieValue)
+	assert.Contains(t, store.sessions, other.CookieValue)
+	assert.NotEqual(t, current.CookieValue, rotated.CookieValue, "expected a new session id")
+	assert.Equal(t, current.CreatedAt, rotated.CreatedAt, "expected creation time to be kept")
+	assert.Equal(t, rotated.CookieValue, responseCookie(t, rec).Value, "expected cookie to carry the new id")
+}
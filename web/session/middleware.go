@@ -0,0 +1,104 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+)
+
+type contextKey struct{}
+
+var userContextKey = contextKey{}
+
+// ContextWithUser returns a copy of ctx carrying the authenticated individual.
+func ContextWithUser(ctx context.Context, userId model.IndividualId) context.Context {
+	return context.WithValue(ctx, userContextKey, userId)
+}
+
+// UserFromContext returns the individual authenticated by the session
+// middleware, if any.
+func UserFromContext(ctx context.Context) (model.IndividualId, bool) {
+	userId, ok := ctx.Value(userContextKey).(model.IndividualId)
+	return userId, ok
+}
+
+// Authenticate only lets requests with a valid session cookie through, and
+// makes the session's individual available to next via UserFromContext.
+func (m *SessionManager) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		cookie, err := r.Cookie(m.cookieName)
+		if err != nil {
+			writeUnauthenticated(w, "missing session cookie")
+			return
+		}
+
+		sesh, err := m.storage.GetSession(ctx, cookie.Value)
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrUserDeleted) {
+			m.logger.DebugContext(ctx, "rejected session", slog.Any("error", err))
+			writeUnauthenticated(w, "invalid session")
+			return
+		} else if err != nil {
+			m.logger.ErrorContext(ctx, "could not retrieve session", slog.Any("error", err))
+			writeInternalError(w)
+			return
+		}
+
+		if m.isExpired(sesh) {
+			m.logger.DebugContext(ctx, "rejected expired session", slog.String("username", string(sesh.UserID)))
+			writeUnauthenticated(w, "session expired")
+			return
+		}
+
+		if time.Since(sesh.LastAccessed) >= m.lastAccessedUpdateInterval {
+			err := m.storage.UpdateLastAccessed(ctx, sesh.CookieValue, time.Now())
+			// the session may have been removed in the meantime, e.g. by a
+			// logout from another tab
+			if errors.Is(err, ErrNotFound) {
+				writeUnauthenticated(w, "invalid session")
+				return
+			} else if err != nil {
+				// not being able to bump the last access time is not a
+				// reason to fail the request
+				m.logger.WarnContext(ctx, "could not update session last access time", slog.Any("error", err))
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithUser(ctx, sesh.UserID)))
+	})
+}
+
+// mirrors the error format of the json api
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{
+		Error: errorBody{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+func writeUnauthenticated(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusUnauthorized, "unauthenticated", message)
+}
+
+func writeInternalError(w http.ResponseWriter) {
+	writeError(w, http.StatusInternalServerError, "internal_error", "internal server error")
+}
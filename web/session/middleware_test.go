@@ -0,0 +1,198 @@
+package session
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSessionStorage struct {
+	sessions map[string]Session
+	updates  int
+	// number of sessions removed by every DeleteExpiredSessions call
+	reaped []int64
+}
+
+func newFakeSessionStorage() *fakeSessionStorage {
+	return &fakeSessionStorage{
+		sessions: make(map[string]Session),
+	}
+}
+
+func (f *fakeSessionStorage) StoreSession(_ context.Context, s Session) error {
+	f.sessions[s.CookieValue] = s
+	return nil
+}
+
+func (f *fakeSessionStorage) GetSession(_ context.Context, cookie string) (Session, error) {
+	s, ok := f.sessions[cookie]
+	if !ok {
+		return Session{}, ErrNotFound
+	}
+	return s, nil
+}
+
+func (f *fakeSessionStorage) UpdateLastAccessed(_ context.Context, cookie string, lastAccessed time.Time) error {
+	s, ok := f.sessions[cookie]
+	if !ok {
+		return ErrNotFound
+	}
+	s.LastAccessed = lastAccessed
+	f.sessions[cookie] = s
+	f.updates++
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteSession(_ context.Context, cookie string) error {
+	if _, ok := f.sessions[cookie]; !ok {
+		return ErrNotFound
+	}
+	delete(f.sessions, cookie)
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteSessionsOfUser(_ context.Context, userId model.IndividualId) (int64, error) {
+	var removed int64
+	for cookie, s := range f.sessions {
+		if s.UserID == userId {
+			delete(f.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (f *fakeSessionStorage) DeleteOtherSessionsOfUser(_ context.Context, userId model.IndividualId, keepCookie string) (int64, error) {
+	var removed int64
+	for cookie, s := range f.sessions {
+		if s.UserID == userId && cookie != keepCookie {
+			delete(f.sessions, cookie)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (f *fakeSessionStorage) RotateSession(_ context.Context, oldCookie string, newSession Session) error {
+	if _, ok := f.sessions[oldCookie]; !ok {
+		return ErrNotFound
+	}
+	delete(f.sessions, oldCookie)
+	f.sessions[newSession.CookieValue] = newSession
+	return nil
+}
+
+func (f *fakeSessionStorage) DeleteExpiredSessions(_ context.Context, idleBefore, createdBefore time.Time, limit int) (int64, error) {
+	var removed int64
+	for cookie, s := range f.sessions {
+		if removed == int64(limit) {
+			break
+		}
+		if s.LastAccessed.Before(idleBefore) || s.CreatedAt.Before(createdBefore) {
+			delete(f.sessions, cookie)
+			removed++
+		}
+	}
+	f.reaped = append(f.reaped, removed)
+	return removed, nil
+}
+
+func (f *fakeSessionStorage) CountActiveSessions(_ context.Context, idleAfter, createdAfter time.Time) (int64, error) {
+	var count int64
+	for _, s := range f.sessions {
+		if !s.LastAccessed.Before(idleAfter) && !s.CreatedAt.Before(createdAfter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func newTestManager(store SessionStorage) *SessionManager {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewSessionManager(store, 10*time.Minute, time.Hour, "", logger)
+}
+
+// serves a request through the middleware and returns the response along with
+// the user seen by the wrapped handler
+func serveAuthenticated(m *SessionManager, cookie string) (*httptest.ResponseRecorder, model.IndividualId) {
+	var seen model.IndividualId
+	h := m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = UserFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: SESSION_COOKIE_NAME, Value: cookie})
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, seen
+}
+
+func TestAuthenticate_RejectsRequest_WhenCookieIsMissing(t *testing.T) {
+	m := newTestManager(newFakeSessionStorage())
+
+	rec, _ := serveAuthenticated(m, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected request without cookie to be rejected")
+}
+
+func TestAuthenticate_RejectsRequest_WhenSessionIsUnknown(t *testing.T) {
+	m := newTestManager(newFakeSessionStorage())
+
+	rec, _ := serveAuthenticated(m, "unknown")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected request with unknown session to be rejected")
+}
+
+func TestAuthenticate_PutsUserInContext_WhenSessionIsValid(t *testing.T) {
+	store := newFakeSessionStorage()
+	m := newTestManager(store)
+	s, err := NewSessionForUser("username")
+	assert.NoError(t, err, "expected no error when creating session")
+	store.sessions[s.CookieValue] = s
+
+	rec, user := serveAuthenticated(m, s.CookieValue)
+	assert.Equal(t, http.StatusOK, rec.Code, "expected request with valid session to pass")
+	assert.Equal(t, model.IndividualId("username"), user, "expected user to be in the request context")
+}
+
+func TestAuthenticate_RejectsRequest_WhenSessionIsExpired(t *testing.T) {
+	synctest.Run(func() {
+		store := newFakeSessionStorage()
+		m := newTestManager(store)
+		s, _ := NewSessionForUser("username")
+		store.sessions[s.CookieValue] = s
+
+		time.Sleep(11 * time.Minute)
+
+		rec, _ := serveAuthenticated(m, s.CookieValue)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "expected expired session to be rejected")
+	})
+}
+
+func TestAuthenticate_ThrottlesLastAccessedUpdates(t *testing.T) {
+	synctest.Run(func() {
+		store := newFakeSessionStorage()
+		m := newTestManager(store)
+		s, _ := NewSessionForUser("username")
+		store.sessions[s.CookieValue] = s
+
+		serveAuthenticated(m, s.CookieValue)
+		time.Sleep(10 * time.Second)
+		serveAuthenticated(m, s.CookieValue)
+		assert.Equal(t, 0, store.updates, "expected no update within the throttling interval")
+
+		time.Sleep(time.Minute)
+		serveAuthenticated(m, s.CookieValue)
+		serveAuthenticated(m, s.CookieValue)
+		assert.Equal(t, 1, store.updates, "expected a single update after the throttling interval")
+	})
+}
@@ -0,0 +1,45 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Ozoniuss/hangcounts/batch"
+)
+
+// number of sessions deleted by a single query, to keep the reaper from
+// holding locks on the table for too long
+const REAPER_BATCH_SIZE = 1000
+
+// ReapExpiredSessions deletes the expired sessions every interval, until ctx
+// is cancelled. Expired sessions are already refused by the middleware, this
+// only stops the sessions table from growing forever.
+func (m *SessionManager) ReapExpiredSessions(ctx context.Context, interval time.Duration, batchSize int) {
+	batch.RunEvery(ctx, "session reaper", interval, m.logger, func(ctx context.Context) {
+		m.reapExpiredSessions(ctx, batchSize)
+	})
+}
+
+// CountActiveSessions returns the number of sessions which are not expired.
+func (m *SessionManager) CountActiveSessions(ctx context.Context) (int64, error) {
+	now := time.Now()
+	return m.storage.CountActiveSessions(ctx, now.Add(-m.idleExpiration), now.Add(-m.absoluteExpiration))
+}
+
+func (m *SessionManager) reapExpiredSessions(ctx context.Context, batchSize int) {
+	now := time.Now()
+	idleBefore := now.Add(-m.idleExpiration)
+	createdBefore := now.Add(-m.absoluteExpiration)
+
+	total, err := batch.Drain(ctx, batchSize, func(ctx context.Context, limit int) (int64, error) {
+		return m.storage.DeleteExpiredSessions(ctx, idleBefore, createdBefore, limit)
+	})
+	if err != nil {
+		// try again on the next tick
+		m.logger.ErrorContext(ctx, "could not delete expired sessions", slog.Any("error", err), slog.Int64("removed", total))
+		return
+	}
+
+	m.logger.InfoContext(ctx, "reaped expired sessions", slog.Int64("removed", total))
+}
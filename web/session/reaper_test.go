@@ -0,0 +1,90 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReapExpiredSessions_RemovesOnlyExpiredSessions(t *testing.T) {
+	synctest.Run(func() {
+		store := newFakeSessionStorage()
+		m := newTestManager(store)
+
+		idle, _ := NewSessionForUser("idle")
+		store.sessions[idle.CookieValue] = idle
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			m.ReapExpiredSessions(ctx, time.Minute, REAPER_BATCH_SIZE)
+			close(done)
+		}()
+
+		// the idle expiration of the test manager is 10 minutes
+		time.Sleep(11 * time.Minute)
+		fresh, _ := NewSessionForUser("fresh")
+		store.sessions[fresh.CookieValue] = fresh
+
+		time.Sleep(time.Minute)
+		synctest.Wait()
+
+		assert.NotContains(t, store.sessions, idle.CookieValue, "expected idle session to be reaped")
+		assert.Contains(t, store.sessions, fresh.CookieValue, "expected fresh session to be kept")
+
+		cancel()
+		<-done
+	})
+}
+
+func TestReapExpiredSessions_RemovesSessionsInBatches(t *testing.T) {
+	synctest.Run(func() {
+		store := newFakeSessionStorage()
+		m := newTestManager(store)
+		for range 5 {
+			s, _ := NewSessionForUser("username")
+			store.sessions[s.CookieValue] = s
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			m.ReapExpiredSessions(ctx, 2*time.Hour, 2)
+			close(done)
+		}()
+
+		time.Sleep(2*time.Hour + time.Second)
+		synctest.Wait()
+
+		assert.Empty(t, store.sessions, "expected all expired sessions to be reaped in one run")
+		assert.Equal(t, []int64{2, 2, 1}, store.reaped, "expected sessions to be removed in batches")
+
+		cancel()
+		<-done
+	})
+}
+
+func TestReapExpiredSessions_Stops_WhenContextIsCancelled(t *testing.T) {
+	synctest.Run(func() {
+		m := newTestManager(newFakeSessionStorage())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			m.ReapExpiredSessions(ctx, time.Minute, REAPER_BATCH_SIZE)
+			close(done)
+		}()
+
+		cancel()
+		synctest.Wait()
+
+		select {
+		case <-done:
+		default:
+			t.Fatal("expected reaper to stop after cancellation")
+		}
+	})
+}
@@ -7,10 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"time"
 
 	"github.com/Ozoniuss/hangcounts/domain/model"
-	"github.com/Ozoniuss/hangcounts/domain/storage"
 )
 
 func GenerateSecureSessionId() (string, error) {
@@ -26,12 +26,32 @@ func GenerateSecureSessionId() (string, error) {
 
 const (
 	SESSION_COOKIE_NAME = "session_id"
+
+	// upper bound on how often the last access time of a session is written
+	// to the database
+	MAX_LAST_ACCESSED_UPDATE_INTERVAL = time.Minute
 )
 
 type SessionStorage interface {
 	StoreSession(ctx context.Context, session Session) error
 	GetSession(ctx context.Context, cookie string) (Session, error)
-	UpdateLastAccessed(ctx context.Context, cookie string, lastAccessed string) error
+	UpdateLastAccessed(ctx context.Context, cookie string, lastAccessed time.Time) error
+	DeleteSession(ctx context.Context, cookie string) error
+	// DeleteSessionsOfUser returns the number of removed sessions.
+	DeleteSessionsOfUser(ctx context.Context, userId model.IndividualId) (int64, error)
+	// DeleteOtherSessionsOfUser is DeleteSessionsOfUser keeping the session
+	// identified by keepCookie.
+	DeleteOtherSessionsOfUser(ctx context.Context, userId model.IndividualId, keepCookie string) (int64, error)
+	// RotateSession atomically replaces the session identified by oldCookie
+	// with newSession.
+	RotateSession(ctx context.Context, oldCookie string, newSession Session) error
+	// DeleteExpiredSessions removes at most limit sessions that were last
+	// accessed before idleBefore or created before createdBefore, and returns
+	// how many were removed.
+	DeleteExpiredSessions(ctx context.Context, idleBefore, createdBefore time.Time, limit int) (int64, error)
+	// CountActiveSessions returns the number of sessions last accessed after
+	// idleAfter and created after createdAfter.
+	CountActiveSessions(ctx context.Context, idleAfter, createdAfter time.Time) (int64, error)
 }
 
 var ErrNotFound = errors.New("session not found in database")
@@ -61,23 +81,38 @@ func NewSessionForUser(userId model.IndividualId) (Session, error) {
 }
 
 type SessionManager struct {
-	storage            storage.AppStorage
+	storage            SessionStorage
 	idleExpiration     time.Duration
 	absoluteExpiration time.Duration
 	cookieName         string
+	logger             *slog.Logger
+
+	// the last access time is only persisted if it is older than this, to
+	// avoid a database write on every request
+	lastAccessedUpdateInterval time.Duration
 }
 
 func NewSessionManager(
-	store storage.AppStorage,
+	store SessionStorage,
 	idleExpiration,
 	absoluteExpiration time.Duration,
 	cookieName string,
+	logger *slog.Logger,
 ) *SessionManager {
+	if cookieName == "" {
+		cookieName = SESSION_COOKIE_NAME
+	}
+	// a tenth of the idle expiration keeps sessions well away from expiring
+	// because of the throttling
+	updateInterval := min(idleExpiration/10, MAX_LAST_ACCESSED_UPDATE_INTERVAL)
+
 	return &SessionManager{
-		storage:            store,
-		idleExpiration:     idleExpiration,
-		absoluteExpiration: absoluteExpiration,
-		cookieName:         cookieName,
+		storage:                    store,
+		idleExpiration:             idleExpiration,
+		absoluteExpiration:         absoluteExpiration,
+		cookieName:                 cookieName,
+		logger:                     logger,
+		lastAccessedUpdateInterval: updateInterval,
 	}
 }
 
@@ -2,6 +2,7 @@ package session
 
 import (
 	"encoding/base64"
+	"log/slog"
 	"testing"
 	"testing/synctest"
 	"time"
@@ -32,7 +33,7 @@ func TestGenerateSessionId_Returns100DifferentSessions_WhenCallingTheGenerator10
 func TestSessionManager_detectsNewSessionAsNotExpired(t *testing.T) {
 	idleExp := 10 * time.Second
 	absExp := 30 * time.Second
-	m := NewSessionManager(nil, idleExp, absExp, "")
+	m := NewSessionManager(nil, idleExp, absExp, "", slog.Default())
 
 	s := Session{
 		CreatedAt:    time.Now(),
@@ -46,7 +47,7 @@ func TestSessionManager_detectsSessionAsNotExpired_WhenIdleIsCloseToExpiration(t
 	synctest.Run(func() {
 		idleExp := 10 * time.Second
 		absExp := 30 * time.Second
-		m := NewSessionManager(nil, idleExp, absExp, "")
+		m := NewSessionManager(nil, idleExp, absExp, "", slog.Default())
 
 		s := Session{
 			CreatedAt:    time.Now(),
@@ -62,7 +63,7 @@ func TestSessionManager_detectsSessionAsNotExpired_WhenCreatedForLongerThanIdle_
 	synctest.Run(func() {
 		idleExp := 10 * time.Second
 		absExp := 30 * time.Second
-		m := NewSessionManager(nil, idleExp, absExp, "")
+		m := NewSessionManager(nil, idleExp, absExp, "", slog.Default())
 
 		s := Session{
 			CreatedAt:    time.Now(),
@@ -81,7 +82,7 @@ func TestSessionManager_detectsSessionAsExpired_WhenIdleExpiredIt(t *testing.T)
 	synctest.Run(func() {
 		idleExp := 10 * time.Second
 		absExp := 30 * time.Second
-		m := NewSessionManager(nil, idleExp, absExp, "")
+		m := NewSessionManager(nil, idleExp, absExp, "", slog.Default())
 
 		s := Session{
 			CreatedAt:    time.Now(),
@@ -96,7 +97,7 @@ func TestSessionManager_detectsSessionAsExpired_WhenAbsoluteExpiredIt_AndIdleDid
 	synctest.Run(func() {
 		idleExp := 10 * time.Second
 		absExp := 15 * time.Second
-		m := NewSessionManager(nil, idleExp, absExp, "")
+		m := NewSessionManager(nil, idleExp, absExp, "", slog.Default())
 
 		s := Session{
 			CreatedAt:    time.Now(),